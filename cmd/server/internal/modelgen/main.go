@@ -0,0 +1,13 @@
+// Command modelgen generates the collection types of the models package
+// from the migrations of the server. It is run by go generate.
+package main
+
+import (
+	_ "github.com/sospartan/pb-toolkit/cmd/server/migrations" // register the migrations
+
+	"github.com/sospartan/pb-toolkit/pkg/codegen"
+)
+
+func main() {
+	codegen.Main()
+}
@@ -6,8 +6,8 @@ import (
 	"os"
 	"strings"
 
-	"github.com/sospartan/pb-toolkit/cmd/server/migrations"
 	_ "github.com/sospartan/pb-toolkit/cmd/server/migrations" // import migrations
+	"github.com/sospartan/pb-toolkit/cmd/server/models"
 	"github.com/sospartan/pb-toolkit/pkg/rpc"
 	"github.com/sospartan/pb-toolkit/pkg/wechat"
 
@@ -29,6 +29,9 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
+	// Load demo data with "server seed [--reset]"
+	app.RootCmd.AddCommand(newSeedCommand(app))
+
 	// Create RPC server
 	rpcServer := rpc.NewServer()
 
@@ -69,10 +72,10 @@ func main() {
 
 		// after auth, get user info as regular record auth
 		authed := w.Group("/authed")
-		authed.Bind(apis.RequireAuth(migrations.CollectionNameWechatAuth))
+		authed.Bind(apis.RequireAuth(models.CollectionNameWechatAuth))
 		authed.GET("/profile", func(e *core.RequestEvent) error {
 			record := e.Auth
-			user := record.Get(migrations.FieldWeAuthinfo).(map[string]any)
+			user := record.Get(models.WechatAuthFieldWeAuthinfo).(map[string]any)
 			return e.JSON(http.StatusOK, user)
 		})
 
@@ -5,45 +5,35 @@ import (
 	m "github.com/pocketbase/pocketbase/migrations"
 )
 
-const (
-	CollectionNameWechatAuth = "wechat_auth"
-	FieldWeOpenid = "we_openid"
-	FieldWeUnionid = "we_unionid"
-	FieldWeAuthinfo = "we_authinfo"
-	FieldWeTokenExpired = "we_token_expired"
-	FieldWeAccessToken = "we_access_token"
-	FieldLastAuthCode = "last_auth_code"
-)
-
 func init() {
 	m.Register(func(app core.App) error {
-		collection := core.NewCollection(core.CollectionTypeAuth, CollectionNameWechatAuth)
+		collection := core.NewCollection(core.CollectionTypeAuth, "wechat_auth")
 
 		collection.Fields.Add(
 			&core.TextField{
-				Name:     FieldWeOpenid,
+				Name:     "we_openid",
 				Required: true,
 				Max:      100,
 			},
 			&core.TextField{
-				Name:     FieldWeUnionid,
+				Name:     "we_unionid",
 				Required: false, // may be null
 				Max:      100,
 			},
 			&core.JSONField{
-				Name:     FieldWeAuthinfo,
+				Name:     "we_authinfo",
 				Required: true,
 			},
 			&core.DateField{
-				Name:     FieldWeTokenExpired,
+				Name:     "we_token_expired",
 				Required: true,
 			},
 			&core.JSONField{
-				Name:     FieldWeAccessToken,
+				Name:     "we_access_token",
 				Required: true,
 			},
 			&core.TextField{
-				Name:     FieldLastAuthCode,
+				Name:     "last_auth_code",
 				Required: true,
 			},
 			&core.AutodateField{
@@ -62,13 +52,13 @@ func init() {
 		}
 
 		// add index for better query performance
-		collection.AddIndex("idx_openid", true, FieldWeOpenid, "")
-		collection.AddIndex("idx_last_auth_code", false, FieldLastAuthCode, "")
+		collection.AddIndex("idx_openid", true, "we_openid", "")
+		collection.AddIndex("idx_last_auth_code", false, "last_auth_code", "")
 
 		return app.Save(collection)
 	}, func(app core.App) error {
 		// add down queries...
-		collection, err := app.FindCollectionByNameOrId(CollectionNameWechatAuth)
+		collection, err := app.FindCollectionByNameOrId("wechat_auth")
 		if err != nil {
 			return err
 		}
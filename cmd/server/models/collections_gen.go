@@ -0,0 +1,85 @@
+// Code generated by pb-toolkit codegen. DO NOT EDIT.
+
+package models
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/sospartan/pb-toolkit/pkg/dsl"
+)
+
+// Collection names.
+const (
+	CollectionNameProducts   = "products"
+	CollectionNameWechatAuth = "wechat_auth"
+)
+
+// ProductID is the id of a products record.
+type ProductID string
+
+// Product is a record of the products collection.
+type Product struct {
+	ID          ProductID      `json:"id" pb:"id"`
+	Name        string         `json:"name" pb:"name"`
+	Price       float64        `json:"price" pb:"price"`
+	Description string         `json:"description" pb:"description"`
+	Created     types.DateTime `json:"created" pb:"created"`
+	Updated     types.DateTime `json:"updated" pb:"updated"`
+}
+
+// Field names of the products collection.
+const (
+	ProductFieldID          = "id"
+	ProductFieldName        = "name"
+	ProductFieldPrice       = "price"
+	ProductFieldDescription = "description"
+	ProductFieldCreated     = "created"
+	ProductFieldUpdated     = "updated"
+)
+
+// ProductRepo returns a repository of the products collection.
+func ProductRepo(app core.App) *dsl.Repo[Product] {
+	return dsl.NewRepo[Product](app, CollectionNameProducts)
+}
+
+// WechatAuthID is the id of a wechat_auth record.
+type WechatAuthID string
+
+// WechatAuth is a record of the wechat_auth collection.
+type WechatAuth struct {
+	ID              WechatAuthID   `json:"id" pb:"id"`
+	Email           string         `json:"email" pb:"email"`
+	EmailVisibility bool           `json:"emailVisibility" pb:"emailVisibility"`
+	Verified        bool           `json:"verified" pb:"verified"`
+	WeOpenid        string         `json:"we_openid" pb:"we_openid"`
+	WeUnionid       string         `json:"we_unionid" pb:"we_unionid"`
+	WeAuthinfo      types.JSONRaw  `json:"we_authinfo" pb:"we_authinfo"`
+	WeTokenExpired  types.DateTime `json:"we_token_expired" pb:"we_token_expired"`
+	WeAccessToken   types.JSONRaw  `json:"we_access_token" pb:"we_access_token"`
+	LastAuthCode    string         `json:"last_auth_code" pb:"last_auth_code"`
+	Created         types.DateTime `json:"created" pb:"created"`
+	Updated         types.DateTime `json:"updated" pb:"updated"`
+}
+
+// Field names of the wechat_auth collection.
+const (
+	WechatAuthFieldID              = "id"
+	WechatAuthFieldPassword        = "password"
+	WechatAuthFieldTokenKey        = "tokenKey"
+	WechatAuthFieldEmail           = "email"
+	WechatAuthFieldEmailVisibility = "emailVisibility"
+	WechatAuthFieldVerified        = "verified"
+	WechatAuthFieldWeOpenid        = "we_openid"
+	WechatAuthFieldWeUnionid       = "we_unionid"
+	WechatAuthFieldWeAuthinfo      = "we_authinfo"
+	WechatAuthFieldWeTokenExpired  = "we_token_expired"
+	WechatAuthFieldWeAccessToken   = "we_access_token"
+	WechatAuthFieldLastAuthCode    = "last_auth_code"
+	WechatAuthFieldCreated         = "created"
+	WechatAuthFieldUpdated         = "updated"
+)
+
+// WechatAuthRepo returns a repository of the wechat_auth collection.
+func WechatAuthRepo(app core.App) *dsl.Repo[WechatAuth] {
+	return dsl.NewRepo[WechatAuth](app, CollectionNameWechatAuth)
+}
@@ -0,0 +1,6 @@
+// Package models holds the Go types of the server collections, generated
+// from the migrations into collections_gen.go. Run go generate after
+// changing a migration; CI fails when the generated file is stale.
+package models
+
+//go:generate go run ../internal/modelgen -o collections_gen.go -collections products,wechat_auth
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/sospartan/pb-toolkit/pkg/dsl"
+	"github.com/spf13/cobra"
+)
+
+//go:embed fixtures
+var fixturesFS embed.FS
+
+// newSeedCommand returns the "seed" command, which loads the demo fixtures
+// (or the given fixture files and directories) into the database.
+func newSeedCommand(app core.App) *cobra.Command {
+	var reset bool
+
+	cmd := &cobra.Command{
+		Use:   "seed [paths...]",
+		Short: "Loads the demo fixtures, or the given fixture files, into the database",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := app.RunAllMigrations(); err != nil {
+				return err
+			}
+
+			paths := args
+			options := dsl.FixtureOptions{Reset: reset}
+			if len(paths) == 0 {
+				paths = []string{"fixtures"}
+				options.FS = fixturesFS
+			}
+
+			result, err := dsl.LoadFixtures(app, paths, options)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Fixtures loaded: %d created, %d updated, %d unchanged, %d deleted\n",
+				result.Created, result.Updated, result.Unchanged, result.Deleted)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&reset, "reset", false, "delete the records of the fixture collections before loading")
+
+	return cmd
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/sospartan/pb-toolkit/cmd/server/models"
 	"github.com/sospartan/pb-toolkit/pkg/dsl"
 )
 
-
+// Product is the products record of the RPC API. It stays separate from
+// the generated models.Product so that schema changes don't change the
+// wire format.
 type Product struct {
 	ID          string `json:"id,omitempty"`
 	Name        string `json:"name"`
@@ -15,6 +20,18 @@ type Product struct {
 	Updated     string `json:"updated,omitempty"`
 }
 
+// productFromModel maps a stored product onto the RPC type.
+func productFromModel(p models.Product) Product {
+	return Product{
+		ID:          string(p.ID),
+		Name:        p.Name,
+		Price:       int(p.Price),
+		Description: p.Description,
+		Created:     p.Created.String(),
+		Updated:     p.Updated.String(),
+	}
+}
+
 type ListRequest struct{}
 
 type UpdateRequest struct {
@@ -32,97 +49,61 @@ type ProductsService struct {
 	app core.App
 }
 
+func (s *ProductsService) products() *dsl.Repo[models.Product] {
+	return models.ProductRepo(s.app)
+}
+
 func (s *ProductsService) Create(req Product) (Product, error) {
-	data := map[string]any{
-		"name":        req.Name,
-		"price":       req.Price,
-		"description": req.Description,
-	}
-	record, err := dsl.Collection(s.app, "products").Create(data)
+	product, err := s.products().Create(models.Product{
+		Name:        req.Name,
+		Price:       float64(req.Price),
+		Description: req.Description,
+	})
 	if err != nil {
 		return Product{}, err
 	}
-	return Product{
-		ID:          record.Id,
-		Name:        record.GetString("name"),
-		Price:       record.GetInt("price"),
-		Description: record.GetString("description"),
-		Created:     record.GetString("created"),
-		Updated:     record.GetString("updated"),
-	}, nil
+	return productFromModel(product), nil
 }
 
 func (s *ProductsService) GetProduct(id string) (Product, error) {
-	record, err := dsl.Collection(s.app, "products").One(id)
+	product, err := s.products().One(id)
 	if err != nil {
 		return Product{}, err
 	}
-	return Product{
-		ID:          record.Id,
-		Name:        record.GetString("name"),
-		Price:       record.GetInt("price"),
-		Description: record.GetString("description"),
-		Created:     record.GetString("created"),
-		Updated:     record.GetString("updated"),
-	}, nil
+	return productFromModel(product), nil
 }
 
 func (s *ProductsService) List(req ListRequest) ([]Product, error) {
 	query := dsl.Query("")
-	records, err := dsl.Collection(s.app, "products").List(*query)
+	items, err := s.products().List(*query)
 	if err != nil {
 		return nil, err
 	}
-	products := make([]Product, len(records))
-	for i, record := range records {
-		products[i] = Product{
-			ID:          record.Id,
-			Name:        record.GetString("name"),
-			Price:       record.GetInt("price"),
-			Description: record.GetString("description"),
-			Created:     record.GetString("created"),
-			Updated:     record.GetString("updated"),
-		}
+	products := make([]Product, len(items))
+	for i, item := range items {
+		products[i] = productFromModel(item)
 	}
 	return products, nil
 }
 
 func (s *ProductsService) Update(req UpdateRequest) (Product, error) {
-	data := map[string]any{
-		"name":        req.Name,
-		"price":       req.Price,
-		"description": req.Description,
-	}
-	record, err := dsl.Collection(s.app, "products").Update(req.ID, data)
+	product, err := s.products().Update(req.ID, models.Product{
+		Name:        req.Name,
+		Price:       float64(req.Price),
+		Description: req.Description,
+	})
 	if err != nil {
 		return Product{}, err
 	}
-	return Product{
-		ID:          record.Id,
-		Name:        record.GetString("name"),
-		Price:       record.GetInt("price"),
-		Description: record.GetString("description"),
-		Created:     record.GetString("created"),
-		Updated:     record.GetString("updated"),
-	}, nil
+	return productFromModel(product), nil
 }
 
 func (s *ProductsService) Delete(req DeleteRequest) error {
-	return dsl.Collection(s.app, "products").Delete(req.ID)
+	return dsl.Collection(s.app, models.CollectionNameProducts).Delete(req.ID)
 }
 
 func (s *ProductsService) Clean() error {
-	query := dsl.Query("")
-	records, err := dsl.Collection(s.app, "products").List(*query)
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		if err := dsl.Collection(s.app, "products").Delete(record.Id); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
\ No newline at end of file
+	return dsl.Collection(s.app, models.CollectionNameProducts).Each(context.Background(), *dsl.Query(""), func(record *core.Record) error {
+		return s.app.Delete(record)
+	})
+}
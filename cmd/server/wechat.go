@@ -1,16 +1,14 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"net/http"
 
-	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/router"
 	"github.com/pocketbase/pocketbase/tools/security"
-	"github.com/sospartan/pb-toolkit/cmd/server/migrations"
+	"github.com/sospartan/pb-toolkit/cmd/server/models"
 	"github.com/sospartan/pb-toolkit/pkg/dsl"
 	"github.com/sospartan/pb-toolkit/pkg/wechat"
 )
@@ -27,13 +25,11 @@ type WechatAuthHandler struct {
 
 // FindAuthRecordByCode implements wechat.AuthHandler.
 func (h *WechatAuthHandler) FindAuthRecordByCode(code string) (*core.Record, error) {
-	collection := dsl.Collection(h.app, migrations.CollectionNameWechatAuth)
-	query := dsl.Query(fmt.Sprintf("%s = {:code}", migrations.FieldLastAuthCode))
-	record, err := collection.First(*query, dbx.Params{
-		"code": code,
-	})
+	collection := dsl.Collection(h.app, models.CollectionNameWechatAuth)
+	query := dsl.Where(dsl.Eq(models.WechatAuthFieldLastAuthCode, code))
+	record, err := collection.First(*query)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, dsl.ErrNotFound) {
 			return nil, wechat.NoAuthRecordError
 		}
 		return nil, err
@@ -52,41 +48,30 @@ func (h *WechatAuthHandler) GetAuthConfig() *wechat.WechatAuth {
 // ModifyAuthRecord implements wechat.AuthHandler.
 func (h *WechatAuthHandler) ModifyAuthRecord(record *core.Record) error {
 	record.Hide(
-		migrations.FieldLastAuthCode,
-		migrations.FieldWeAccessToken,
-		migrations.FieldWeTokenExpired,
+		models.WechatAuthFieldLastAuthCode,
+		models.WechatAuthFieldWeAccessToken,
+		models.WechatAuthFieldWeTokenExpired,
 	)
 	return nil
 }
 
 // Save implements wechat.AuthHandler.
 func (h *WechatAuthHandler) Save(token *wechat.AccessTokenResponse, info *wechat.UserInfoResponse, code string) (*core.Record, error) {
-	collection := dsl.Collection(h.app, migrations.CollectionNameWechatAuth)
-	record, err := collection.First(*dsl.Query(fmt.Sprintf("%s = {:openid}", migrations.FieldWeOpenid)), dbx.Params{
-		"openid": info.OpenID,
-	})
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
-	}
-	if record == nil {
-		return collection.Create(map[string]any{
-			core.FieldNamePassword:         security.RandomString(10),
-			core.FieldNameEmail:            info.OpenID + "@pb.com",
-			migrations.FieldWeOpenid:       info.OpenID,
-			migrations.FieldWeUnionid:      info.UnionID,
-			migrations.FieldWeAuthinfo:     info,
-			migrations.FieldWeAccessToken:  token,
-			migrations.FieldWeTokenExpired: token.ExpiresIn,
-			migrations.FieldLastAuthCode:   code,
-		})
-	}
-
-	return collection.Update(record.Id, map[string]any{
-		migrations.FieldWeAccessToken:  token,
-		migrations.FieldWeTokenExpired: token.ExpiresIn,
-		migrations.FieldLastAuthCode:   code,
-		migrations.FieldWeAuthinfo:     info,
-	})
+	record, _, err := dsl.Collection(h.app, models.CollectionNameWechatAuth).Upsert(
+		[]string{models.WechatAuthFieldWeOpenid},
+		map[string]any{
+			core.FieldNamePassword:               security.RandomString(10),
+			core.FieldNameEmail:                  info.OpenID + "@pb.com",
+			models.WechatAuthFieldWeOpenid:       info.OpenID,
+			models.WechatAuthFieldWeUnionid:      info.UnionID,
+			models.WechatAuthFieldWeAuthinfo:     info,
+			models.WechatAuthFieldWeAccessToken:  token,
+			models.WechatAuthFieldWeTokenExpired: token.ExpiresIn,
+			models.WechatAuthFieldLastAuthCode:   code,
+		},
+		dsl.UpsertOptions{InsertOnly: []string{core.FieldNamePassword, core.FieldNameEmail}},
+	)
+	return record, err
 }
 
 func (h *WechatAuthHandler) SetupRoutes(g *router.RouterGroup[*core.RequestEvent]) {
@@ -94,11 +79,11 @@ func (h *WechatAuthHandler) SetupRoutes(g *router.RouterGroup[*core.RequestEvent
 	g.GET("/callback", wechat.HandleAuthResponseWithCode(h))
 
 	authed := g.Group("/authed")
-	authed.Bind(apis.RequireAuth(migrations.CollectionNameWechatAuth))
+	authed.Bind(apis.RequireAuth(models.CollectionNameWechatAuth))
 
 	authed.GET("/profile", func(e *core.RequestEvent) error {
 		record := e.Auth
-		user := record.Get(migrations.FieldWeAuthinfo).(map[string]any)
+		user := record.Get(models.WechatAuthFieldWeAuthinfo).(map[string]any)
 		return e.JSON(http.StatusOK, user)
 	})
 
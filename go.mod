@@ -6,8 +6,13 @@ toolchain go1.23.10
 
 // Core dependencies for pb-toolkit library
 require (
+	github.com/ganigeorgiev/fexpr v0.5.0 // Filter parser used to check client queries against a dsl.QueryPolicy
+	github.com/go-ozzo/ozzo-validation/v4 v4.3.0 // Validation errors unpacked into dsl.ValidationError
 	github.com/pocketbase/dbx v1.11.0 // Database abstraction layer for PocketBase
 	github.com/pocketbase/pocketbase v0.28.4 // PocketBase core library for DSL functionality
+	github.com/spf13/cast v1.9.2 // Value conversions for aggregation results
+	github.com/spf13/cobra v1.9.1 // CLI commands of the example server
+	gopkg.in/yaml.v3 v3.0.1 // Fixture file parser for dsl.LoadFixtures
 )
 
 // Indirect dependencies (automatically managed by Go modules)
@@ -18,8 +23,6 @@ require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/fatih/color v1.18.0 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.9 // indirect
-	github.com/ganigeorgiev/fexpr v0.5.0 // indirect
-	github.com/go-ozzo/ozzo-validation/v4 v4.3.0 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -27,8 +30,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	github.com/spf13/cast v1.9.2 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	golang.org/x/crypto v0.39.0 // indirect
 	golang.org/x/exp v0.0.0-20250606033433-dcc06ee1d476 // indirect
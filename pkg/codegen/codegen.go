@@ -0,0 +1,294 @@
+// Package codegen generates Go types for PocketBase collections: a struct
+// per collection usable with dsl.Repo, field name constants, enums for the
+// select fields and id types for the relations.
+//
+// The collections are read from the migrations of the application or from
+// a PocketBase data directory, see LoadMigrations, LoadDataDir and Main.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Header is the first line of the generated files.
+const Header = "// Code generated by pb-toolkit codegen. DO NOT EDIT."
+
+// initialisms are the words written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "https": true, "id": true, "ip": true,
+	"json": true, "sql": true, "uid": true, "uri": true, "url": true, "uuid": true,
+}
+
+// Options configures Generate.
+type Options struct {
+	// Package is the package name of the generated file. Defaults to
+	// "models".
+	Package string
+
+	// Collections lists the names of the generated collections. By
+	// default all the given collections are generated.
+	Collections []string
+
+	// TypeNames overrides the Go type name of collections, by collection
+	// name. By default the type name is the singular of the collection
+	// name in camel case, e.g. "order_items" becomes OrderItem.
+	TypeNames map[string]string
+}
+
+// collectionType describes the generated types of a collection.
+type collectionType struct {
+	collection *core.Collection
+	name       string // Struct type name, e.g. Product
+}
+
+// Generate returns the gofmt-ed source of a Go file declaring, for every
+// collection:
+//
+//   - a CollectionName<Collection> constant with the collection name
+//   - a <Type>ID string type for the record ids
+//   - a <Type> struct with a json and pb tagged field per visible
+//     collection field, usable with dsl.Repo
+//   - <Type>Field<Field> constants with the names of all collection fields
+//   - a <Type><Field> string type and constants for every select field
+//   - a <Type>Repo function returning a dsl.Repo of the collection
+//
+// Relation fields are typed with the id type of the related collection when
+// it is generated too, and with string otherwise. Hidden fields (e.g.
+// passwords) only get a field name constant.
+//
+// Example:
+//
+//	collections, err := codegen.LoadDataDir("pb_data")
+//	source, err := codegen.Generate(collections, codegen.Options{Package: "models"})
+//	err = os.WriteFile("models/collections_gen.go", source, 0o644)
+func Generate(collections []*core.Collection, opts ...Options) ([]byte, error) {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Package == "" {
+		options.Package = "models"
+	}
+
+	selected, err := selectCollections(collections, options)
+	if err != nil {
+		return nil, err
+	}
+	byId := map[string]*collectionType{}
+	names := map[string]string{}
+	for _, t := range selected {
+		if other, ok := names[t.name]; ok {
+			return nil, fmt.Errorf("collections %q and %q both map to the type %s, set Options.TypeNames", other, t.collection.Name, t.name)
+		}
+		names[t.name] = t.collection.Name
+		byId[t.collection.Id] = t
+	}
+
+	g := &generator{types: byId}
+	for _, t := range selected {
+		g.collection(t)
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "%s\n\npackage %s\n\n", Header, options.Package)
+	file.WriteString("import (\n")
+	file.WriteString("\t\"github.com/pocketbase/pocketbase/core\"\n")
+	if g.usesTypes {
+		file.WriteString("\t\"github.com/pocketbase/pocketbase/tools/types\"\n")
+	}
+	file.WriteString("\t\"github.com/sospartan/pb-toolkit/pkg/dsl\"\n)\n\n")
+	file.WriteString("// Collection names.\nconst (\n")
+	for _, t := range selected {
+		fmt.Fprintf(&file, "\tCollectionName%s = %q\n", goName(t.collection.Name), t.collection.Name)
+	}
+	file.WriteString(")\n")
+	file.Write(g.buf.Bytes())
+
+	source, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the generated code: %w", err)
+	}
+	return source, nil
+}
+
+// selectCollections returns the collections to generate, sorted by name.
+func selectCollections(collections []*core.Collection, options Options) ([]*collectionType, error) {
+	var selected []*collectionType
+	for _, collection := range collections {
+		if len(options.Collections) > 0 && !slices.Contains(options.Collections, collection.Name) {
+			continue
+		}
+		name := options.TypeNames[collection.Name]
+		if name == "" {
+			name = goName(singular(collection.Name))
+		}
+		selected = append(selected, &collectionType{collection: collection, name: name})
+	}
+	for _, name := range options.Collections {
+		if !slices.ContainsFunc(selected, func(t *collectionType) bool { return t.collection.Name == name }) {
+			return nil, fmt.Errorf("unknown collection %q", name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no collections to generate")
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].collection.Name < selected[j].collection.Name
+	})
+	return selected, nil
+}
+
+// generator writes the declarations of the collections.
+type generator struct {
+	buf       bytes.Buffer
+	types     map[string]*collectionType // Generated collections by id
+	usesTypes bool                       // Whether the types package is used
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// collection writes the declarations of a collection.
+func (g *generator) collection(t *collectionType) {
+	c := t.collection
+
+	g.printf("\n// %sID is the id of a %s record.\ntype %sID string\n", t.name, c.Name, t.name)
+
+	g.printf("\n// %s is a record of the %s collection.\ntype %s struct {\n", t.name, c.Name, t.name)
+	for _, field := range c.Fields {
+		if field.GetHidden() {
+			continue
+		}
+		name := field.GetName()
+		g.printf("\t%s %s `json:%q pb:%q`\n", goName(name), g.goType(t, field), name, name)
+	}
+	g.printf("}\n")
+
+	g.printf("\n// Field names of the %s collection.\nconst (\n", c.Name)
+	for _, field := range c.Fields {
+		g.printf("\t%sField%s = %q\n", t.name, goName(field.GetName()), field.GetName())
+	}
+	g.printf(")\n")
+
+	for _, field := range c.Fields {
+		if selectField, ok := field.(*core.SelectField); ok {
+			g.enum(t, selectField)
+		}
+	}
+
+	g.printf("\n// %sRepo returns a repository of the %s collection.\n", t.name, c.Name)
+	g.printf("func %sRepo(app core.App) *dsl.Repo[%s] {\n", t.name, t.name)
+	g.printf("\treturn dsl.NewRepo[%s](app, CollectionName%s)\n}\n", t.name, goName(c.Name))
+}
+
+// enum writes the type and the value constants of a select field.
+func (g *generator) enum(t *collectionType, field *core.SelectField) {
+	typeName := t.name + goName(field.Name)
+	g.printf("\n// %s is a value of the %s.%s select field.\ntype %s string\n", typeName, t.collection.Name, field.Name, typeName)
+	g.printf("\n// Values of the %s.%s select field.\nconst (\n", t.collection.Name, field.Name)
+	used := map[string]bool{}
+	for _, value := range field.Values {
+		name := goName(value)
+		if name == "" {
+			name = "Empty"
+		}
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+		}
+		used[name] = true
+		g.printf("\t%s%s %s = %q\n", typeName, name, typeName, value)
+	}
+	g.printf(")\n")
+}
+
+// goType returns the Go type of a collection field of t.
+func (g *generator) goType(t *collectionType, field core.Field) string {
+	switch f := field.(type) {
+	case *core.NumberField:
+		if f.OnlyInt {
+			return "int"
+		}
+		return "float64"
+	case *core.BoolField:
+		return "bool"
+	case *core.DateField, *core.AutodateField:
+		g.usesTypes = true
+		return "types.DateTime"
+	case *core.JSONField:
+		g.usesTypes = true
+		return "types.JSONRaw"
+	case *core.GeoPointField:
+		g.usesTypes = true
+		return "types.GeoPoint"
+	case *core.SelectField:
+		return multiple(f.IsMultiple(), t.name+goName(f.Name))
+	case *core.RelationField:
+		idType := "string"
+		if related, ok := g.types[f.CollectionId]; ok {
+			idType = related.name + "ID"
+		}
+		return multiple(f.IsMultiple(), idType)
+	case *core.FileField:
+		return multiple(f.IsMultiple(), "string")
+	}
+	if field.GetName() == core.FieldNameId {
+		return t.name + "ID"
+	}
+	return "string"
+}
+
+// multiple returns a slice of typeName for multiple fields.
+func multiple(isMultiple bool, typeName string) string {
+	if isMultiple {
+		return "[]" + typeName
+	}
+	return typeName
+}
+
+// goName converts a collection, field or select value name to an exported
+// Go name, e.g. "we_openid" to WeOpenid and "emailVisibility" to
+// EmailVisibility. Names starting with a digit get an X prefix.
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	if s := b.String(); s != "" && unicode.IsDigit(rune(s[0])) {
+		return "X" + s
+	}
+	return b.String()
+}
+
+// singular returns the singular of a plural collection name, e.g.
+// "categories" becomes "category".
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") &&
+		!strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is"):
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,217 @@
+package codegen
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		return app.Save(testCollections()[0])
+	}, nil, "1700000000_codegen_test.go")
+}
+
+// testCollections returns a categories and an order_items collection.
+func testCollections() []*core.Collection {
+	categories := core.NewBaseCollection("categories")
+	categories.Id = "pbc_categories"
+	categories.Fields.Add(&core.TextField{Name: "name"})
+
+	items := core.NewBaseCollection("order_items")
+	items.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.NumberField{Name: "price"},
+		&core.NumberField{Name: "quantity", OnlyInt: true},
+		&core.BoolField{Name: "gift"},
+		&core.SelectField{Name: "status", Values: []string{"draft", "in-stock", "2nd hand"}, MaxSelect: 1},
+		&core.SelectField{Name: "tags", Values: []string{"new", "sale"}, MaxSelect: 2},
+		&core.RelationField{Name: "category", CollectionId: categories.Id, MaxSelect: 1},
+		&core.RelationField{Name: "owners", CollectionId: "_pb_users_auth_", MaxSelect: 5},
+		&core.JSONField{Name: "meta"},
+		&core.FileField{Name: "images", MaxSelect: 3},
+		&core.TextField{Name: "secret", Hidden: true},
+		&core.AutodateField{Name: "created", OnCreate: true},
+	)
+	return []*core.Collection{categories, items}
+}
+
+func TestGenerate(t *testing.T) {
+	source, err := Generate(testCollections(), Options{Package: "shop"})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	code := string(source)
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", source, parser.AllErrors); err != nil {
+		t.Fatalf("Expected valid Go code, got %v\n%s", err, code)
+	}
+
+	expected := []string{
+		Header + "\n\npackage shop",
+		`"github.com/pocketbase/pocketbase/tools/types"`,
+		`CollectionNameCategories = "categories"`,
+		`CollectionNameOrderItems = "order_items"`,
+		"type OrderItemID string",
+		"type OrderItem struct {",
+		"ID       OrderItemID ",
+		"Price    float64 ",
+		"Quantity int ",
+		"Gift     bool ",
+		"Status   OrderItemStatus ",
+		"Tags     []OrderItemTags ",
+		"Category CategoryID ",
+		"Owners   []string ",
+		"Meta     types.JSONRaw ",
+		"Images   []string ",
+		"Created  types.DateTime ",
+		"`json:\"category\" pb:\"category\"`",
+		`OrderItemFieldSecret   = "secret"`,
+		`OrderItemStatusInStock OrderItemStatus = "in-stock"`,
+		`OrderItemStatusX2ndHand OrderItemStatus = "2nd hand"`,
+		"func OrderItemRepo(app core.App) *dsl.Repo[OrderItem] {",
+		"return dsl.NewRepo[OrderItem](app, CollectionNameOrderItems)",
+	}
+	normalized := strings.Join(strings.Fields(code), " ")
+	for _, s := range expected {
+		if !strings.Contains(normalized, strings.Join(strings.Fields(s), " ")) {
+			t.Errorf("Expected the generated code to contain %q, got\n%s", s, code)
+		}
+	}
+	if strings.Contains(code, "\tSecret ") {
+		t.Errorf("Expected the hidden field to be left out of the struct, got\n%s", code)
+	}
+	if strings.Index(code, "type Category struct") > strings.Index(code, "type OrderItem struct") {
+		t.Error("Expected the collections to be sorted by name")
+	}
+
+	// only the selected collections, with custom type names
+	source, err = Generate(testCollections(), Options{Collections: []string{"order_items"}, TypeNames: map[string]string{"order_items": "Line"}})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	code = string(source)
+	if !strings.Contains(code, "package models") || !strings.Contains(code, "type Line struct") || !strings.Contains(code, "Category string ") {
+		t.Errorf("Expected the Line type with a string relation, got\n%s", code)
+	}
+	if strings.Contains(code, "type Category struct") {
+		t.Error("Expected the categories to be left out")
+	}
+
+	if _, err := Generate(testCollections(), Options{Collections: []string{"missing"}}); err == nil {
+		t.Error("Expected error for an unknown collection")
+	}
+	if _, err := Generate(testCollections(), Options{TypeNames: map[string]string{"order_items": "Category"}}); err == nil {
+		t.Error("Expected error for a type name collision")
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := map[string]string{
+		"we_openid":       "WeOpenid",
+		"emailVisibility": "EmailVisibility",
+		"id":              "ID",
+		"avatar_url":      "AvatarURL",
+		"in-stock":        "InStock",
+		"2fa":             "X2fa",
+	}
+	for name, expected := range names {
+		if got := goName(name); got != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, name, got)
+		}
+	}
+
+	singulars := map[string]string{
+		"products":    "product",
+		"categories":  "category",
+		"boxes":       "box",
+		"addresses":   "address",
+		"status":      "status",
+		"wechat_auth": "wechat_auth",
+	}
+	for name, expected := range singulars {
+		if got := singular(name); got != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, name, got)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	collections, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load the migrations: %v", err)
+	}
+	if names := collectionNames(collections); names != "users,categories" {
+		t.Errorf("Expected the users and the migrated categories, got %s", names)
+	}
+
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test app: %v", err)
+	}
+	defer app.Cleanup()
+	// the test app already ran the migration creating the categories
+	if err := app.Save(testCollections()[1]); err != nil {
+		t.Fatalf("Failed to save collection: %v", err)
+	}
+	collections, err = LoadDataDir(app.DataDir())
+	if err != nil {
+		t.Fatalf("Failed to load the data dir: %v", err)
+	}
+	if names := collectionNames(collections); names != "users,categories,order_items" {
+		t.Errorf("Expected the collections of the data dir, got %s", names)
+	}
+	if status, ok := collections[2].Fields.GetByName("status").(*core.SelectField); !ok || len(status.Values) != 3 {
+		t.Errorf("Expected the select field options to be loaded, got %#v", collections[2].Fields.GetByName("status"))
+	}
+
+	if _, err := LoadDataDir(t.TempDir()); err == nil {
+		t.Error("Expected error for a directory without database")
+	}
+}
+
+func TestRun(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "models_gen.go")
+	args := []string{"-o", output, "-pkg", "models", "-collections", "categories"}
+
+	if err := Run(append(args, "-check"), os.Stderr); !errors.Is(err, ErrStale) {
+		t.Errorf("Expected ErrStale for a missing file, got %v", err)
+	}
+	if err := Run(args, os.Stderr); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	source, err := os.ReadFile(output)
+	if err != nil || !strings.Contains(string(source), "type Category struct") {
+		t.Fatalf("Expected the generated file, got %v", err)
+	}
+	if err := Run(append(args, "-check"), os.Stderr); err != nil {
+		t.Errorf("Expected the file to be up to date, got %v", err)
+	}
+
+	if err := os.WriteFile(output, append(source, "// edited\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(append(args, "-check"), os.Stderr); !errors.Is(err, ErrStale) {
+		t.Errorf("Expected ErrStale for an edited file, got %v", err)
+	}
+
+	if err := Run([]string{"-types", "categories"}, os.Stderr); err == nil {
+		t.Error("Expected error for an invalid -types flag")
+	}
+}
+
+// collectionNames returns the comma-separated names of collections.
+func collectionNames(collections []*core.Collection) string {
+	names := make([]string, len(collections))
+	for i, collection := range collections {
+		names[i] = collection.Name
+	}
+	return strings.Join(names, ",")
+}
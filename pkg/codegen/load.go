@@ -0,0 +1,83 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// LoadMigrations applies the registered migrations to a temporary
+// database and returns the resulting collections, without the system
+// ones.
+//
+// Go migrations register themselves when their package is imported, so
+// the program calling LoadMigrations must import the migrations package of
+// the application.
+//
+// Example:
+//
+//	import _ "myapp/migrations"
+//
+//	collections, err := codegen.LoadMigrations()
+func LoadMigrations() ([]*core.Collection, error) {
+	dir, err := os.MkdirTemp("", "pb_codegen_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: dir})
+	if err := app.Bootstrap(); err != nil {
+		return nil, fmt.Errorf("failed to bootstrap the temporary app: %w", err)
+	}
+	defer app.ResetBootstrapState()
+
+	if err := app.RunAllMigrations(); err != nil {
+		return nil, fmt.Errorf("failed to apply the migrations: %w", err)
+	}
+	collections, err := app.FindAllCollections()
+	if err != nil {
+		return nil, err
+	}
+	return withoutSystem(collections), nil
+}
+
+// LoadDataDir returns the collections of the PocketBase data directory dir
+// (e.g. "pb_data"), without the system ones. The database is opened read
+// only.
+//
+// Example:
+//
+//	collections, err := codegen.LoadDataDir("pb_data")
+func LoadDataDir(dir string) ([]*core.Collection, error) {
+	path := filepath.Join(dir, "data.db")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no PocketBase database in %s: %w", dir, err)
+	}
+
+	db, err := dbx.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	collections := []*core.Collection{}
+	if err := db.Select("*").From((&core.Collection{}).TableName()).OrderBy("rowid ASC").All(&collections); err != nil {
+		return nil, fmt.Errorf("failed to read the collections of %s: %w", dir, err)
+	}
+	return withoutSystem(collections), nil
+}
+
+// withoutSystem returns the collections that aren't system collections.
+func withoutSystem(collections []*core.Collection) []*core.Collection {
+	result := make([]*core.Collection, 0, len(collections))
+	for _, collection := range collections {
+		if !collection.System {
+			result = append(result, collection)
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package codegen
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ErrStale is returned by Run with -check when the generated file is
+// missing or out of date.
+var ErrStale = errors.New("generated code is out of date, run go generate")
+
+// Main runs the generator with the command line arguments and exits with
+// a non-zero status on failure. See Run for the flags.
+//
+// A small generator program importing the migrations of the application
+// is enough to generate its types with go generate:
+//
+//	// internal/modelgen/main.go
+//	package main
+//
+//	import (
+//	    _ "myapp/migrations"
+//
+//	    "github.com/sospartan/pb-toolkit/pkg/codegen"
+//	)
+//
+//	func main() { codegen.Main() }
+//
+//	// models/models.go
+//	//go:generate go run ../internal/modelgen -o collections_gen.go
+//	package models
+func Main() {
+	if err := Run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "codegen:", err)
+		os.Exit(1)
+	}
+}
+
+// Run generates a Go file from the collections of the registered
+// migrations, or of a data directory, with the given command line flags:
+//
+//	-o file          output file (default "collections_gen.go")
+//	-pkg name        package name (default $GOPACKAGE, or "models")
+//	-dir path        read the collections from a PocketBase data directory
+//	                 instead of the migrations
+//	-collections a,b generate only the listed collections
+//	-types a=T,b=U   Go type names by collection name
+//	-check           don't write anything, fail with ErrStale when the output
+//	                 file isn't up to date (for CI)
+//
+// Usage errors are written to log.
+func Run(args []string, log io.Writer) error {
+	flags := flag.NewFlagSet("codegen", flag.ContinueOnError)
+	flags.SetOutput(log)
+	output := flags.String("o", "collections_gen.go", "output file")
+	pkg := flags.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	dir := flags.String("dir", "", "PocketBase data directory to read the collections from, instead of the migrations")
+	collections := flags.String("collections", "", "comma-separated collections to generate, all by default")
+	typeNames := flags.String("types", "", "comma-separated collection=Type names")
+	check := flags.Bool("check", false, "fail if the output file is out of date instead of writing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := Options{Package: *pkg, TypeNames: map[string]string{}}
+	if *collections != "" {
+		options.Collections = strings.Split(*collections, ",")
+	}
+	if *typeNames != "" {
+		for _, pair := range strings.Split(*typeNames, ",") {
+			collection, name, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid -types entry %q, expected collection=Type", pair)
+			}
+			options.TypeNames[collection] = name
+		}
+	}
+
+	var loaded []*core.Collection
+	var err error
+	if *dir != "" {
+		loaded, err = LoadDataDir(*dir)
+	} else {
+		loaded, err = LoadMigrations()
+	}
+	if err != nil {
+		return err
+	}
+	source, err := Generate(loaded, options)
+	if err != nil {
+		return err
+	}
+
+	if *check {
+		current, err := os.ReadFile(*output)
+		if err != nil || !bytes.Equal(current, source) {
+			return fmt.Errorf("%s: %w", *output, ErrStale)
+		}
+		return nil
+	}
+	return os.WriteFile(*output, source, 0o644)
+}
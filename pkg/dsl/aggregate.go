@@ -0,0 +1,447 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+	"github.com/spf13/cast"
+)
+
+// aliasRegex matches the allowed result column names of aggregate queries.
+var aliasRegex = regexp.MustCompile(`^\w+$`)
+
+// dateGroupFormats maps the date modifiers of group keys to strftime
+// formats.
+var dateGroupFormats = map[string]string{
+	"year":  "%Y",
+	"month": "%Y-%m",
+	"day":   "%Y-%m-%d",
+	"hour":  "%Y-%m-%d %H:00",
+}
+
+// Aggregation describes an aggregate function over a field, created with
+// SumOf, AvgOf, MinOf, MaxOf, CountOf or CountAll.
+type Aggregation struct {
+	fn    string // The SQL aggregate function
+	field string // The aggregated field path, empty for CountAll
+	alias string // The result column name
+}
+
+// SumOf returns the sum of field, named "sum_<field>" unless renamed
+// with As.
+func SumOf(field string) Aggregation {
+	return Aggregation{fn: "SUM", field: field, alias: "sum_" + defaultAlias(field)}
+}
+
+// AvgOf returns the average of field, named "avg_<field>" unless renamed
+// with As.
+func AvgOf(field string) Aggregation {
+	return Aggregation{fn: "AVG", field: field, alias: "avg_" + defaultAlias(field)}
+}
+
+// MinOf returns the minimum of field, named "min_<field>" unless renamed
+// with As.
+func MinOf(field string) Aggregation {
+	return Aggregation{fn: "MIN", field: field, alias: "min_" + defaultAlias(field)}
+}
+
+// MaxOf returns the maximum of field, named "max_<field>" unless renamed
+// with As.
+func MaxOf(field string) Aggregation {
+	return Aggregation{fn: "MAX", field: field, alias: "max_" + defaultAlias(field)}
+}
+
+// CountOf returns the number of non-null values of field, named
+// "count_<field>" unless renamed with As.
+func CountOf(field string) Aggregation {
+	return Aggregation{fn: "COUNT", field: field, alias: "count_" + defaultAlias(field)}
+}
+
+// CountAll returns the number of records in each group, named "count"
+// unless renamed with As.
+func CountAll() Aggregation {
+	return Aggregation{fn: "COUNT", alias: "count"}
+}
+
+// As renames the result column of the aggregation.
+//
+// Example:
+//
+//	dsl.SumOf("total").As("revenue")
+func (a Aggregation) As(alias string) Aggregation {
+	a.alias = alias
+	return a
+}
+
+// defaultAlias derives a result column name from a field path.
+func defaultAlias(field string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(field)
+}
+
+// AggregateQuery represents an aggregation over the records of a
+// collection, optionally grouped by one or more fields.
+//
+// It is created with CollectionQueryBuilder.GroupBy (or Aggregate for a
+// single, ungrouped row) and executed with Rows or All.
+type AggregateQuery struct {
+	collection   *CollectionQueryBuilder // The aggregated collection
+	groups       []string                // The group keys
+	aggregations []Aggregation           // The aggregate functions
+	having       string                  // Filter on the aggregated rows
+}
+
+// GroupBy starts an aggregation grouped by the given fields.
+//
+// Fields can be any path the filter syntax supports (e.g. "category.name").
+// A date field can be truncated with a ":year", ":month", ":day" or ":hour"
+// suffix. The result column is named after the path with "." and ":"
+// replaced by "_", unless an alias is given with " as ".
+//
+// Example:
+//
+//	rows, err := dsl.Collection(app, "orders").
+//	    GroupBy("created:day as day").
+//	    Aggregate(dsl.SumOf("total").As("revenue"), dsl.CountAll()).
+//	    Rows(*dsl.Query("status = 'paid'").Sort("day"))
+func (c *CollectionQueryBuilder) GroupBy(fields ...string) *AggregateQuery {
+	return &AggregateQuery{collection: c, groups: fields}
+}
+
+// Aggregate starts an ungrouped aggregation, which produces a single row.
+//
+// Example:
+//
+//	rows, err := dsl.Collection(app, "products").
+//	    Aggregate(dsl.AvgOf("price"), dsl.MaxOf("price")).
+//	    Rows(*dsl.Query("status = 'active'"))
+func (c *CollectionQueryBuilder) Aggregate(aggregations ...Aggregation) *AggregateQuery {
+	return &AggregateQuery{collection: c, aggregations: aggregations}
+}
+
+// Aggregate adds aggregate functions to the query.
+func (a *AggregateQuery) Aggregate(aggregations ...Aggregation) *AggregateQuery {
+	a.aggregations = append(a.aggregations, aggregations...)
+	return a
+}
+
+// Having filters the aggregated rows. The filter uses the PocketBase
+// filter syntax, with the group and aggregation column names as fields;
+// its placeholders are bound from the params passed to Rows or All.
+//
+// Example:
+//
+//	query.Having("revenue > {:min} && count >= 10")
+func (a *AggregateQuery) Having(filter string) *AggregateQuery {
+	a.having = filter
+	return a
+}
+
+// AggregateRow is a single row of an aggregation result, keyed by the
+// result column names.
+type AggregateRow map[string]any
+
+// Float returns the value of column as float64 (0 for NULL).
+func (r AggregateRow) Float(column string) float64 {
+	return cast.ToFloat64(r[column])
+}
+
+// Int returns the value of column as int (0 for NULL).
+func (r AggregateRow) Int(column string) int {
+	return cast.ToInt(r[column])
+}
+
+// String returns the value of column as string ("" for NULL).
+func (r AggregateRow) String(column string) string {
+	return cast.ToString(r[column])
+}
+
+// Rows runs the aggregation over the records matching the query and
+// returns the result rows.
+//
+// The filter and params of the query are applied to the records as in
+// List. Sort and Page apply to the aggregated rows, so the sort expression
+// refers to the result column names.
+func (a *AggregateQuery) Rows(query QueryBuilder, params ...dbx.Params) ([]AggregateRow, error) {
+	q, err := a.build(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, a.collection.contextError(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []AggregateRow
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(AggregateRow, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// All runs the aggregation like Rows and scans the rows into dest, which
+// must be a pointer to a slice of structs whose fields are matched to the
+// result columns by their `db` tags.
+//
+// Example:
+//
+//	var stats []struct {
+//	    Day     string  `db:"day"`
+//	    Revenue float64 `db:"revenue"`
+//	}
+//	err := dsl.Collection(app, "orders").
+//	    GroupBy("created:day as day").
+//	    Aggregate(dsl.SumOf("total").As("revenue")).
+//	    All(&stats, *dsl.Query(""))
+func (a *AggregateQuery) All(dest any, query QueryBuilder, params ...dbx.Params) error {
+	q, err := a.build(query, params)
+	if err != nil {
+		return err
+	}
+	return a.collection.contextError(q.All(dest))
+}
+
+// build composes the aggregate select query.
+//
+// The filter is applied through an id subquery, so that the joins of
+// multi-valued relations in the filter don't count records twice.
+func (a *AggregateQuery) build(query QueryBuilder, params []dbx.Params) (*dbx.SelectQuery, error) {
+	if len(a.groups) == 0 && len(a.aggregations) == 0 {
+		return nil, errors.New("aggregate query without groups or aggregations")
+	}
+
+	c := a.collection
+	filtered, err := c.filteredSelect(query, params)
+	if err != nil {
+		return nil, err
+	}
+	collection := filtered.collection
+
+	q := c.withQueryContext(c.app.DB().Select().From(collection.Name))
+	if query.filter != "" || filtered.searchTable != "" || c.deletedExpr(collection) != nil || c.rules() != nil {
+		ids := filtered.build().Select("[[" + collection.Name + ".id]]").Build()
+		q.AndWhere(dbx.NewExp("[["+collection.Name+".id]] IN ("+ids.SQL()+")", ids.Params()))
+	}
+
+	// like the filter, the fields can only use hidden fields without rules
+	resolver := core.NewRecordFieldResolver(c.app, collection, c.rules(), c.rules() == nil)
+	columns := aggregateResolver{}
+
+	for _, group := range a.groups {
+		field, alias := parseGroupKey(group)
+		expr, err := resolveAggregateField(resolver, field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group field %q: %w", group, err)
+		}
+		if err := columns.add(alias, expr); err != nil {
+			return nil, err
+		}
+		q.AndSelect(expr + " AS [[" + alias + "]]").AndGroupBy(expr)
+	}
+
+	for _, aggregation := range a.aggregations {
+		expr := "[[" + collection.Name + ".id]]"
+		if aggregation.field != "" {
+			expr, err = resolveAggregateField(resolver, aggregation.field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid aggregate field %q: %w", aggregation.field, err)
+			}
+		}
+		if aggregation.fn == "COUNT" && aggregation.field == "" {
+			expr = aggregation.fn + "(DISTINCT " + expr + ")"
+		} else {
+			expr = aggregation.fn + "(" + expr + ")"
+		}
+		if err := columns.add(aggregation.alias, expr); err != nil {
+			return nil, err
+		}
+		q.AndSelect(expr + " AS [[" + aggregation.alias + "]]")
+	}
+
+	if err := resolver.UpdateQuery(q); err != nil {
+		return nil, err
+	}
+	// the joins are only needed for the resolved columns
+	q.Distinct(false)
+
+	if a.having != "" {
+		expr, err := search.FilterData(a.having).BuildExpr(columns, query.mergeParams(params)...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid having expression: %w", err)
+		}
+		q.AndHaving(expr)
+	}
+
+	if query.sort != "" {
+		for _, sortField := range search.ParseSortFromString(query.sort) {
+			if sortField.Name == "" {
+				continue
+			}
+			expr, err := sortField.BuildExpr(columns)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sort field %q: %w", sortField.Name, err)
+			}
+			q.AndOrderBy(expr)
+		}
+	}
+
+	if query.perPage > 0 {
+		q.Limit(int64(query.perPage))
+		if query.page > 1 {
+			q.Offset(int64((query.page - 1) * query.perPage))
+		}
+	}
+
+	instrument(c.app, collection.Name, q, query.debug)
+	return q, nil
+}
+
+// parseGroupKey splits a group key into its field and result column name.
+func parseGroupKey(group string) (string, string) {
+	field, alias := strings.TrimSpace(group), ""
+	if i := strings.LastIndex(strings.ToLower(field), " as "); i != -1 {
+		field, alias = strings.TrimSpace(field[:i]), strings.TrimSpace(field[i+4:])
+	}
+	if alias == "" {
+		alias = defaultAlias(field)
+	}
+	return field, alias
+}
+
+// resolveAggregateField resolves a field path, with an optional date
+// modifier, into an SQL expression.
+func resolveAggregateField(resolver *core.RecordFieldResolver, field string) (string, error) {
+	format := ""
+	if i := strings.LastIndex(field, ":"); i != -1 {
+		if f, ok := dateGroupFormats[field[i+1:]]; ok {
+			field, format = field[:i], f
+		}
+	}
+
+	result, err := resolver.Resolve(field)
+	if err != nil {
+		return "", err
+	}
+	if len(result.Params) > 0 {
+		return "", errors.New("fields with bound parameters can't be aggregated")
+	}
+	if result.AfterBuild != nil {
+		// e.g. auth emails, whose visibility is only applied to conditions
+		return "", errors.New("fields with a visibility condition can't be aggregated")
+	}
+	if format != "" {
+		return "strftime('" + format + "', " + result.Identifier + ")", nil
+	}
+	return result.Identifier, nil
+}
+
+// aggregateResolver resolves the result column names of an aggregate query
+// in Having and Sort expressions.
+type aggregateResolver map[string]string
+
+// add registers a result column.
+func (r aggregateResolver) add(alias, expr string) error {
+	if !aliasRegex.MatchString(alias) {
+		return fmt.Errorf("invalid result column name %q", alias)
+	}
+	if _, exists := r[alias]; exists {
+		return fmt.Errorf("duplicated result column name %q", alias)
+	}
+	r[alias] = expr
+	return nil
+}
+
+// UpdateQuery implements search.FieldResolver.
+func (r aggregateResolver) UpdateQuery(query *dbx.SelectQuery) error {
+	return nil
+}
+
+// Resolve implements search.FieldResolver.
+func (r aggregateResolver) Resolve(field string) (*search.ResolverResult, error) {
+	expr, ok := r[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown result column %q", field)
+	}
+	return &search.ResolverResult{Identifier: expr}, nil
+}
+
+// aggregateValue computes a single aggregation over the records matching
+// the query.
+func (c *CollectionQueryBuilder) aggregateValue(aggregation Aggregation, query QueryBuilder, params []dbx.Params) (float64, error) {
+	rows, err := c.Aggregate(aggregation.As("value")).Rows(query, params...)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Float("value"), nil
+}
+
+// Sum returns the sum of the numeric field over the records matching the
+// query, or 0 if there are none.
+//
+// Example:
+//
+//	total, err := dsl.Collection(app, "orders").Sum("total", *dsl.Query("status = 'paid'"))
+func (c *CollectionQueryBuilder) Sum(field string, query QueryBuilder, params ...dbx.Params) (float64, error) {
+	return c.aggregateValue(SumOf(field), query, params)
+}
+
+// Avg returns the average of the numeric field over the records matching
+// the query, or 0 if there are none.
+//
+// Example:
+//
+//	avg, err := dsl.Collection(app, "products").Avg("price", *dsl.Query(""))
+func (c *CollectionQueryBuilder) Avg(field string, query QueryBuilder, params ...dbx.Params) (float64, error) {
+	return c.aggregateValue(AvgOf(field), query, params)
+}
+
+// Min returns the minimum of the numeric field over the records matching
+// the query, or 0 if there are none. Use MinOf with Rows for non-numeric
+// fields.
+//
+// Example:
+//
+//	cheapest, err := dsl.Collection(app, "products").Min("price", *dsl.Query(""))
+func (c *CollectionQueryBuilder) Min(field string, query QueryBuilder, params ...dbx.Params) (float64, error) {
+	return c.aggregateValue(MinOf(field), query, params)
+}
+
+// Max returns the maximum of the numeric field over the records matching
+// the query, or 0 if there are none. Use MaxOf with Rows for non-numeric
+// fields.
+//
+// Example:
+//
+//	highest, err := dsl.Collection(app, "products").Max("price", *dsl.Query(""))
+func (c *CollectionQueryBuilder) Max(field string, query QueryBuilder, params ...dbx.Params) (float64, error) {
+	return c.aggregateValue(MaxOf(field), query, params)
+}
@@ -0,0 +1,125 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/pocketbase/dbx"
+)
+
+// seedAggregates creates products in two categories and one without.
+func seedAggregates(t *testing.T) *CollectionQueryBuilder {
+	app := newTestApp(t)
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	books := mustCreate(t, app, "categories", map[string]any{"name": "books"})
+	for _, data := range []map[string]any{
+		{"name": "hammer", "price": 10, "status": "active", "category": tools.Id, "tags": []string{"new", "sale"}},
+		{"name": "saw", "price": 30, "status": "active", "category": tools.Id, "tags": []string{"sale"}},
+		{"name": "drill", "price": 50, "status": "draft", "category": tools.Id},
+		{"name": "novel", "price": 5, "status": "active", "category": books.Id, "tags": []string{"sale", "hot"}},
+		{"name": "misc", "price": 1, "status": "archived"},
+	} {
+		mustCreate(t, app, "products", data)
+	}
+
+	return Collection(app, "products")
+}
+
+func TestAggregateScalars(t *testing.T) {
+	c := seedAggregates(t)
+
+	scenarios := []struct {
+		name     string
+		fn       func(string, QueryBuilder, ...dbx.Params) (float64, error)
+		query    *QueryBuilder
+		params   []dbx.Params
+		expected float64
+	}{
+		{"sum", c.Sum, Query(""), nil, 96},
+		{"sum filtered", c.Sum, Query("status = {:status}"), []dbx.Params{{"status": "active"}}, 45},
+		{"sum with relation filter", c.Sum, Query("category.name = 'tools'"), nil, 90},
+		{"sum with multi-valued filter", c.Sum, Where(AnyEq(Each("tags"), "sale")), nil, 45},
+		{"avg", c.Avg, Where(Eq("category.name", "tools")), nil, 30},
+		{"min", c.Min, Query(""), nil, 1},
+		{"max", c.Max, Query("status != 'draft'"), nil, 30},
+		{"no records", c.Sum, Query("price > 100"), nil, 0},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			value, err := s.fn("price", *s.query, s.params...)
+			if err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+			if value != s.expected {
+				t.Errorf("Expected %v, got %v", s.expected, value)
+			}
+		})
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	c := seedAggregates(t)
+
+	rows, err := c.GroupBy("category.name as category").
+		Aggregate(SumOf("price"), CountAll(), MaxOf("name").As("last")).
+		Having("count >= {:min}").
+		Rows(*Query("status != 'archived'").Sort("-sum_price"), dbx.Params{"min": 1})
+	if err != nil {
+		t.Fatalf("Failed to group: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 groups, got %v", rows)
+	}
+	if rows[0].String("category") != "tools" || rows[0].Float("sum_price") != 90 || rows[0].Int("count") != 3 || rows[0].String("last") != "saw" {
+		t.Errorf("Unexpected tools row %v", rows[0])
+	}
+	if rows[1].String("category") != "books" || rows[1].Float("sum_price") != 5 || rows[1].Int("count") != 1 {
+		t.Errorf("Unexpected books row %v", rows[1])
+	}
+
+	var stats []struct {
+		Status string  `db:"status"`
+		Avg    float64 `db:"avg_price"`
+	}
+	err = c.GroupBy("status").
+		Aggregate(AvgOf("price")).
+		Having("avg_price > 2").
+		All(&stats, *Query("").Sort("status"))
+	if err != nil {
+		t.Fatalf("Failed to scan groups: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Status != "active" || stats[0].Avg != 15 || stats[1].Status != "draft" || stats[1].Avg != 50 {
+		t.Errorf("Unexpected typed rows %+v", stats)
+	}
+
+	days, err := c.GroupBy("created:day").Aggregate(CountAll()).Rows(*Query(""))
+	if err != nil {
+		t.Fatalf("Failed to group by day: %v", err)
+	}
+	if len(days) != 1 || len(days[0].String("created_day")) != len("2006-01-02") || days[0].Int("count") != 5 {
+		t.Errorf("Unexpected day rows %v", days)
+	}
+}
+
+func TestGroupByErrors(t *testing.T) {
+	c := seedAggregates(t)
+
+	scenarios := map[string]*AggregateQuery{
+		"no aggregations":   c.GroupBy(),
+		"unknown field":     c.GroupBy("missing").Aggregate(CountAll()),
+		"unknown having":    c.GroupBy("status").Aggregate(CountAll()).Having("total > 1"),
+		"invalid alias":     c.Aggregate(CountAll().As("a-b")),
+		"duplicated column": c.GroupBy("status as count").Aggregate(CountAll()),
+	}
+	for name, query := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			if _, err := query.Rows(*Query("")); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, err := c.GroupBy("status").Aggregate(CountAll()).Rows(*Query("").Sort("missing")); err == nil {
+		t.Error("Expected error for an unknown sort column")
+	}
+}
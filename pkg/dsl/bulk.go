@@ -0,0 +1,382 @@
+package dsl
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// bulkIdChunkSize is the maximum number of ids bound in a single fast path
+// UPDATE or DELETE statement.
+const bulkIdChunkSize = 500
+
+// BulkOptions configures CreateMany, UpdateWhere and DeleteWhere.
+type BulkOptions struct {
+	// ContinueOnError keeps processing the remaining items when one fails.
+	// Every item runs in its own savepoint, so a failed item is rolled back
+	// alone and the others are committed. By default the first failure
+	// rolls back the whole operation.
+	ContinueOnError bool
+
+	// SkipHooks enables the fast path: records are written with plain SQL
+	// statements, without PocketBase validation, record hooks and field
+	// interceptors (so no file uploads and no cascade deletes). Ids and
+	// autodate fields are still filled in.
+	SkipHooks bool
+}
+
+// BulkResult reports the outcome of a bulk operation.
+type BulkResult struct {
+	Affected int              // Number of created, updated or deleted records
+	Records  []*core.Record   // Created or updated records (hooks path only)
+	Errors   []*BulkItemError // Failures of individual items
+}
+
+// BulkItemError describes the failure of a single item of a bulk
+// operation.
+type BulkItemError struct {
+	Index int    // Position of the item in the input or iteration order
+	Id    string // Id of the record, if known
+	Err   error  // The underlying error
+}
+
+// Error implements the error interface.
+func (e *BulkItemError) Error() string {
+	if e.Id != "" {
+		return fmt.Sprintf("item %d (%s): %v", e.Index, e.Id, e.Err)
+	}
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BulkItemError) Unwrap() error {
+	return e.Err
+}
+
+// bulkOptions returns the first of opts, or the defaults.
+func bulkOptions(opts []BulkOptions) BulkOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return BulkOptions{}
+}
+
+// runItem runs fn for a single bulk item, in its own savepoint when
+// failures of single items are tolerated, and records the failure in
+// result. The returned error aborts the bulk operation.
+func (r *BulkResult) runItem(tx *Tx, opts BulkOptions, index int, id string, fn func(tx *Tx) error) error {
+	var err error
+	if opts.ContinueOnError {
+		err = tx.Transaction(fn)
+	} else {
+		err = fn(tx)
+	}
+	if err == nil {
+		r.Affected++
+		return nil
+	}
+
+	itemErr := &BulkItemError{Index: index, Id: id, Err: err}
+	r.Errors = append(r.Errors, itemErr)
+	if opts.ContinueOnError {
+		return nil
+	}
+	return itemErr
+}
+
+// CreateMany creates a record for each of items in a single transaction.
+//
+// Records are saved with the regular validation and hooks unless
+// SkipHooks is set. By default the first failure rolls back everything and
+// is returned as a *BulkItemError (the result then only holds the error
+// report); with ContinueOnError the failures are only reported in the
+// result.
+//
+// Example:
+//
+//	result, err := dsl.Collection(app, "products").CreateMany([]map[string]any{
+//	    {"name": "Widget", "price": 10},
+//	    {"name": "Gadget", "price": 20},
+//	})
+//	// result.Affected, result.Records, result.Errors
+func (c *CollectionQueryBuilder) CreateMany(items []map[string]any, opts ...BulkOptions) (*BulkResult, error) {
+	options := bulkOptions(opts)
+	if options.SkipHooks && c.rules() != nil {
+		return nil, errRulesSkipHooks
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+
+	result := &BulkResult{}
+	err = Transaction(c.app, func(tx *Tx) error {
+		for i, item := range items {
+			if err := c.checkContext(); err != nil {
+				return err
+			}
+			record := core.NewRecord(collection)
+			record.Load(item)
+			err := result.runItem(tx, options, i, record.Id, func(tx *Tx) error {
+				if options.SkipHooks {
+					return insertRecord(tx.App(), record)
+				}
+				if err := c.withApp(tx.App()).save(record); err != nil {
+					return err
+				}
+				result.Records = append(result.Records, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if options.SkipHooks {
+		// the statements don't trigger the cache invalidation hooks
+		invalidateQueryCache(c.app, c.collection)
+	}
+	if err != nil {
+		return &BulkResult{Errors: result.Errors}, err
+	}
+	return result, nil
+}
+
+// UpdateWhere applies patch to every record matching the query in a
+// single transaction. Pagination and sorting of the query are ignored.
+//
+// Records are saved with the regular validation and hooks unless
+// SkipHooks is set, in which case the matching records are updated with
+// UPDATE statements. See CreateMany for the error handling.
+//
+// Example:
+//
+//	result, err := dsl.Collection(app, "products").UpdateWhere(
+//	    *dsl.Where(dsl.Eq("status", "draft")),
+//	    map[string]any{"status": "archived"},
+//	)
+func (c *CollectionQueryBuilder) UpdateWhere(query QueryBuilder, patch map[string]any, opts ...BulkOptions) (*BulkResult, error) {
+	options := bulkOptions(opts)
+	if options.SkipHooks && c.rules() != nil {
+		return nil, errRulesSkipHooks
+	}
+	result := &BulkResult{}
+	err := Transaction(c.app, func(tx *Tx) error {
+		if options.SkipHooks {
+			ids, collection, err := c.withApp(tx.App()).matchingIds(query)
+			if err != nil {
+				return err
+			}
+			result.Affected, err = updateRecords(tx.App(), collection, ids, patch)
+			return err
+		}
+
+		index := 0
+		return c.withApp(tx.App()).EachChunk(c.context(), bulkQuery(query), func(records []*core.Record) error {
+			for _, record := range records {
+				record.Load(patch)
+				err := result.runItem(tx, options, index, record.Id, func(tx *Tx) error {
+					if err := c.withApp(tx.App()).save(record); err != nil {
+						return err
+					}
+					result.Records = append(result.Records, record)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				index++
+			}
+			return nil
+		})
+	})
+	if options.SkipHooks {
+		// the statements don't trigger the cache invalidation hooks
+		invalidateQueryCache(c.app, c.collection)
+	}
+	if err != nil {
+		return &BulkResult{Errors: result.Errors}, err
+	}
+	return result, nil
+}
+
+// DeleteWhere deletes every record matching the query in a single
+// transaction. Pagination and sorting of the query are ignored.
+//
+// Records are deleted with the regular hooks (including cascade deletes
+// and file cleanup) unless SkipHooks is set, in which case the matching
+// records are removed with DELETE statements. Records of soft-deletable
+// collections are marked as deleted instead, see EnableSoftDelete. See
+// CreateMany for the error handling.
+//
+// Example:
+//
+//	result, err := dsl.Collection(app, "sessions").DeleteWhere(*dsl.Where(dsl.Lt("expires", now)))
+func (c *CollectionQueryBuilder) DeleteWhere(query QueryBuilder, opts ...BulkOptions) (*BulkResult, error) {
+	options := bulkOptions(opts)
+	if options.SkipHooks && c.rules() != nil {
+		return nil, errRulesSkipHooks
+	}
+	result := &BulkResult{}
+	err := Transaction(c.app, func(tx *Tx) error {
+		if options.SkipHooks {
+			ids, collection, err := c.withApp(tx.App()).matchingIds(query)
+			if err != nil {
+				return err
+			}
+			if field := softDeleteField(tx.App(), collection); field != "" {
+				result.Affected, err = updateRecords(tx.App(), collection, ids, map[string]any{field: types.NowDateTime()})
+				return err
+			}
+			result.Affected, err = deleteRecords(tx.App(), collection, ids)
+			return err
+		}
+
+		index := 0
+		return c.withApp(tx.App()).EachChunk(c.context(), bulkQuery(query), func(records []*core.Record) error {
+			for _, record := range records {
+				err := result.runItem(tx, options, index, record.Id, func(tx *Tx) error {
+					return c.withApp(tx.App()).deleteRecord(record)
+				})
+				if err != nil {
+					return err
+				}
+				index++
+			}
+			return nil
+		})
+	})
+	if options.SkipHooks {
+		// the statements don't trigger the cache invalidation hooks
+		invalidateQueryCache(c.app, c.collection)
+	}
+	if err != nil {
+		return &BulkResult{Errors: result.Errors}, err
+	}
+	return result, nil
+}
+
+// bulkQuery prepares query for iterating over the records to modify.
+//
+// Records are walked in id order, which modifications can't change, so
+// that none is skipped or visited twice.
+func bulkQuery(query QueryBuilder) QueryBuilder {
+	query.page = 0
+	query.perPage = 0
+	query.sort = ""
+	query.expand = ""
+	query.fields = nil
+	query.preloads = nil
+	query.cursor = ""
+	query.cursorBefore = false
+	return query
+}
+
+// matchingIds returns the ids of all records matching the query.
+func (c *CollectionQueryBuilder) matchingIds(query QueryBuilder) ([]string, *core.Collection, error) {
+	q, collection, err := c.recordQuery(bulkQuery(query), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids []string
+	err = q.Select("[[" + collection.Name + ".id]]").Column(&ids)
+	if err != nil {
+		return nil, nil, c.contextError(err)
+	}
+	return ids, collection, nil
+}
+
+// insertRecord inserts record with a plain INSERT statement.
+func insertRecord(app core.App, record *core.Record) error {
+	if record.Id == "" {
+		record.Id = core.GenerateDefaultRandomId()
+	}
+	now := types.NowDateTime()
+	for _, field := range record.Collection().Fields {
+		if autodate, ok := field.(*core.AutodateField); ok && record.GetDateTime(autodate.Name).IsZero() {
+			record.SetRaw(autodate.Name, now)
+		}
+	}
+
+	data, err := record.DBExport(app)
+	if err != nil {
+		return err
+	}
+	insert := app.NonconcurrentDB().Insert(record.Collection().Name, data)
+	_, err = instrumentQuery(app, record.Collection().Name, insert, false).Execute()
+	if err != nil {
+		return err
+	}
+	record.MarkAsNotNew()
+	return nil
+}
+
+// updateRecords applies patch to the records with the given ids with plain
+// UPDATE statements and returns the number of updated rows.
+func updateRecords(app core.App, collection *core.Collection, ids []string, patch map[string]any) (int, error) {
+	// normalize the values the same way records do
+	record := core.NewRecord(collection)
+	record.Load(patch)
+	exported, err := record.DBExport(app)
+	if err != nil {
+		return 0, err
+	}
+
+	data := dbx.Params{}
+	for name := range patch {
+		if name == core.FieldNameId || collection.Fields.GetByName(name) == nil {
+			return 0, fmt.Errorf("unknown or read-only field %q", name)
+		}
+		data[name] = exported[name]
+	}
+	now := types.NowDateTime()
+	for _, field := range collection.Fields {
+		if autodate, ok := field.(*core.AutodateField); ok && autodate.OnUpdate {
+			if _, ok := patch[autodate.Name]; !ok {
+				data[autodate.Name] = now.String()
+			}
+		}
+	}
+
+	return execByIds(ids, func(chunk []any) *dbx.Query {
+		q := app.NonconcurrentDB().Update(collection.Name, data, dbx.In("id", chunk...))
+		return instrumentQuery(app, collection.Name, q, false)
+	})
+}
+
+// deleteRecords deletes the records with the given ids with plain DELETE
+// statements and returns the number of deleted rows.
+func deleteRecords(app core.App, collection *core.Collection, ids []string) (int, error) {
+	return execByIds(ids, func(chunk []any) *dbx.Query {
+		q := app.NonconcurrentDB().Delete(collection.Name, dbx.In("id", chunk...))
+		return instrumentQuery(app, collection.Name, q, false)
+	})
+}
+
+// execByIds executes the statements built for consecutive chunks of ids
+// and returns the total number of affected rows.
+func execByIds(ids []string, build func(chunk []any) *dbx.Query) (int, error) {
+	affected := 0
+	for start := 0; start < len(ids); start += bulkIdChunkSize {
+		end := min(start+bulkIdChunkSize, len(ids))
+		chunk := make([]any, 0, end-start)
+		for _, id := range ids[start:end] {
+			chunk = append(chunk, id)
+		}
+
+		res, err := build(chunk).Execute()
+		if err != nil {
+			return affected, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return affected, err
+		}
+		affected += int(n)
+	}
+	return affected, nil
+}
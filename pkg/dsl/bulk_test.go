@@ -0,0 +1,139 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCreateMany(t *testing.T) {
+	for _, skipHooks := range []bool{false, true} {
+		app := newTestApp(t)
+		c := Collection(app, "products")
+
+		var hooked int
+		app.OnRecordCreate("products").BindFunc(func(e *core.RecordEvent) error {
+			hooked++
+			return e.Next()
+		})
+
+		result, err := c.CreateMany([]map[string]any{
+			{"name": "a", "price": 1},
+			{"name": "b", "price": 2, "status": "active"},
+		}, BulkOptions{SkipHooks: skipHooks})
+		if err != nil {
+			t.Fatalf("[skipHooks %v] Failed to create records: %v", skipHooks, err)
+		}
+		if result.Affected != 2 || len(result.Errors) != 0 {
+			t.Errorf("[skipHooks %v] Expected 2 affected and no errors, got %d and %v", skipHooks, result.Affected, result.Errors)
+		}
+		if expected := map[bool]int{false: 2, true: 0}[skipHooks]; hooked != expected {
+			t.Errorf("[skipHooks %v] Expected %d hook calls, got %d", skipHooks, expected, hooked)
+		}
+
+		records, err := c.List(*Query("").Sort("name"))
+		if err != nil {
+			t.Fatalf("[skipHooks %v] Failed to list records: %v", skipHooks, err)
+		}
+		if names := recordNames(records); names != "a,b" {
+			t.Errorf("[skipHooks %v] Expected a,b, got %s", skipHooks, names)
+		}
+		if records[1].GetString("status") != "active" || records[1].GetDateTime("created").IsZero() {
+			t.Errorf("[skipHooks %v] Expected status and created to be set, got %v", skipHooks, records[1].FieldsData())
+		}
+	}
+}
+
+func TestCreateManyErrors(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+	items := []map[string]any{
+		{"name": "a"},
+		{"name": "b", "status": "invalid"},
+		{"name": "c"},
+	}
+
+	result, err := c.CreateMany(items)
+	var itemErr *BulkItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 {
+		t.Fatalf("Expected a BulkItemError for item 1, got %v", err)
+	}
+	if result.Affected != 0 || len(result.Errors) != 1 {
+		t.Errorf("Expected only the error report, got %+v", result)
+	}
+	if total := mustCount(t, app, "products"); total != 0 {
+		t.Errorf("Expected everything to be rolled back, got %d records", total)
+	}
+
+	result, err = c.CreateMany(items, BulkOptions{ContinueOnError: true})
+	if err != nil {
+		t.Fatalf("Expected no error with ContinueOnError, got %v", err)
+	}
+	if result.Affected != 2 || len(result.Records) != 2 || len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Errorf("Expected 2 created and item 1 failed, got %+v", result)
+	}
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Errorf("Expected 2 records, got %d", total)
+	}
+}
+
+func TestUpdateWhere(t *testing.T) {
+	for _, skipHooks := range []bool{false, true} {
+		app := newTestApp(t)
+		c := Collection(app, "products")
+
+		for _, name := range []string{"a", "b", "c"} {
+			mustCreate(t, app, "products", map[string]any{"name": name, "status": "draft"})
+		}
+		mustCreate(t, app, "products", map[string]any{"name": "d", "status": "active"})
+
+		result, err := c.UpdateWhere(*Where(Eq("status", "draft")), map[string]any{"status": "archived", "price": 5}, BulkOptions{SkipHooks: skipHooks})
+		if err != nil {
+			t.Fatalf("[skipHooks %v] Failed to update records: %v", skipHooks, err)
+		}
+		if result.Affected != 3 {
+			t.Errorf("[skipHooks %v] Expected 3 affected, got %d", skipHooks, result.Affected)
+		}
+
+		records, err := c.List(*Where(Eq("status", "archived"), Eq("price", 5)).Sort("name"))
+		if err != nil {
+			t.Fatalf("[skipHooks %v] Failed to list records: %v", skipHooks, err)
+		}
+		if names := recordNames(records); names != "a,b,c" {
+			t.Errorf("[skipHooks %v] Expected a,b,c to be updated, got %s", skipHooks, names)
+		}
+	}
+
+	app := newTestApp(t)
+	mustCreate(t, app, "products", map[string]any{"name": "a"})
+	result, err := Collection(app, "products").UpdateWhere(*Query(""), map[string]any{"status": "invalid"})
+	if err == nil || len(result.Errors) != 1 {
+		t.Errorf("Expected a validation error report, got %v and %+v", err, result)
+	}
+	if _, err := Collection(app, "products").UpdateWhere(*Query(""), map[string]any{"missing": 1}, BulkOptions{SkipHooks: true}); err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	for _, skipHooks := range []bool{false, true} {
+		app := newTestApp(t)
+		c := Collection(app, "products")
+
+		for i := 0; i < 5; i++ {
+			mustCreate(t, app, "products", map[string]any{"name": "p", "price": i})
+		}
+
+		result, err := c.DeleteWhere(*Where(Gte("price", 2)), BulkOptions{SkipHooks: skipHooks})
+		if err != nil {
+			t.Fatalf("[skipHooks %v] Failed to delete records: %v", skipHooks, err)
+		}
+		if result.Affected != 3 {
+			t.Errorf("[skipHooks %v] Expected 3 affected, got %d", skipHooks, result.Affected)
+		}
+		if total := mustCount(t, app, "products"); total != 2 {
+			t.Errorf("[skipHooks %v] Expected 2 records left, got %d", skipHooks, total)
+		}
+	}
+}
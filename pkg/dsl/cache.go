@@ -0,0 +1,393 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// queryCacheStoreKey is the app store key of the query cache.
+const queryCacheStoreKey = "dsl.queryCache"
+
+// queryCacheHookId identifies the invalidation hooks of the query cache.
+const queryCacheHookId = "dslQueryCacheInvalidation"
+
+// identifierRegex matches the dotted identifiers of filter and sort
+// expressions, e.g. "author.profile.name" or "@collection.users.email".
+var identifierRegex = regexp.MustCompile(`@?\w+(?:\.\w+)+`)
+
+// CacheStats reports the activity of the query cache of an app.
+type CacheStats struct {
+	Hits          int64 // Number of results served from the cache
+	Misses        int64 // Number of results loaded from the database
+	Invalidations int64 // Number of entries removed because of record changes
+	Entries       int   // Number of cached results, including expired ones
+}
+
+// queryCache holds the cached query results of an app.
+//
+// Every collection has a version that is incremented when one of its
+// records changes. A result is only stored if the versions of the
+// collections it depends on didn't change while it was being loaded, so
+// that a concurrent write can't leave a stale entry behind.
+type queryCache struct {
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry // Cached results by query key
+	versions map[string]uint64      // Versions by collection id
+	epoch    uint64                 // Incremented when the whole cache is cleared
+	stats    CacheStats             // Hit, miss and invalidation counters
+}
+
+// cacheEntry is a cached query result.
+type cacheEntry struct {
+	value   any       // The cached result
+	expires time.Time // Expiration time
+	deps    []string  // Ids of the collections the result depends on
+}
+
+// cacheSnapshot records the collection versions a result is loaded with.
+type cacheSnapshot struct {
+	epoch    uint64
+	versions []uint64
+}
+
+// Cache enables caching of the query results for ttl.
+//
+// Results of List, First and ListPage are cached per collection, filter,
+// parameters, sort, pagination, expand, fields and preloads. Entries are
+// invalidated when a record of the collection, or of a collection used by
+// the filter, sort, expand or preload relations, is created, updated or
+// deleted, and when a collection schema changes. Cached records are
+// cloned, so they can be modified by the caller. Queries run within a
+// transaction bypass the cache.
+//
+// Example:
+//
+//	query := dsl.Query("status = 'active'").Sort("-created").Expand("category").Cache(time.Minute)
+//	records, err := dsl.Collection(app, "products").List(*query)
+//
+//	stats := dsl.QueryCacheStats(app)
+func (q *QueryBuilder) Cache(ttl time.Duration) *QueryBuilder {
+	q.cacheTTL = ttl
+	return q
+}
+
+// QueryCacheStats returns the query cache statistics of app.
+//
+// Example:
+//
+//	stats := dsl.QueryCacheStats(app)
+//	log.Printf("query cache: %d hits, %d misses", stats.Hits, stats.Misses)
+func QueryCacheStats(app core.App) CacheStats {
+	qc := getQueryCache(app)
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	stats := qc.stats
+	stats.Entries = len(qc.entries)
+	return stats
+}
+
+// ClearQueryCache removes all the cached query results of app.
+//
+// Writes that bypass the record hooks, e.g. raw SQL statements, should be
+// followed by a call to ClearQueryCache.
+func ClearQueryCache(app core.App) {
+	getQueryCache(app).clear()
+}
+
+// getQueryCache returns the query cache of app, creating it and binding
+// its invalidation hooks on first use.
+func getQueryCache(app core.App) *queryCache {
+	return app.Store().GetOrSet(queryCacheStoreKey, func() any {
+		qc := &queryCache{
+			entries:  map[string]*cacheEntry{},
+			versions: map[string]uint64{},
+		}
+
+		invalidate := func(e *core.RecordEvent) error {
+			qc.invalidate(e.Record.Collection().Id)
+			return e.Next()
+		}
+		app.OnRecordAfterCreateSuccess().Bind(&hook.Handler[*core.RecordEvent]{Id: queryCacheHookId, Func: invalidate})
+		app.OnRecordAfterUpdateSuccess().Bind(&hook.Handler[*core.RecordEvent]{Id: queryCacheHookId, Func: invalidate})
+		app.OnRecordAfterDeleteSuccess().Bind(&hook.Handler[*core.RecordEvent]{Id: queryCacheHookId, Func: invalidate})
+
+		clearAll := func(e *core.CollectionEvent) error {
+			qc.clear()
+			return e.Next()
+		}
+		app.OnCollectionAfterUpdateSuccess().Bind(&hook.Handler[*core.CollectionEvent]{Id: queryCacheHookId, Func: clearAll})
+		app.OnCollectionAfterDeleteSuccess().Bind(&hook.Handler[*core.CollectionEvent]{Id: queryCacheHookId, Func: clearAll})
+
+		return qc
+	}).(*queryCache)
+}
+
+// invalidateQueryCache invalidates the cached results depending on the
+// collection with the given name or id, if app has a query cache.
+func invalidateQueryCache(app core.App, collection string) {
+	qc, ok := app.Store().Get(queryCacheStoreKey).(*queryCache)
+	if !ok {
+		return
+	}
+	c, err := app.FindCachedCollectionByNameOrId(collection)
+	if err != nil {
+		qc.clear()
+		return
+	}
+	qc.invalidate(c.Id)
+}
+
+// get returns the cached result of key, if any and not expired.
+func (qc *queryCache) get(key string) (any, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	entry, ok := qc.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(qc.entries, key)
+		ok = false
+	}
+	if !ok {
+		qc.stats.Misses++
+		return nil, false
+	}
+	qc.stats.Hits++
+	return entry.value, true
+}
+
+// snapshot returns the current versions of the deps collections.
+func (qc *queryCache) snapshot(deps []string) cacheSnapshot {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	s := cacheSnapshot{epoch: qc.epoch, versions: make([]uint64, len(deps))}
+	for i, dep := range deps {
+		s.versions[i] = qc.versions[dep]
+	}
+	return s
+}
+
+// set stores the result of key unless one of the deps collections changed
+// since the snapshot was taken.
+func (qc *queryCache) set(key string, value any, ttl time.Duration, deps []string, s cacheSnapshot) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	if qc.epoch != s.epoch {
+		return
+	}
+	for i, dep := range deps {
+		if qc.versions[dep] != s.versions[i] {
+			return
+		}
+	}
+
+	now := time.Now()
+	for k, entry := range qc.entries {
+		if now.After(entry.expires) {
+			delete(qc.entries, k)
+		}
+	}
+	qc.entries[key] = &cacheEntry{value: value, expires: now.Add(ttl), deps: deps}
+}
+
+// invalidate removes the results depending on the collection with the
+// given id.
+func (qc *queryCache) invalidate(collectionId string) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.versions[collectionId]++
+	for key, entry := range qc.entries {
+		for _, dep := range entry.deps {
+			if dep == collectionId {
+				delete(qc.entries, key)
+				qc.stats.Invalidations++
+				break
+			}
+		}
+	}
+}
+
+// clear removes all the cached results.
+func (qc *queryCache) clear() {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.epoch++
+	qc.entries = map[string]*cacheEntry{}
+}
+
+// cachedQuery returns the cached result of the kind operation for query,
+// or calls load with the cache disabled and caches its result.
+//
+// Cached values are never handed out directly, the callers get copies
+// made with clone.
+func cachedQuery[T any](c *CollectionQueryBuilder, kind string, query QueryBuilder, params []dbx.Params, load func(query QueryBuilder) (T, error), clone func(T) T) (T, error) {
+	ttl := query.cacheTTL
+	query.cacheTTL = 0
+	if query.err != nil || c.requestInfo != nil {
+		// the results of rule-aware builders depend on the request
+		return load(query)
+	}
+	if c.app.IsTransactional() {
+		// a transaction sees its own uncommitted writes, which must neither
+		// be cached (they may be rolled back) nor hidden by cached results
+		return load(query)
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return load(query)
+	}
+
+	qc := getQueryCache(c.app)
+	key := fmt.Sprintf("%s|%s|%d|%s", kind, collection.Id, c.scope, query.cacheKey(params))
+	if value, ok := qc.get(key); ok {
+		return clone(value.(T)), nil
+	}
+
+	deps := cacheDeps(c.app, collection, query)
+	snapshot := qc.snapshot(deps)
+	value, err := load(query)
+	if err != nil {
+		return value, err
+	}
+	qc.set(key, value, ttl, deps, snapshot)
+	return clone(value), nil
+}
+
+// cacheKey serializes the settings of the query that affect its results.
+func (q QueryBuilder) cacheKey(params []dbx.Params) string {
+	var b strings.Builder
+
+	merged := q.mergeParams(params)
+	encodedParams := []byte("{}")
+	if len(merged) > 0 {
+		encoded, err := json.Marshal(merged[0]) // map keys are sorted
+		if err != nil {
+			encoded = []byte(fmt.Sprintf("%#v", merged[0]))
+		}
+		encodedParams = encoded
+	}
+
+	fmt.Fprintf(&b, "filter=%q params=%s page=%d perPage=%d sort=%q expand=%q fields=%q skipTotal=%t cursor=%q before=%t search=%q noHidden=%t",
+		q.filter, encodedParams, q.page, q.perPage, q.sort, q.expand, q.fields, q.skipTotal, q.cursor, q.cursorBefore, q.search, q.noHiddenFields)
+	for _, p := range q.preloads {
+		options := ""
+		if p.options != nil {
+			options = p.options.cacheKey(nil)
+		}
+		fmt.Fprintf(&b, " preload=%q(%s)", p.path, options)
+	}
+	return b.String()
+}
+
+// cacheDeps returns the ids of the collections the results of query on
+// collection depend on: the collection itself and the collections reached
+// by the relations of the filter, sort, expand and preloads.
+func cacheDeps(app core.App, collection *core.Collection, query QueryBuilder) []string {
+	deps := map[string]bool{collection.Id: true}
+	addQueryDeps(app, collection, query, deps)
+
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// addQueryDeps adds the collections used by query on collection to deps.
+func addQueryDeps(app core.App, collection *core.Collection, query QueryBuilder, deps map[string]bool) {
+	for _, identifier := range identifierRegex.FindAllString(query.filter+" "+query.sort, -1) {
+		parts := strings.Split(identifier, ".")
+		if parts[0] != "@collection" {
+			addRelationDeps(app, collection, parts, deps)
+			continue
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		if other, err := app.FindCachedCollectionByNameOrId(parts[1]); err == nil {
+			deps[other.Id] = true
+			addRelationDeps(app, other, parts[2:], deps)
+		}
+	}
+	for _, path := range splitExpand(query.expand) {
+		addRelationDeps(app, collection, strings.Split(path, "."), deps)
+	}
+	for _, p := range query.preloads {
+		last := addRelationDeps(app, collection, strings.Split(p.path, "."), deps)
+		if last != nil && p.options != nil {
+			addQueryDeps(app, last, *p.options, deps)
+		}
+	}
+}
+
+// addRelationDeps walks the relation path from collection, adds the
+// collections it reaches to deps and returns the last one, or nil if the
+// path doesn't end with a relation.
+func addRelationDeps(app core.App, collection *core.Collection, path []string, deps map[string]bool) *core.Collection {
+	current := collection
+	for _, name := range path {
+		var next *core.Collection
+		if field, ok := current.Fields.GetByName(name).(*core.RelationField); ok {
+			next, _ = app.FindCachedCollectionByNameOrId(field.CollectionId)
+		} else if match := backRelationRegex.FindStringSubmatch(name); match != nil {
+			next, _ = app.FindCachedCollectionByNameOrId(match[1])
+		}
+		if next == nil {
+			return nil
+		}
+		deps[next.Id] = true
+		current = next
+	}
+	return current
+}
+
+// cloneRecords returns deep copies of records, including their expanded
+// relations.
+func cloneRecords(records []*core.Record) []*core.Record {
+	seen := map[*core.Record]*core.Record{}
+	clones := make([]*core.Record, len(records))
+	for i, record := range records {
+		clones[i] = cloneRecord(record, seen)
+	}
+	return clones
+}
+
+// cloneRecord returns a deep copy of record. Records shared by several
+// expands are copied once.
+func cloneRecord(record *core.Record, seen map[*core.Record]*core.Record) *core.Record {
+	if record == nil {
+		return nil
+	}
+	if clone, ok := seen[record]; ok {
+		return clone
+	}
+	clone := record.Clone()
+	seen[record] = clone
+
+	expand := record.Expand()
+	if len(expand) == 0 {
+		return clone
+	}
+	for name, value := range expand {
+		switch v := value.(type) {
+		case *core.Record:
+			expand[name] = cloneRecord(v, seen)
+		case []*core.Record:
+			items := make([]*core.Record, len(v))
+			for i, r := range v {
+				items[i] = cloneRecord(r, seen)
+			}
+			expand[name] = items
+		}
+	}
+	clone.SetExpand(expand)
+	return clone
+}
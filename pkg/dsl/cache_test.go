@@ -0,0 +1,231 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCacheHitsAndInvalidation(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+	saw := mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20})
+
+	list := func() string {
+		records, err := c.List(*Query("price > {:min}").Sort("name").Cache(time.Minute), dbx.Params{"min": 5})
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		return recordNames(records)
+	}
+
+	if names := list(); names != "hammer,saw" {
+		t.Fatalf("Expected hammer,saw, got %s", names)
+	}
+	if names := list(); names != "hammer,saw" {
+		t.Fatalf("Expected hammer,saw from the cache, got %s", names)
+	}
+	stats := QueryCacheStats(app)
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 entry, got %+v", stats)
+	}
+
+	// different parameters are cached separately
+	if _, err := c.List(*Query("price > {:min}").Sort("name").Cache(time.Minute), dbx.Params{"min": 15}); err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if stats := QueryCacheStats(app); stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("Expected 2 misses and 2 entries, got %+v", stats)
+	}
+
+	// any record change of the collection invalidates its entries
+	if _, err := c.Update(saw.Id, map[string]any{"name": "jigsaw"}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if stats := QueryCacheStats(app); stats.Entries != 0 || stats.Invalidations != 2 {
+		t.Errorf("Expected the 2 entries to be invalidated, got %+v", stats)
+	}
+	if names := list(); names != "hammer,jigsaw" {
+		t.Errorf("Expected hammer,jigsaw after the update, got %s", names)
+	}
+
+	mustCreate(t, app, "products", map[string]any{"name": "axe", "price": 30})
+	if names := list(); names != "axe,hammer,jigsaw" {
+		t.Errorf("Expected axe,hammer,jigsaw after the create, got %s", names)
+	}
+
+	if err := c.Delete(saw.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if names := list(); names != "axe,hammer" {
+		t.Errorf("Expected axe,hammer after the delete, got %s", names)
+	}
+
+	// changes in a transaction
+	err := Transaction(app, func(tx *Tx) error {
+		_, err := tx.Collection("products").Create(map[string]any{"name": "drill", "price": 40})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to run transaction: %v", err)
+	}
+	if names := list(); names != "axe,drill,hammer" {
+		t.Errorf("Expected axe,drill,hammer after the transaction, got %s", names)
+	}
+
+	// statements without hooks
+	if _, err := c.UpdateWhere(*Query("name = 'drill'"), map[string]any{"price": 1}, BulkOptions{SkipHooks: true}); err != nil {
+		t.Fatalf("Failed to update records: %v", err)
+	}
+	if names := list(); names != "axe,hammer" {
+		t.Errorf("Expected axe,hammer after the bulk update, got %s", names)
+	}
+}
+
+func TestCacheRelationInvalidation(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "category": tools.Id})
+
+	categoryName := func(query *QueryBuilder) string {
+		record, err := c.First(*query.Cache(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to fetch record: %v", err)
+		}
+		return record.ExpandedOne("category").GetString("name")
+	}
+	expanded := func() *QueryBuilder { return Query("").Expand("category") }
+	preloaded := func() *QueryBuilder { return Query("").Preload("category") }
+
+	if name := categoryName(expanded()); name != "tools" {
+		t.Fatalf("Expected tools, got %s", name)
+	}
+	if name := categoryName(preloaded()); name != "tools" {
+		t.Fatalf("Expected tools, got %s", name)
+	}
+
+	// an unrelated collection doesn't invalidate the entries
+	notes := core.NewBaseCollection("notes")
+	notes.Fields.Add(&core.TextField{Name: "text"})
+	if err := app.Save(notes); err != nil {
+		t.Fatalf("Failed to create notes collection: %v", err)
+	}
+	mustCreate(t, app, "notes", map[string]any{"text": "hello"})
+	if stats := QueryCacheStats(app); stats.Entries != 2 {
+		t.Errorf("Expected 2 entries, got %+v", stats)
+	}
+
+	if _, err := Collection(app, "categories").Update(tools.Id, map[string]any{"name": "hand tools"}); err != nil {
+		t.Fatalf("Failed to update category: %v", err)
+	}
+	if name := categoryName(expanded()); name != "hand tools" {
+		t.Errorf("Expected the expanded category to be invalidated, got %s", name)
+	}
+	if name := categoryName(preloaded()); name != "hand tools" {
+		t.Errorf("Expected the preloaded category to be invalidated, got %s", name)
+	}
+
+	// relations used by the filter
+	filtered := func() string {
+		records, err := c.List(*Query("category.name = 'garden'").Cache(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		return recordNames(records)
+	}
+	if names := filtered(); names != "" {
+		t.Fatalf("Expected no records, got %s", names)
+	}
+	if _, err := Collection(app, "categories").Update(tools.Id, map[string]any{"name": "garden"}); err != nil {
+		t.Fatalf("Failed to update category: %v", err)
+	}
+	if names := filtered(); names != "hammer" {
+		t.Errorf("Expected hammer after the category rename, got %s", names)
+	}
+}
+
+func TestCacheCopiesAndExpiration(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+
+	result, err := c.ListPage(*Query("").Cache(50 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	result.Items[0].Set("name", "modified")
+
+	result, err = c.ListPage(*Query("").Cache(50 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if name := result.Items[0].GetString("name"); name != "hammer" {
+		t.Errorf("Expected the cached record to be unaffected by changes of the caller, got %s", name)
+	}
+	if result.TotalItems != 1 {
+		t.Errorf("Expected 1 total item, got %d", result.TotalItems)
+	}
+	if stats := QueryCacheStats(app); stats.Hits != 1 {
+		t.Errorf("Expected 1 hit, got %+v", stats)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.ListPage(*Query("").Cache(50 * time.Millisecond)); err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if stats := QueryCacheStats(app); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected the expired entry to be reloaded, got %+v", stats)
+	}
+
+	ClearQueryCache(app)
+	if stats := QueryCacheStats(app); stats.Entries != 0 {
+		t.Errorf("Expected no entries after ClearQueryCache, got %+v", stats)
+	}
+
+	// queries without Cache are never cached
+	if _, err := c.List(*Query("")); err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if stats := QueryCacheStats(app); stats.Entries != 0 || stats.Misses != 2 {
+		t.Errorf("Expected uncached queries to be ignored, got %+v", stats)
+	}
+}
+
+func TestCacheBypassedInTransaction(t *testing.T) {
+	app := newTestApp(t)
+
+	errRollback := errors.New("rollback")
+	err := app.RunInTransaction(func(txApp core.App) error {
+		mustCreate(t, txApp, "products", map[string]any{"name": "hammer"})
+		records, err := Collection(txApp, "products").List(*Query("").Cache(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		if len(records) != 1 {
+			t.Errorf("Expected the uncommitted record within the transaction, got %d", len(records))
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Expected the rollback error, got %v", err)
+	}
+
+	records, err := Collection(app, "products").List(*Query("").Cache(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected the rolled back record not to be cached, got %d records", len(records))
+	}
+	if stats := QueryCacheStats(app); stats.Misses != 1 {
+		t.Errorf("Expected only the query outside the transaction to use the cache, got %+v", stats)
+	}
+}
@@ -0,0 +1,133 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// WithContext returns a copy of the builder whose database queries and
+// writes run with ctx.
+//
+// When ctx is canceled or its deadline expires, running queries are
+// interrupted and the operations return an error matching ctx.Err(), i.e.
+// context.Canceled or context.DeadlineExceeded, with errors.Is.
+// Operations started with an already done ctx fail without querying the
+// database.
+//
+// Example:
+//
+//	func (s *ProductService) ListProducts(e *core.RequestEvent) error {
+//	    records, err := dsl.Collection(s.app, "products").
+//	        WithContext(e.Request.Context()).
+//	        List(*dsl.Query("status = 'active'"))
+//	    if errors.Is(err, context.Canceled) {
+//	        return nil // the client went away
+//	    }
+//	    ...
+//	}
+func (c *CollectionQueryBuilder) WithContext(ctx context.Context) *CollectionQueryBuilder {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// context returns the context of the builder, or context.Background.
+func (c *CollectionQueryBuilder) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// checkContext returns the deferred error of AsRequest, or the error of
+// the builder context if it is done.
+func (c *CollectionQueryBuilder) checkContext() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Err()
+}
+
+// contextError wraps err with the error of the builder context when the
+// context is done, so that an interrupted query can be told apart with
+// errors.Is(err, context.Canceled) or context.DeadlineExceeded.
+func (c *CollectionQueryBuilder) contextError(err error) error {
+	if err == nil || c.ctx == nil {
+		return err
+	}
+	ctxErr := c.ctx.Err()
+	if ctxErr == nil || errors.Is(err, ctxErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctxErr, err)
+}
+
+// withQueryContext sets the builder context on q.
+func (c *CollectionQueryBuilder) withQueryContext(q *dbx.SelectQuery) *dbx.SelectQuery {
+	if c.ctx != nil {
+		q.WithContext(c.ctx)
+	}
+	return q
+}
+
+// findRecordById loads a record of the collection by id with the builder
+// context.
+func (c *CollectionQueryBuilder) findRecordById(id string) (*core.Record, error) {
+	if err := c.checkContext(); err != nil {
+		return nil, err
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	record, err := c.app.FindRecordById(collection, id, func(q *dbx.SelectQuery) error {
+		c.withQueryContext(q)
+		instrument(c.app, collection.Name, q, false)
+		return nil
+	})
+	return record, c.contextError(notFoundError(err))
+}
+
+// save saves record with the regular validation and hooks and the builder
+// context, checking the CreateRule or UpdateRule of a rule-aware builder.
+func (c *CollectionQueryBuilder) save(record *core.Record) error {
+	if err := c.checkContext(); err != nil {
+		return err
+	}
+	if c.rules() != nil {
+		if record.IsNew() {
+			return c.createWithRules(record)
+		}
+		if err := c.checkRule(record, record.Collection().UpdateRule, "update"); err != nil {
+			return err
+		}
+	}
+	err := c.app.SaveWithContext(c.context(), record)
+	return c.contextError(saveError(record.Collection().Name, err))
+}
+
+// delete deletes record with the regular hooks and the builder context,
+// checking the DeleteRule of a rule-aware builder.
+func (c *CollectionQueryBuilder) delete(record *core.Record) error {
+	if err := c.checkContext(); err != nil {
+		return err
+	}
+	if err := c.checkRule(record, record.Collection().DeleteRule, "delete"); err != nil {
+		return err
+	}
+	err := c.app.DeleteWithContext(c.context(), record)
+	return c.contextError(saveError(record.Collection().Name, err))
+}
+
+// WithContext returns a copy of the repository whose operations run with
+// ctx. See CollectionQueryBuilder.WithContext.
+func (r *Repo[T]) WithContext(ctx context.Context) *Repo[T] {
+	return &Repo[T]{collection: r.collection.WithContext(ctx)}
+}
@@ -0,0 +1,136 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestWithContextCanceled(t *testing.T) {
+	app := newTestApp(t)
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := Collection(app, "products").WithContext(ctx)
+
+	checks := map[string]func() error{
+		"One": func() error {
+			_, err := c.One(hammer.Id)
+			return err
+		},
+		"First": func() error {
+			_, err := c.First(*Query(""))
+			return err
+		},
+		"List": func() error {
+			_, err := c.List(*Query("price > 5"))
+			return err
+		},
+		"ListPage": func() error {
+			_, err := c.ListPage(*Query(""))
+			return err
+		},
+		"ListCursor": func() error {
+			_, err := c.ListCursor(*Query(""))
+			return err
+		},
+		"Count": func() error {
+			_, err := c.Count("")
+			return err
+		},
+		"Sum": func() error {
+			_, err := c.Sum("price", *Query(""))
+			return err
+		},
+		"Create": func() error {
+			_, err := c.Create(map[string]any{"name": "saw"})
+			return err
+		},
+		"Update": func() error {
+			_, err := c.Update(hammer.Id, map[string]any{"name": "mallet"})
+			return err
+		},
+		"Delete": func() error {
+			return c.Delete(hammer.Id)
+		},
+		"UpdateWhere": func() error {
+			_, err := c.UpdateWhere(*Query(""), map[string]any{"price": 1})
+			return err
+		},
+		"Each": func() error {
+			return Collection(app, "products").Each(ctx, *Query(""), func(record *core.Record) error { return nil })
+		},
+	}
+	for name, check := range checks {
+		if err := check(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+
+	// nothing was written
+	record, err := Collection(app, "products").One(hammer.Id)
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if record.GetString("name") != "hammer" || record.GetInt("price") != 10 {
+		t.Errorf("Expected the record to be unchanged, got %s %d", record.GetString("name"), record.GetInt("price"))
+	}
+	if count, _ := Collection(app, "products").Count(""); count != 1 {
+		t.Errorf("Expected 1 record, got %d", count)
+	}
+}
+
+func TestWithContextDeadline(t *testing.T) {
+	app := newTestApp(t)
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	c := Collection(app, "products").WithContext(ctx)
+	if _, err := c.List(*Query("")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := c.List(*Query("")); errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the deadline error to be distinct from context.Canceled, got %v", err)
+	}
+
+	type product struct {
+		Name string `pb:"name"`
+	}
+	repo := NewRepo[product](app, "products").WithContext(ctx)
+	if _, err := repo.List(*Query("")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded from the repository, got %v", err)
+	}
+
+	// the original builder is unaffected
+	if _, err := Collection(app, "products").List(*Query("")); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestWithContextTransaction(t *testing.T) {
+	app := newTestApp(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Transaction(app, func(tx *Tx) error {
+		products := tx.Collection("products").WithContext(ctx)
+		if _, err := products.Create(map[string]any{"name": "hammer"}); err != nil {
+			return err
+		}
+		cancel()
+		_, err := products.Create(map[string]any{"name": "saw"})
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if count, _ := Collection(app, "products").Count(""); count != 0 {
+		t.Errorf("Expected the transaction to be rolled back, got %d records", count)
+	}
+}
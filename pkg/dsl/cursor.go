@@ -0,0 +1,420 @@
+package dsl
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor is malformed, was
+// tampered with, or was issued for a different collection or sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var (
+	cursorSecretMu sync.RWMutex
+	cursorSecret   = newCursorSecret()
+)
+
+func newCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed to generate cursor secret: %v", err))
+	}
+	return secret
+}
+
+// SetCursorSecret sets the key used to sign pagination cursors.
+//
+// By default a random key is generated on startup, which means cursors
+// become invalid after a restart and aren't accepted by other instances.
+// Set a stable secret to make cursors survive restarts and work behind a
+// load balancer.
+//
+// Example:
+//
+//	dsl.SetCursorSecret([]byte(os.Getenv("CURSOR_SECRET")))
+func SetCursorSecret(secret []byte) {
+	cursorSecretMu.Lock()
+	defer cursorSecretMu.Unlock()
+	cursorSecret = append([]byte{}, secret...)
+}
+
+// CursorResult is a single page of records fetched with keyset (cursor)
+// pagination.
+//
+// NextCursor and PrevCursor are set whenever the page isn't empty, so that
+// a feed can also poll for items added after its last page. HasNext and
+// HasPrev report whether more items were known to exist at query time.
+type CursorResult[T any] struct {
+	Items      []T    `json:"items"`                // The records of the current page
+	NextCursor string `json:"nextCursor,omitempty"` // Pass to After to fetch the next page
+	PrevCursor string `json:"prevCursor,omitempty"` // Pass to Before to fetch the previous page
+	HasNext    bool   `json:"hasNext"`              // Whether there are more items after this page
+	HasPrev    bool   `json:"hasPrev"`              // Whether there are more items before this page
+}
+
+// After sets the cursor after which ListCursor starts fetching items.
+//
+// The cursor must be a NextCursor (or PrevCursor) returned by ListCursor
+// for the same collection and sort expression.
+//
+// Example:
+//
+//	query := dsl.Query("").Sort("-created").Page(0, 20).After(result.NextCursor)
+func (q *QueryBuilder) After(cursor string) *QueryBuilder {
+	q.cursor = cursor
+	q.cursorBefore = false
+	return q
+}
+
+// Before sets the cursor before which ListCursor fetches items, which is
+// used to navigate backwards.
+//
+// Example:
+//
+//	query := dsl.Query("").Sort("-created").Page(0, 20).Before(result.PrevCursor)
+func (q *QueryBuilder) Before(cursor string) *QueryBuilder {
+	q.cursor = cursor
+	q.cursorBefore = true
+	return q
+}
+
+// cursorKey is a single resolved sort key used for keyset pagination.
+type cursorKey struct {
+	name string // The sort field name (e.g. "author.name")
+	expr string // The resolved SQL column expression
+	desc bool   // Whether the key is sorted in descending order
+}
+
+// cursorKeys resolves the sort expression into keyset pagination keys,
+// appending "id" as a unique tie-breaker when it isn't already sorted on.
+//
+// It also returns a signature of the sort that is embedded in the cursors.
+func (s *recordSelect) cursorKeys(sort string) ([]cursorKey, string, error) {
+	var keys []cursorKey
+	hasId := false
+	for _, field := range search.ParseSortFromString(sort) {
+		if field.Name == "" {
+			continue
+		}
+		key := cursorKey{name: field.Name, desc: field.Direction == search.SortDesc}
+		switch field.Name {
+		case "@random":
+			return nil, "", errors.New("random sort can't be used with cursor pagination")
+		case "@rowid":
+			key.expr = "[[" + s.collection.Name + "._rowid_]]"
+		default:
+			result, err := s.resolver.Resolve(field.Name)
+			if err != nil || len(result.Params) > 0 || result.Identifier == "" || strings.ToLower(result.Identifier) == "null" {
+				return nil, "", fmt.Errorf("invalid sort field %q", field.Name)
+			}
+			key.expr = result.Identifier
+		}
+		if field.Name == core.FieldNameId {
+			hasId = true
+		}
+		keys = append(keys, key)
+	}
+	if !hasId {
+		keys = append(keys, cursorKey{name: core.FieldNameId, expr: "[[" + s.collection.Name + ".id]]"})
+	}
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.name
+		if key.desc {
+			parts[i] = "-" + key.name
+		}
+	}
+	return keys, strings.Join(parts, ","), nil
+}
+
+// keysetExpr builds the condition matching the rows located after the
+// cursor values in the traversal order.
+//
+// SQLite sorts NULLs first in ascending order (and therefore last in
+// descending order), which is taken into account for NULL key values.
+func keysetExpr(keys []cursorKey, values []any, backward bool) dbx.Expression {
+	params := dbx.Params{}
+	placeholders := make([]string, len(keys))
+	for i, value := range values {
+		if value != nil {
+			name := fmt.Sprintf("dslc%d", i)
+			params[name] = value
+			placeholders[i] = "{:" + name + "}"
+		}
+	}
+
+	ors := make([]string, 0, len(keys))
+	for i, key := range keys {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				ands = append(ands, keys[j].expr+" IS NULL")
+			} else {
+				ands = append(ands, keys[j].expr+" = "+placeholders[j])
+			}
+		}
+
+		ascending := key.desc == backward
+		switch {
+		case ascending && values[i] == nil:
+			ands = append(ands, key.expr+" IS NOT NULL")
+		case ascending:
+			ands = append(ands, key.expr+" > "+placeholders[i])
+		case values[i] == nil:
+			continue // nothing sorts after NULL in descending order
+		default:
+			ands = append(ands, "("+key.expr+" < "+placeholders[i]+" OR "+key.expr+" IS NULL)")
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	if len(ors) == 0 {
+		return dbx.NewExp("0 = 1")
+	}
+	return dbx.NewExp(strings.Join(ors, " OR "), params)
+}
+
+// cursorPayload is the signed content of a cursor token.
+type cursorPayload struct {
+	Collection string `json:"c"` // The collection id
+	Sort       string `json:"s"` // The sort signature
+	Values     []any  `json:"v"` // The sort key values of the boundary record
+}
+
+// encodeCursor signs the sort key values of a record into an opaque token.
+func encodeCursor(collection *core.Collection, sort string, values []any) (string, error) {
+	payload, err := json.Marshal(cursorPayload{Collection: collection.Id, Sort: sort, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signCursor(payload)), nil
+}
+
+// decodeCursor verifies a cursor token and returns its sort key values.
+func decodeCursor(token string, collection *core.Collection, sort string, numKeys int) ([]any, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signCursor(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var data cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if data.Collection != collection.Id || data.Sort != sort || len(data.Values) != numKeys {
+		return nil, fmt.Errorf("%w: cursor was issued for a different collection or sort", ErrInvalidCursor)
+	}
+
+	for i, value := range data.Values {
+		if n, ok := value.(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				data.Values[i] = v
+			} else if v, err := n.Float64(); err == nil {
+				data.Values[i] = v
+			} else {
+				return nil, ErrInvalidCursor
+			}
+		}
+	}
+	return data.Values, nil
+}
+
+func signCursor(payload []byte) []byte {
+	cursorSecretMu.RLock()
+	mac := hmac.New(sha256.New, cursorSecret)
+	cursorSecretMu.RUnlock()
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ListCursor retrieves a page of records using keyset (cursor) pagination.
+//
+// Unlike offset pagination, keyset pagination stays fast on deep pages and
+// doesn't skip or repeat rows when records are inserted or deleted between
+// requests. The page size is taken from the perPage value of Page (the
+// page number is ignored) and defaults to 30. Any sort expression accepted
+// by Sort can be used, except "@random"; "id" is appended as a tie-breaker
+// to make the order total.
+//
+// Use After with the returned NextCursor to fetch the following page and
+// Before with PrevCursor to go back.
+//
+// Example:
+//
+//	query := dsl.Query("status = 'published'").Sort("-created").Page(0, 20)
+//	result, err := dsl.Collection(app, "posts").ListCursor(*query)
+//
+//	// next page
+//	result, err = dsl.Collection(app, "posts").ListCursor(*query.After(result.NextCursor))
+func (c *CollectionQueryBuilder) ListCursor(query QueryBuilder, params ...dbx.Params) (*CursorResult[*core.Record], error) {
+	s, err := c.filteredSelect(query, params)
+	if err != nil {
+		return nil, err
+	}
+	keys, sort, err := s.cursorKeys(query.sort)
+	if err != nil {
+		return nil, err
+	}
+
+	backward := query.cursor != "" && query.cursorBefore
+	if query.cursor != "" {
+		values, err := decodeCursor(query.cursor, s.collection, sort, len(keys))
+		if err != nil {
+			return nil, err
+		}
+		s.query.AndWhere(keysetExpr(keys, values, backward))
+	}
+	for _, key := range keys {
+		if key.desc != backward {
+			s.query.AndOrderBy(key.expr + " DESC")
+		} else {
+			s.query.AndOrderBy(key.expr + " ASC")
+		}
+	}
+
+	perPage := query.perPage
+	if perPage <= 0 {
+		perPage = search.DefaultPerPage
+	} else if perPage > search.MaxPerPage {
+		perPage = search.MaxPerPage
+	}
+
+	records := []*core.Record{}
+	if err := s.build().Limit(int64(perPage + 1)).All(&records); err != nil {
+		return nil, c.contextError(err)
+	}
+
+	hasMore := len(records) > perPage
+	if hasMore {
+		records = records[:perPage]
+	}
+	result := &CursorResult[*core.Record]{}
+	if backward {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+		result.HasPrev = hasMore
+		result.HasNext = true
+	} else {
+		result.HasNext = hasMore
+		result.HasPrev = query.cursor != ""
+	}
+
+	if len(records) > 0 {
+		first, last := records[0], records[len(records)-1]
+		values, err := s.cursorValues(keys, first.Id, last.Id)
+		if err != nil {
+			return nil, c.contextError(err)
+		}
+		if result.PrevCursor, err = encodeCursor(s.collection, sort, values[first.Id]); err != nil {
+			return nil, err
+		}
+		if result.NextCursor, err = encodeCursor(s.collection, sort, values[last.Id]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.finishRecords(records, query); err != nil {
+		return nil, err
+	}
+	result.Items = records
+
+	return result, nil
+}
+
+// cursorValues loads the raw sort key values of the records with the given
+// ids, keyed by record id.
+//
+// The values are read with a separate query instead of from the records,
+// so that relation sort keys and SQL type affinities are preserved.
+func (s *recordSelect) cursorValues(keys []cursorKey, ids ...string) (map[string][]any, error) {
+	columns := make([]string, 0, len(keys)+1)
+	columns = append(columns, "[["+s.collection.Name+".id]]")
+	for _, key := range keys {
+		columns = append(columns, key.expr)
+	}
+	idsAny := make([]any, len(ids))
+	for i, id := range ids {
+		idsAny[i] = id
+	}
+
+	q := s.query.Select(columns...).
+		OrderBy( /* reset */ ).
+		Limit(-1).
+		AndWhere(dbx.In(s.collection.Name+".id", idsAny...))
+
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string][]any, len(ids))
+	for rows.Next() {
+		row := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		id, _ := row[0].(string)
+		if _, exists := result[id]; !exists {
+			result[id] = row[1:]
+		}
+	}
+	return result, rows.Err()
+}
+
+// ListCursor retrieves a page of items using keyset (cursor) pagination.
+//
+// See CollectionQueryBuilder.ListCursor for details.
+//
+// Example:
+//
+//	result, err := repo.ListCursor(*dsl.Query("").Sort("-created").Page(0, 20).After(cursor))
+func (r *Repo[T]) ListCursor(query QueryBuilder, params ...dbx.Params) (*CursorResult[T], error) {
+	page, err := r.collection.ListCursor(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.FromRecords(page.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &CursorResult[T]{
+		Items:      items,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		HasNext:    page.HasNext,
+		HasPrev:    page.HasPrev,
+	}, nil
+}
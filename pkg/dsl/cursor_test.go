@@ -0,0 +1,162 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// collectCursorPages walks all pages of query forward and returns the
+// visited record names.
+func collectCursorPages(t *testing.T, c *CollectionQueryBuilder, query *QueryBuilder) []string {
+	t.Helper()
+
+	var names []string
+	for i := 0; i < 100; i++ {
+		result, err := c.ListCursor(*query)
+		if err != nil {
+			t.Fatalf("Failed to list cursor page: %v", err)
+		}
+		for _, record := range result.Items {
+			names = append(names, record.GetString("name"))
+		}
+		if !result.HasNext {
+			return names
+		}
+		query.After(result.NextCursor)
+	}
+	t.Fatal("Cursor pagination didn't terminate")
+	return nil
+}
+
+func TestListCursor(t *testing.T) {
+	app := newTestApp(t)
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	books := mustCreate(t, app, "categories", map[string]any{"name": "books"})
+	for i := 0; i < 10; i++ {
+		data := map[string]any{"name": fmt.Sprintf("p%d", i), "price": i % 3}
+		switch i % 3 {
+		case 0:
+			data["category"] = tools.Id
+		case 1:
+			data["category"] = books.Id
+		}
+		mustCreate(t, app, "products", data)
+	}
+
+	c := Collection(app, "products")
+
+	for _, sort := range []string{"", "-price", "price,-name", "category.name,-price", "-category.name", "@rowid"} {
+		t.Run("sort "+sort, func(t *testing.T) {
+			expectedRecords, err := c.List(*Query("").Sort(strings.Trim(sort+",id", ",")))
+			if err != nil {
+				t.Fatalf("Failed to list records: %v", err)
+			}
+			expected := make([]string, len(expectedRecords))
+			for i, record := range expectedRecords {
+				expected[i] = record.GetString("name")
+			}
+
+			actual := collectCursorPages(t, c, Query("").Sort(sort).Page(0, 3))
+			if strings.Join(actual, ",") != strings.Join(expected, ",") {
+				t.Errorf("Expected order %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestListCursorBackward(t *testing.T) {
+	app := newTestApp(t)
+
+	for i := 0; i < 7; i++ {
+		mustCreate(t, app, "products", map[string]any{"name": fmt.Sprintf("p%d", i), "price": i})
+	}
+
+	c := Collection(app, "products")
+	query := Query("price >= 1").Sort("-price").Page(0, 3)
+
+	first, err := c.ListCursor(*query)
+	if err != nil {
+		t.Fatalf("Failed to list first page: %v", err)
+	}
+	if first.HasPrev || !first.HasNext {
+		t.Errorf("Expected first page to have only next, got prev=%v next=%v", first.HasPrev, first.HasNext)
+	}
+
+	second, err := c.ListCursor(*query.After(first.NextCursor))
+	if err != nil {
+		t.Fatalf("Failed to list second page: %v", err)
+	}
+	if names := recordNames(second.Items); names != "p3,p2,p1" {
+		t.Errorf("Expected second page p3,p2,p1, got %s", names)
+	}
+	if second.HasNext || !second.HasPrev {
+		t.Errorf("Expected second page to have only prev, got prev=%v next=%v", second.HasPrev, second.HasNext)
+	}
+
+	back, err := c.ListCursor(*query.Before(second.PrevCursor))
+	if err != nil {
+		t.Fatalf("Failed to list previous page: %v", err)
+	}
+	if names := recordNames(back.Items); names != "p6,p5,p4" {
+		t.Errorf("Expected previous page p6,p5,p4, got %s", names)
+	}
+	if back.HasPrev || !back.HasNext {
+		t.Errorf("Expected previous page to have only next, got prev=%v next=%v", back.HasPrev, back.HasNext)
+	}
+
+	// records inserted before the cursor don't shift the following page
+	mustCreate(t, app, "products", map[string]any{"name": "p10", "price": 10})
+	again, err := c.ListCursor(*query.After(first.NextCursor))
+	if err != nil {
+		t.Fatalf("Failed to list second page again: %v", err)
+	}
+	if names := recordNames(again.Items); names != "p3,p2,p1" {
+		t.Errorf("Expected p3,p2,p1 after insert, got %s", names)
+	}
+}
+
+func TestListCursorInvalid(t *testing.T) {
+	app := newTestApp(t)
+
+	mustCreate(t, app, "products", map[string]any{"name": "a", "price": 1})
+	mustCreate(t, app, "products", map[string]any{"name": "b", "price": 2})
+
+	c := Collection(app, "products")
+	result, err := c.ListCursor(*Query("").Sort("price").Page(0, 1))
+	if err != nil {
+		t.Fatalf("Failed to list page: %v", err)
+	}
+
+	payload, signature, _ := strings.Cut(result.NextCursor, ".")
+	scenarios := map[string]*QueryBuilder{
+		"garbage":        Query("").Sort("price").After("garbage"),
+		"tampered":       Query("").Sort("price").After(payload + "x." + signature),
+		"different sort": Query("").Sort("-price").After(result.NextCursor),
+	}
+	for name, query := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.ListCursor(*query); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+
+	if _, err := Collection(app, "categories").ListCursor(*Query("").Sort("price").After(result.NextCursor)); err == nil {
+		t.Error("Expected error for a cursor of another collection")
+	}
+
+	if _, err := c.ListCursor(*Query("").Sort("@random")); err == nil {
+		t.Error("Expected error for random sort")
+	}
+}
+
+func recordNames[T interface{ GetString(string) string }](records []T) string {
+	names := make([]string, len(records))
+	for i, record := range records {
+		names[i] = record.GetString("name")
+	}
+	return strings.Join(names, ",")
+}
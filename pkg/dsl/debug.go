@@ -0,0 +1,230 @@
+package dsl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// QueryEvent describes a dsl query executed against the database.
+type QueryEvent struct {
+	Collection string        // The queried collection name
+	SQL        string        // The executed SQL with the parameters inlined
+	Duration   time.Duration // The execution time
+	Caller     string        // The "file:line" of the code that called the dsl method
+	Err        error         // The query error, if any
+}
+
+// slowQueryHook is a hook registered with SetSlowQueryHook.
+type slowQueryHook struct {
+	threshold time.Duration
+	fn        func(e QueryEvent)
+}
+
+// currentSlowQueryHook holds the registered *slowQueryHook, if any.
+var currentSlowQueryHook atomic.Pointer[slowQueryHook]
+
+// packageDir is the source directory of the dsl package, used to find the
+// call site of queries.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// quotedNameRegex matches the dbx quoted table and column names.
+var quotedNameRegex = regexp.MustCompile(`\{\{[^}]+\}\}|\[\[[^\]]+\]\]`)
+
+// SetSlowQueryHook registers fn to be called for every dsl query that runs
+// for threshold or longer, with its SQL, duration, collection and call
+// site. A threshold of 0 reports all the queries and a nil fn removes the
+// hook.
+//
+// The hook covers the statements built by the package: the record, count,
+// aggregate, preload, One and Explain queries and the statements of the
+// bulk operations with SkipHooks. Records saved and deleted with the hooks
+// (Create, Update, Delete, ...) are written by PocketBase and aren't
+// reported, neither are the relations loaded by Expand.
+//
+// The hook is global and called synchronously after the query, so it
+// should be fast and safe for concurrent use.
+//
+// Example:
+//
+//	dsl.SetSlowQueryHook(200*time.Millisecond, func(e dsl.QueryEvent) {
+//	    app.Logger().Warn("slow query",
+//	        "collection", e.Collection,
+//	        "duration", e.Duration,
+//	        "caller", e.Caller,
+//	        "sql", e.SQL,
+//	    )
+//	})
+func SetSlowQueryHook(threshold time.Duration, fn func(e QueryEvent)) {
+	if fn == nil {
+		currentSlowQueryHook.Store(nil)
+		return
+	}
+	currentSlowQueryHook.Store(&slowQueryHook{threshold: threshold, fn: fn})
+}
+
+// Debug logs the SQL of every database query the query is executed with,
+// together with its duration and call site, through the app logger.
+//
+// Example:
+//
+//	records, err := dsl.Collection(app, "products").List(*dsl.Query("category.name = 'tools'").Debug())
+func (q *QueryBuilder) Debug() *QueryBuilder {
+	q.debug = true
+	return q
+}
+
+// ToSQL returns the SQL statement List executes for query, with the dbx
+// placeholders ("{:name}") and the parameters bound to them. The filter
+// values are bound to generated placeholders.
+//
+// Example:
+//
+//	sql, params, err := dsl.Collection(app, "products").ToSQL(*dsl.Query("price > {:min}").Sort("-price"), dbx.Params{"min": 10})
+//	// SELECT DISTINCT `products`.* FROM `products` WHERE `products`.`price` > {:t...} ORDER BY `products`.`price` DESC
+func (c *CollectionQueryBuilder) ToSQL(query QueryBuilder, params ...dbx.Params) (string, dbx.Params, error) {
+	q, _, err := c.listQuery(query, params)
+	if err != nil {
+		return "", nil, err
+	}
+	built := q.Build()
+	return unquoteNames(built.SQL()), built.Params(), nil
+}
+
+// Explain returns the SQLite EXPLAIN QUERY PLAN output for the statement
+// List executes for query, one line per plan step, indented by depth.
+//
+// Example:
+//
+//	plan, err := dsl.Collection(app, "products").Explain(*dsl.Query("category.name = 'tools'"))
+//	// SCAN products
+//	// SEARCH products_category USING INTEGER PRIMARY KEY (rowid=?)
+func (c *CollectionQueryBuilder) Explain(query QueryBuilder, params ...dbx.Params) ([]string, error) {
+	q, collection, err := c.listQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+	built := q.Build()
+
+	explain := c.app.DB().NewQuery("EXPLAIN QUERY PLAN " + built.SQL()).Bind(built.Params())
+	rows, err := instrumentQuery(c.app, collection.Name, explain, query.debug).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := map[int]int{}
+	plan := []string{}
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		depth := 0
+		if d, ok := depths[parent]; ok {
+			depth = d + 1
+		}
+		depths[id] = depth
+		plan = append(plan, strings.Repeat("  ", depth)+detail)
+	}
+	return plan, rows.Err()
+}
+
+// unquoteNames replaces the dbx quoting markers of sql with SQLite quotes.
+func unquoteNames(sql string) string {
+	return quotedNameRegex.ReplaceAllStringFunc(sql, func(match string) string {
+		parts := strings.Split(match[2:len(match)-2], ".")
+		for i, part := range parts {
+			if part != "*" {
+				parts[i] = "`" + part + "`"
+			}
+		}
+		return strings.Join(parts, ".")
+	})
+}
+
+// instrument reports the executions of q to the slow query hook and, when
+// debug is set, to the app logger.
+func instrument(app core.App, collection string, q *dbx.SelectQuery, debug bool) {
+	prev := q.Info().BuildHook
+	q.WithBuildHook(func(built *dbx.Query) {
+		if prev != nil {
+			prev(built)
+		}
+		instrumentQuery(app, collection, built, debug)
+	})
+}
+
+// instrumentQuery reports the executions of the built query or statement
+// q like instrument.
+func instrumentQuery(app core.App, collection string, q *dbx.Query, debug bool) *dbx.Query {
+	queryLogFunc := q.QueryLogFunc
+	q.QueryLogFunc = func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
+		if queryLogFunc != nil {
+			queryLogFunc(ctx, t, sql, rows, err)
+		}
+		reportQuery(app, QueryEvent{Collection: collection, SQL: sql, Duration: t, Err: err}, debug)
+	}
+	execLogFunc := q.ExecLogFunc
+	q.ExecLogFunc = func(ctx context.Context, t time.Duration, sql string, result sql.Result, err error) {
+		if execLogFunc != nil {
+			execLogFunc(ctx, t, sql, result, err)
+		}
+		reportQuery(app, QueryEvent{Collection: collection, SQL: sql, Duration: t, Err: err}, debug)
+	}
+	return q
+}
+
+// reportQuery passes e to the slow query hook and the debug log.
+func reportQuery(app core.App, e QueryEvent, debug bool) {
+	hook := currentSlowQueryHook.Load()
+	slow := hook != nil && e.Duration >= hook.threshold
+	if !slow && !debug {
+		return
+	}
+	e.Caller = callSite()
+	if slow {
+		hook.fn(e)
+	}
+	if debug {
+		app.Logger().Info("dsl query",
+			"collection", e.Collection,
+			"duration", e.Duration.String(),
+			"caller", e.Caller,
+			"sql", e.SQL,
+			"error", e.Err,
+		)
+	}
+}
+
+// callSite returns the "file:line" of the innermost caller of the dsl
+// package on the stack, skipping the dbx and PocketBase frames.
+func callSite() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	inPackage := false
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go") {
+			inPackage = true
+		} else if inPackage && !strings.HasPrefix(frame.Function, "github.com/pocketbase/") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestToSQL(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	sql, params, err := c.ToSQL(*Query("price > {:min} && category.name = 'tools'").Sort("-price").Page(2, 10), dbx.Params{"min": 5})
+	if err != nil {
+		t.Fatalf("Failed to build SQL: %v", err)
+	}
+	for _, part := range []string{"SELECT", "`products`.`price`", "LEFT JOIN `categories`", "ORDER BY", "LIMIT 10", "OFFSET 10"} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("Expected %q in %s", part, sql)
+		}
+	}
+	if strings.Contains(sql, "[[") || strings.Contains(sql, "{{") {
+		t.Errorf("Expected the dbx quoting markers to be replaced, got %s", sql)
+	}
+
+	values := map[string]bool{}
+	for name, value := range params {
+		if !strings.Contains(sql, "{:"+name+"}") {
+			t.Errorf("Expected placeholder {:%s} in %s", name, sql)
+		}
+		values[fmt.Sprint(value)] = true
+	}
+	if !values["5"] || !values["tools"] {
+		t.Errorf("Expected the 5 and tools parameters, got %v", params)
+	}
+
+	if _, _, err := c.ToSQL(*Query("missing = 1")); err == nil {
+		t.Error("Expected error for an invalid filter")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	app := newTestApp(t)
+
+	plan, err := Collection(app, "products").Explain(*Query("category.name = {:name}"), dbx.Params{"name": "tools"})
+	if err != nil {
+		t.Fatalf("Failed to explain query: %v", err)
+	}
+	if len(plan) == 0 {
+		t.Fatal("Expected a query plan")
+	}
+	joined := strings.Join(plan, "\n")
+	if !strings.Contains(joined, "products") || !strings.Contains(joined, "categories") {
+		t.Errorf("Expected both tables in the plan, got\n%s", joined)
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+
+	var mu sync.Mutex
+	var events []QueryEvent
+	SetSlowQueryHook(0, func(e QueryEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	t.Cleanup(func() { SetSlowQueryHook(0, nil) })
+
+	if _, err := c.List(*Query("price > 5").Debug()); err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if _, err := c.Count("price > 5"); err != nil {
+		t.Fatalf("Failed to count records: %v", err)
+	}
+	if _, err := c.Sum("price", *Query("")); err != nil {
+		t.Fatalf("Failed to sum records: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 reported queries, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Collection != "products" {
+			t.Errorf("Expected the products collection, got %q", e.Collection)
+		}
+		if !strings.Contains(e.Caller, "debug_test.go:") {
+			t.Errorf("Expected the call site in debug_test.go, got %q", e.Caller)
+		}
+		if e.Err != nil {
+			t.Errorf("Unexpected query error %v", e.Err)
+		}
+	}
+	if !strings.Contains(events[0].SQL, "> 5") {
+		t.Errorf("Expected the SQL with the inlined filter values, got %s", events[0].SQL)
+	}
+
+	// One, Explain and the bulk statements are reported too
+	SetSlowQueryHook(0, func(e QueryEvent) {
+		events = append(events, e)
+	})
+	hammer, err := c.First(*Query("name = 'hammer'"))
+	if err != nil {
+		t.Fatalf("Failed to find hammer: %v", err)
+	}
+	events = nil
+	if _, err := c.One(hammer.Id); err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if _, err := c.Explain(*Query("price > 5")); err != nil {
+		t.Fatalf("Failed to explain query: %v", err)
+	}
+	if _, err := c.UpdateWhere(*Query("price > 5"), map[string]any{"price": 20}, BulkOptions{SkipHooks: true}); err != nil {
+		t.Fatalf("Failed to update records: %v", err)
+	}
+	var statements []string
+	for _, e := range events {
+		statements = append(statements, strings.Fields(e.SQL)[0])
+	}
+	if got := strings.Join(statements, ","); got != "SELECT,EXPLAIN,SELECT,UPDATE" {
+		t.Errorf("Expected the One, Explain, id and UPDATE statements, got %s", got)
+	}
+	count := len(events)
+
+	// queries faster than the threshold aren't reported
+	SetSlowQueryHook(time.Hour, func(e QueryEvent) {
+		events = append(events, e)
+	})
+	if _, err := c.List(*Query("")); err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(events) != count {
+		t.Errorf("Expected no new reported queries, got %d", len(events)-count)
+	}
+}
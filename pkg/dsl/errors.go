@@ -0,0 +1,164 @@
+package dsl
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// ErrNotFound is matched by the errors returned when a record doesn't
+// exist or isn't visible, e.g. by One, First, Update and Delete.
+//
+// The errors also match sql.ErrNoRows for compatibility with the
+// PocketBase finders.
+//
+// Example:
+//
+//	record, err := dsl.Collection(app, "users").One(id)
+//	if errors.Is(err, dsl.ErrNotFound) {
+//	    return e.NotFoundError("User not found", nil)
+//	}
+var ErrNotFound = errors.New("record not found")
+
+// ErrCollectionNotFound is matched by the errors returned when the
+// collection of a CollectionQueryBuilder, a relation or a repository
+// doesn't exist.
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// ErrForbidden is matched by the errors returned when an operation is
+// denied, e.g. by a hook returning a 403 router.ApiError or by the API
+// rules of a collection enforced with As.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrConflict is matched by the *ConflictError returned when a conditional
+// update finds that the record was modified in between.
+//
+// Example:
+//
+//	if errors.Is(err, dsl.ErrConflict) {
+//	    // reload the record and retry or report the conflict
+//	}
+var ErrConflict = errors.New("record was modified concurrently")
+
+// ErrInvalidQuery is matched by the *QueryError returned when ParseQuery
+// rejects the parameters of a client request.
+//
+// Example:
+//
+//	query, err := dsl.ParseQuery(e.Request.URL.Query(), policy)
+//	if errors.Is(err, dsl.ErrInvalidQuery) {
+//	    return e.BadRequestError(err.Error(), nil)
+//	}
+var ErrInvalidQuery = errors.New("invalid query")
+
+// FieldError is the validation error of a single record field.
+type FieldError struct {
+	Code    string // The validation error code, e.g. "validation_required"
+	Message string // The human readable message, e.g. "Cannot be blank."
+}
+
+// ValidationError is returned when a record fails the PocketBase field
+// validation on create or update, with the code and message of every
+// invalid field.
+//
+// It unwraps to the original validation errors.
+//
+// Example:
+//
+//	_, err := dsl.Collection(app, "users").Create(data)
+//	var validationErr *dsl.ValidationError
+//	if errors.As(err, &validationErr) {
+//	    for field, fieldErr := range validationErr.Fields {
+//	        log.Printf("%s: %s (%s)", field, fieldErr.Message, fieldErr.Code)
+//	    }
+//	}
+type ValidationError struct {
+	Collection string                // The collection name
+	Fields     map[string]FieldError // The errors keyed by field name; nested fields use dots, e.g. "meta.title"
+	err        error                 // The original validation errors
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + e.Fields[name].Message
+	}
+	return fmt.Sprintf("invalid %s record: %s", e.Collection, strings.Join(parts, "; "))
+}
+
+// Unwrap returns the original validation errors.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// notFoundError wraps err with ErrNotFound when it reports a missing row.
+func notFoundError(err error) error {
+	if err == nil || !errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrNotFound, err)
+}
+
+// collectionError wraps an error of a collection lookup with
+// ErrCollectionNotFound.
+func collectionError(err error) error {
+	if err == nil || errors.Is(err, ErrCollectionNotFound) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrCollectionNotFound, err)
+}
+
+// saveError translates an error of saving or deleting a record of
+// collection into the dsl error types.
+func saveError(collection string, err error) error {
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		fields := map[string]FieldError{}
+		flattenValidationErrors(fields, "", errs)
+		return &ValidationError{Collection: collection, Fields: fields, err: err}
+	}
+
+	var apiErr *router.ApiError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrForbidden, err)
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+	}
+	return notFoundError(err)
+}
+
+// flattenValidationErrors adds the errors of errs to fields, joining the
+// names of nested errors with dots.
+func flattenValidationErrors(fields map[string]FieldError, prefix string, errs validation.Errors) {
+	for name, err := range errs {
+		if err == nil {
+			continue
+		}
+		var nested validation.Errors
+		var object validation.Error
+		switch {
+		case errors.As(err, &nested):
+			flattenValidationErrors(fields, prefix+name+".", nested)
+		case errors.As(err, &object):
+			fields[prefix+name] = FieldError{Code: object.Code(), Message: object.Message()}
+		default:
+			fields[prefix+name] = FieldError{Code: "validation_invalid_value", Message: err.Error()}
+		}
+	}
+}
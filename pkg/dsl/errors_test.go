@@ -0,0 +1,144 @@
+package dsl
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+func TestNotFoundErrors(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	checks := map[string]func() error{
+		"One": func() error {
+			_, err := c.One("missing")
+			return err
+		},
+		"First": func() error {
+			_, err := c.First(*Query("name = 'missing'"))
+			return err
+		},
+		"Update": func() error {
+			_, err := c.Update("missing", map[string]any{"name": "saw"})
+			return err
+		},
+		"Delete": func() error {
+			return c.Delete("missing")
+		},
+		"ForceDelete": func() error {
+			return c.ForceDelete("missing")
+		},
+		"Repo.One": func() error {
+			_, err := NewRepo[repoTestProduct](app, "products").One("missing")
+			return err
+		},
+	}
+	for name, check := range checks {
+		err := check()
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: expected the error to still match sql.ErrNoRows, got %v", name, err)
+		}
+		if errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("%s: expected a record error, got %v", name, err)
+		}
+	}
+}
+
+func TestCollectionNotFoundErrors(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "missing")
+
+	checks := map[string]func() error{
+		"One": func() error {
+			_, err := c.One("abc")
+			return err
+		},
+		"List": func() error {
+			_, err := c.List(*Query(""))
+			return err
+		},
+		"Count": func() error {
+			_, err := c.Count("")
+			return err
+		},
+		"Create": func() error {
+			_, err := c.Create(map[string]any{"name": "saw"})
+			return err
+		},
+		"Sum": func() error {
+			_, err := c.Sum("price", *Query(""))
+			return err
+		},
+		"CreateMany": func() error {
+			_, err := c.CreateMany([]map[string]any{{"name": "saw"}})
+			return err
+		},
+	}
+	for name, check := range checks {
+		if err := check(); !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("%s: expected ErrCollectionNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	_, err := c.Create(map[string]any{"name": "hammer", "status": "bogus", "category": "missing"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	if validationErr.Collection != "products" {
+		t.Errorf("Expected the products collection, got %q", validationErr.Collection)
+	}
+	if len(validationErr.Fields) != 2 {
+		t.Errorf("Expected 2 invalid fields, got %v", validationErr.Fields)
+	}
+	for _, name := range []string{"status", "category"} {
+		field, ok := validationErr.Fields[name]
+		if !ok {
+			t.Errorf("Expected an error for %s, got %v", name, validationErr.Fields)
+			continue
+		}
+		if field.Code == "" || field.Message == "" {
+			t.Errorf("Expected the code and message of %s, got %+v", name, field)
+		}
+	}
+
+	// updates and bulk operations report the same error
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer"})
+	if _, err := c.Update(hammer.Id, map[string]any{"status": "bogus"}); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a *ValidationError from Update, got %v", err)
+	}
+	if _, err := c.CreateMany([]map[string]any{{"status": "bogus"}}); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a *ValidationError from CreateMany, got %v", err)
+	}
+}
+
+func TestForbiddenError(t *testing.T) {
+	app := newTestApp(t)
+	app.OnRecordCreate("products").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") == "archived" {
+			return router.NewForbiddenError("archived products can't be created", nil)
+		}
+		return e.Next()
+	})
+
+	_, err := Collection(app, "products").Create(map[string]any{"name": "hammer", "status": "archived"})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden, got %v", err)
+	}
+	var apiErr *router.ApiError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("Expected the original *router.ApiError, got %v", err)
+	}
+}
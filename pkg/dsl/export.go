@@ -0,0 +1,412 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cast"
+)
+
+// Format is a data exchange format of Export and Import.
+type Format string
+
+const (
+	// FormatCSV is comma-separated values with a header row. Multiple
+	// values are written as JSON arrays, JSON fields as JSON text.
+	FormatCSV Format = "csv"
+
+	// FormatJSONL is JSON Lines: one JSON object per line.
+	FormatJSONL Format = "jsonl"
+)
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Fields lists the exported columns, in order. A dotted path, e.g.
+	// "category.name", exports a field of a related record; the relation
+	// is expanded automatically. By default the visible fields of the
+	// collection are exported, followed by the fields of the relations
+	// expanded by the query.
+	//
+	// The password and tokenKey fields of auth collections can't be
+	// exported, nor can hidden fields when the builder enforces the API
+	// rules.
+	Fields []string
+}
+
+// exportColumn is a column of an export.
+type exportColumn struct {
+	name     string   // Column name, the dotted field path
+	path     []string // Relation fields followed by the exported field
+	multiple bool     // Whether the path crosses a multiple relation
+	email    bool     // Whether the exported field is the email of an auth collection
+}
+
+// Export streams the records matching the query to w in the given format,
+// loading them in chunks like EachChunk.
+//
+// Every record becomes a CSV row or a JSON line whose columns are the
+// ExportOptions fields. Fields of expanded relations are flattened into
+// "relation.field" columns; a path crossing a relation with multiple
+// records yields one flat list of values. Pagination and the Fields
+// projection of the query are ignored; the chunk size is taken from
+// perPage.
+//
+// When the builder enforces the API rules (see As), the emails of auth
+// records are only exported when the record JSON would include them: with
+// emailVisibility, for the auth record itself or for the records its
+// ManageRule allows.
+//
+// Example:
+//
+//	f, err := os.Create("products.csv")
+//	defer f.Close()
+//	err = dsl.Collection(app, "products").Export(
+//	    *dsl.Query("status = 'active'").Sort("name"),
+//	    dsl.FormatCSV,
+//	    f,
+//	    dsl.ExportOptions{Fields: []string{"id", "name", "price", "category.name"}},
+//	)
+func (c *CollectionQueryBuilder) Export(query QueryBuilder, format Format, w io.Writer, opts ...ExportOptions) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	if err := c.checkContext(); err != nil {
+		return err
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return collectionError(err)
+	}
+	var options ExportOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	fields := options.Fields
+	if len(fields) == 0 {
+		fields, err = c.defaultExportFields(collection, query.expand)
+		if err != nil {
+			return err
+		}
+	}
+	columns := make([]exportColumn, len(fields))
+	expand := splitExpand(query.expand)
+	for i, name := range fields {
+		columns[i], err = c.exportColumn(collection, name)
+		if err != nil {
+			return err
+		}
+		if path := columns[i].path; len(path) > 1 {
+			if relation := strings.Join(path[:len(path)-1], "."); !slices.Contains(expand, relation) {
+				expand = append(expand, relation)
+			}
+		}
+	}
+	query.expand = strings.Join(expand, ",")
+	query.fields = nil
+	query.page = 0
+
+	var writer recordWriter
+	if format == FormatCSV {
+		writer, err = newCSVRecordWriter(w, fields)
+	} else {
+		writer = &jsonlRecordWriter{w: w, names: fields}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.EachChunk(c.context(), query, func(records []*core.Record) error {
+		visible, err := c.visibleEmails(records, columns)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			values := make([]any, len(columns))
+			for i, column := range columns {
+				values[i] = column.value(record, visible)
+			}
+			if err := writer.write(values); err != nil {
+				return err
+			}
+		}
+		return writer.flush()
+	})
+	if err != nil {
+		return err
+	}
+	return writer.flush()
+}
+
+// defaultExportFields returns the visible fields of collection followed by
+// the visible fields of the relations in expand.
+func (c *CollectionQueryBuilder) defaultExportFields(collection *core.Collection, expand string) ([]string, error) {
+	fields := visibleFieldNames(collection, "")
+	for _, path := range splitExpand(expand) {
+		related := collection
+		for _, name := range strings.Split(path, ".") {
+			relation, ok := related.Fields.GetByName(name).(*core.RelationField)
+			if !ok {
+				return nil, fmt.Errorf("can't derive the export columns of expand %q, list them in ExportOptions.Fields", path)
+			}
+			var err error
+			related, err = c.app.FindCachedCollectionByNameOrId(relation.CollectionId)
+			if err != nil {
+				return nil, collectionError(err)
+			}
+		}
+		fields = append(fields, visibleFieldNames(related, path+".")...)
+	}
+	return fields, nil
+}
+
+// visibleFieldNames returns the names of the non-hidden fields of
+// collection with the given prefix.
+func visibleFieldNames(collection *core.Collection, prefix string) []string {
+	var names []string
+	for _, field := range collection.Fields {
+		if !field.GetHidden() {
+			names = append(names, prefix+field.GetName())
+		}
+	}
+	return names
+}
+
+// exportColumn resolves the dotted field path name against collection.
+func (c *CollectionQueryBuilder) exportColumn(collection *core.Collection, name string) (exportColumn, error) {
+	column := exportColumn{name: name, path: strings.Split(name, ".")}
+	related := collection
+	for i, fieldName := range column.path {
+		field := related.Fields.GetByName(fieldName)
+		if field == nil {
+			return column, fmt.Errorf("unknown export field %q: %s has no field %q", name, related.Name, fieldName)
+		}
+		if field.GetHidden() && (c.rules() != nil || slices.Contains(alwaysHiddenFields, fieldName)) {
+			return column, fmt.Errorf("invalid export field %q: %s.%s is hidden", name, related.Name, fieldName)
+		}
+		if i == len(column.path)-1 {
+			column.email = related.IsAuth() && fieldName == core.FieldNameEmail
+			break
+		}
+		relation, ok := field.(*core.RelationField)
+		if !ok {
+			return column, fmt.Errorf("invalid export field %q: %s.%s is not a relation", name, related.Name, fieldName)
+		}
+		column.multiple = column.multiple || relation.IsMultiple()
+		var err error
+		related, err = c.app.FindCachedCollectionByNameOrId(relation.CollectionId)
+		if err != nil {
+			return column, collectionError(err)
+		}
+	}
+	return column, nil
+}
+
+// value returns the value of the column for record. visible is the result
+// of visibleEmails; the emails of the other auth records without
+// emailVisibility are exported as null.
+func (col exportColumn) value(record *core.Record, visible map[*core.Record]bool) any {
+	return pathValue(record, col.path, col.multiple, func(owner *core.Record) bool {
+		return !col.email || visible == nil || visible[owner] || owner.GetBool(core.FieldNameEmailVisibility)
+	})
+}
+
+// pathValue returns the value of the field path of record, following the
+// expanded relations. A path crossing a multiple relation yields a single
+// list with the values of all the related records. show reports whether
+// the field of the record owning it is exported.
+func pathValue(record *core.Record, path []string, multiple bool, show func(owner *core.Record) bool) any {
+	owners := pathRecords([]*core.Record{record}, path[:len(path)-1])
+	value := func(owner *core.Record) any {
+		if !show(owner) {
+			return nil
+		}
+		return owner.Get(path[len(path)-1])
+	}
+	if !multiple {
+		if len(owners) == 0 {
+			return nil
+		}
+		return value(owners[0])
+	}
+	values := make([]any, 0, len(owners))
+	for _, owner := range owners {
+		values = append(values, value(owner))
+	}
+	return values
+}
+
+// pathRecords returns the records reached from records through the
+// expanded relations of path.
+func pathRecords(records []*core.Record, path []string) []*core.Record {
+	for _, name := range path {
+		var related []*core.Record
+		for _, record := range records {
+			related = append(related, record.ExpandedAll(name)...)
+		}
+		records = related
+	}
+	return records
+}
+
+// visibleEmails returns the auth records of the email columns whose email
+// the request may see regardless of emailVisibility, like the record API:
+// the auth record itself and the records its ManageRule allows. It returns
+// nil when the builder doesn't enforce the API rules, so every email is
+// exported.
+func (c *CollectionQueryBuilder) visibleEmails(records []*core.Record, columns []exportColumn) (map[*core.Record]bool, error) {
+	info := c.rules()
+	if info == nil {
+		return nil, nil
+	}
+	visible := map[*core.Record]bool{}
+	for _, column := range columns {
+		if !column.email {
+			continue
+		}
+		owners := pathRecords(records, column.path[:len(column.path)-1])
+		if len(owners) == 0 {
+			continue
+		}
+		collection := owners[0].Collection()
+		ids := make([]any, 0, len(owners))
+		for _, owner := range owners {
+			if info.Auth != nil && info.Auth.Id == owner.Id && info.Auth.Collection().Id == collection.Id {
+				visible[owner] = true
+			}
+			ids = append(ids, owner.Id)
+		}
+		if collection.ManageRule == nil || *collection.ManageRule == "" {
+			continue
+		}
+
+		q := c.withQueryContext(c.app.RecordQuery(collection).
+			Select("[[" + collection.Name + ".id]]").
+			AndWhere(dbx.In("[["+collection.Name+".id]]", ids...)))
+		resolver := core.NewRecordFieldResolver(c.app, collection, info, true)
+		expr, err := ruleExpr(resolver, collection, collection.ManageRule, "manage")
+		if err != nil {
+			return nil, err
+		}
+		q.AndWhere(expr)
+		if err := resolver.UpdateQuery(q); err != nil {
+			return nil, err
+		}
+		var managed []string
+		if err := q.Distinct(true).Column(&managed); err != nil {
+			return nil, c.contextError(err)
+		}
+		for _, owner := range owners {
+			if slices.Contains(managed, owner.Id) {
+				visible[owner] = true
+			}
+		}
+	}
+	return visible, nil
+}
+
+// recordWriter writes the rows of an export.
+type recordWriter interface {
+	write(values []any) error
+	flush() error
+}
+
+// csvRecordWriter writes CSV rows.
+type csvRecordWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+// newCSVRecordWriter returns a CSV writer and writes the header row.
+func newCSVRecordWriter(w io.Writer, names []string) (*csvRecordWriter, error) {
+	writer := &csvRecordWriter{w: csv.NewWriter(w), row: make([]string, len(names))}
+	if err := writer.w.Write(names); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (cw *csvRecordWriter) write(values []any) error {
+	for i, value := range values {
+		cell, err := csvCell(value)
+		if err != nil {
+			return err
+		}
+		cw.row[i] = cell
+	}
+	return cw.w.Write(cw.row)
+}
+
+func (cw *csvRecordWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// csvCell formats value as a CSV cell.
+func csvCell(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case types.DateTime:
+		return v.String(), nil
+	case types.JSONRaw:
+		return string(v), nil
+	case []string:
+		if len(v) == 0 {
+			return "", nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return "", nil
+		}
+	case int, int64, float64:
+		return cast.ToString(v), nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// jsonlRecordWriter writes JSON lines whose keys keep the column order.
+type jsonlRecordWriter struct {
+	w     io.Writer
+	names []string
+	buf   bytes.Buffer
+}
+
+func (jw *jsonlRecordWriter) write(values []any) error {
+	jw.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			jw.buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(jw.names[i])
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", jw.names[i], err)
+		}
+		jw.buf.Write(name)
+		jw.buf.WriteByte(':')
+		jw.buf.Write(data)
+	}
+	jw.buf.WriteString("}\n")
+	return nil
+}
+
+func (jw *jsonlRecordWriter) flush() error {
+	_, err := jw.w.Write(jw.buf.Bytes())
+	jw.buf.Reset()
+	return err
+}
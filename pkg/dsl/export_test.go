@@ -0,0 +1,174 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestExport(t *testing.T) {
+	app := newTestApp(t)
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(&core.RelationField{Name: "alternatives", CollectionId: mustCollection(t, app, "categories").Id, MaxSelect: 5})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	garden := mustCreate(t, app, "categories", map[string]any{"name": "garden"})
+	mustCreate(t, app, "products", map[string]any{
+		"name": "hammer, large", "price": 10.5, "tags": []string{"new", "sale"},
+		"category": tools.Id, "alternatives": []string{tools.Id, garden.Id},
+	})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20})
+
+	c := Collection(app, "products")
+	query := *Query("").Sort("name").Page(0, 1)
+	options := ExportOptions{Fields: []string{"name", "price", "tags", "category.name", "alternatives.name"}}
+
+	var out strings.Builder
+	if err := c.Export(query, FormatCSV, &out, options); err != nil {
+		t.Fatalf("Failed to export CSV: %v", err)
+	}
+	expected := `name,price,tags,category.name,alternatives.name
+"hammer, large",10.5,"[""new"",""sale""]",tools,"[""tools"",""garden""]"
+saw,20,,,
+`
+	if out.String() != expected {
+		t.Errorf("Expected CSV\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := c.Export(query, FormatJSONL, &out, options); err != nil {
+		t.Fatalf("Failed to export JSONL: %v", err)
+	}
+	expected = `{"name":"hammer, large","price":10.5,"tags":["new","sale"],"category.name":"tools","alternatives.name":["tools","garden"]}
+{"name":"saw","price":20,"tags":[],"category.name":null,"alternatives.name":[]}
+`
+	if out.String() != expected {
+		t.Errorf("Expected JSONL\n%s\ngot\n%s", expected, out.String())
+	}
+
+	// by default the visible fields and the expanded relations are exported
+	out.Reset()
+	if err := c.Export(*Query("price > 15").Expand("category"), FormatCSV, &out); err != nil {
+		t.Fatalf("Failed to export CSV: %v", err)
+	}
+	header, _, _ := strings.Cut(out.String(), "\n")
+	if header != "id,name,price,status,tags,category,created,updated,alternatives,category.id,category.name" {
+		t.Errorf("Expected the default columns, got %s", header)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 2 {
+		t.Errorf("Expected the header and 1 row, got %d lines", lines)
+	}
+
+	for _, fields := range [][]string{{"missing"}, {"name.length"}, {"category.missing"}} {
+		if err := c.Export(query, FormatCSV, &out, ExportOptions{Fields: fields}); err == nil {
+			t.Errorf("Expected error for the fields %v", fields)
+		}
+	}
+	if err := c.Export(query, Format("xml"), &out); err == nil {
+		t.Error("Expected error for an unsupported format")
+	}
+}
+
+func TestExportNestedRelations(t *testing.T) {
+	app := newTestApp(t)
+	categories := mustCollection(t, app, "categories")
+	categories.Fields.Add(&core.RelationField{Name: "related", CollectionId: categories.Id, MaxSelect: 5})
+	if err := app.Save(categories); err != nil {
+		t.Fatalf("Failed to update categories collection: %v", err)
+	}
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(&core.RelationField{Name: "alternatives", CollectionId: categories.Id, MaxSelect: 5})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	garden := mustCreate(t, app, "categories", map[string]any{"name": "garden"})
+	paint := mustCreate(t, app, "categories", map[string]any{"name": "paint"})
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools", "related": []string{garden.Id, paint.Id}})
+	wood := mustCreate(t, app, "categories", map[string]any{"name": "wood", "related": []string{tools.Id}})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "category": tools.Id, "alternatives": []string{tools.Id, wood.Id}})
+
+	// a single then multiple relation and multiple relations in a row
+	// yield one flat list
+	var out strings.Builder
+	options := ExportOptions{Fields: []string{"name", "category.related.name", "alternatives.related.name"}}
+	if err := Collection(app, "products").Export(*Query(""), FormatJSONL, &out, options); err != nil {
+		t.Fatalf("Failed to export JSONL: %v", err)
+	}
+	expected := `{"name":"saw","category.related.name":["garden","paint"],"alternatives.related.name":["garden","paint","tools"]}
+`
+	if out.String() != expected {
+		t.Errorf("Expected JSONL\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	app := newRulesApp(t)
+	users := mustCollection(t, app, "users")
+	users.Fields.Add(&core.TextField{Name: "secret", Hidden: true})
+	users.ListRule = types.Pointer("")
+	users.ViewRule = types.Pointer("")
+	users.ManageRule = types.Pointer("@request.auth.verified = true")
+	if err := app.Save(users); err != nil {
+		t.Fatalf("Failed to update users collection: %v", err)
+	}
+	alice := mustCreate(t, app, "users", map[string]any{"email": "alice@example.com", "password": "1234567890", "name": "alice"})
+	bob := mustCreate(t, app, "users", map[string]any{"email": "bob@example.com", "password": "1234567890", "name": "bob", "emailVisibility": true, "secret": "b"})
+	carol := mustCreate(t, app, "users", map[string]any{"email": "carol@example.com", "password": "1234567890", "name": "carol", "verified": true})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "owner": alice.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "status": "active", "owner": bob.Id})
+
+	export := func(c *CollectionQueryBuilder, query QueryBuilder, fields ...string) string {
+		t.Helper()
+		var out strings.Builder
+		if err := c.Export(query, FormatCSV, &out, ExportOptions{Fields: fields}); err != nil {
+			t.Fatalf("Failed to export CSV: %v", err)
+		}
+		return out.String()
+	}
+	byName := *Query("").Sort("name")
+
+	// emails follow the visibility of the record JSON under the rules
+	if out := export(Collection(app, "users").As(nil), byName, "name", "email"); out != "name,email\nalice,\nbob,bob@example.com\ncarol,\n" {
+		t.Errorf("Expected only the visible emails for a guest, got\n%s", out)
+	}
+	if out := export(Collection(app, "users").As(alice), byName, "name", "email"); out != "name,email\nalice,alice@example.com\nbob,bob@example.com\ncarol,\n" {
+		t.Errorf("Expected the own and visible emails, got\n%s", out)
+	}
+	if out := export(Collection(app, "users").As(carol), byName, "name", "email"); out != "name,email\nalice,alice@example.com\nbob,bob@example.com\ncarol,carol@example.com\n" {
+		t.Errorf("Expected the emails of the managed users, got\n%s", out)
+	}
+	if out := export(Collection(app, "users"), byName, "name", "email"); out != "name,email\nalice,alice@example.com\nbob,bob@example.com\ncarol,carol@example.com\n" {
+		t.Errorf("Expected every email without the rules, got\n%s", out)
+	}
+
+	// the default columns and related records follow the same visibility
+	out := export(Collection(app, "users").As(nil), *Query("name = 'alice'"))
+	if header, row, _ := strings.Cut(out, "\n"); strings.Contains(header, "password") || strings.Contains(header, "tokenKey") || strings.Contains(header, "secret") || strings.Contains(row, "alice@example.com") {
+		t.Errorf("Expected no hidden fields nor email, got\n%s", out)
+	}
+	if out := export(Collection(app, "products").As(nil), byName, "name", "owner.email"); out != "name,owner.email\nhammer,\nsaw,bob@example.com\n" {
+		t.Errorf("Expected only the visible owner emails, got\n%s", out)
+	}
+
+	// hidden fields are rejected
+	for _, fields := range [][]string{{"password"}, {"tokenKey"}, {"secret"}} {
+		if err := Collection(app, "users").As(alice).Export(byName, FormatCSV, &strings.Builder{}, ExportOptions{Fields: fields}); err == nil {
+			t.Errorf("Expected error for the hidden fields %v", fields)
+		}
+	}
+	if err := Collection(app, "products").As(alice).Export(byName, FormatCSV, &strings.Builder{}, ExportOptions{Fields: []string{"owner.tokenKey"}}); err == nil {
+		t.Error("Expected error for a hidden field of a relation")
+	}
+	for _, fields := range [][]string{{"password"}, {"tokenKey"}} {
+		if err := Collection(app, "users").Export(byName, FormatCSV, &strings.Builder{}, ExportOptions{Fields: fields}); err == nil {
+			t.Errorf("Expected error for the fields %v without the rules", fields)
+		}
+	}
+	if out := export(Collection(app, "users"), *Query("name = 'bob'"), "secret"); out != "secret\nb\n" {
+		t.Errorf("Expected the hidden field without the rules, got %q", out)
+	}
+}
@@ -0,0 +1,205 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/picker"
+	"github.com/pocketbase/pocketbase/tools/tokenizer"
+)
+
+// fieldNode is a node of a parsed Fields projection.
+type fieldNode struct {
+	leaf     bool                  // Whether the whole value at this path is picked
+	modifier string                // Value modifier, e.g. "excerpt(200,true)"
+	children map[string]*fieldNode // Picked nested fields
+}
+
+// Fields limits the returned record fields, with the semantics of the
+// PocketBase "fields" query parameter.
+//
+// Each argument may hold one or more comma-separated field paths. Nested
+// expand fields use dot-notation ("expand.author.name"), "*" picks all
+// fields of a level and modifiers such as ":excerpt(200,true)" transform the
+// value. The expand key must be picked for expanded relations to be kept.
+//
+// Only the selected columns (plus the id and the relations to expand or
+// preload) are read from the database and the other fields are hidden from
+// the record serialization. Since projected records are partial, they must
+// not be saved back.
+//
+// Example:
+//
+//	query := dsl.Query("").Expand("profile").Fields("id", "name", "expand.profile.name")
+//	records, err := dsl.Collection(app, "users").List(*query)
+//
+//	// modifiers
+//	query := dsl.Query("").Fields("id,title,body:excerpt(200,true)")
+func (q *QueryBuilder) Fields(fields ...string) *QueryBuilder {
+	for _, raw := range fields {
+		tokens, err := tokenizer.NewFromString(raw).ScanAll()
+		if err != nil {
+			q.err = fmt.Errorf("invalid fields %q: %w", raw, err)
+			return q
+		}
+		for _, token := range tokens {
+			if token = strings.TrimSpace(token); token != "" {
+				q.fields = append(q.fields, token)
+			}
+		}
+	}
+	return q
+}
+
+// parseFields builds the projection tree of fields.
+func parseFields(fields []string) map[string]*fieldNode {
+	if len(fields) == 0 {
+		return nil
+	}
+	root := map[string]*fieldNode{}
+	for _, field := range fields {
+		path, modifier, _ := strings.Cut(field, ":")
+		level := root
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			node, ok := level[part]
+			if !ok {
+				node = &fieldNode{children: map[string]*fieldNode{}}
+				level[part] = node
+			}
+			if i == len(parts)-1 {
+				node.leaf = true
+				node.modifier = modifier
+			}
+			level = node.children
+		}
+	}
+	return root
+}
+
+// projectedColumns returns the columns to select for the fields
+// projection, or nil when all columns are needed.
+func projectedColumns(collection *core.Collection, query QueryBuilder) []string {
+	tree := parseFields(query.fields)
+	if tree == nil || tree["*"] != nil {
+		return nil
+	}
+
+	names := map[string]bool{core.FieldNameId: true}
+	for name := range tree {
+		names[name] = true
+	}
+	for _, path := range splitExpand(query.expand) {
+		root, _, _ := strings.Cut(path, ".")
+		names[root] = true
+	}
+	for _, p := range query.preloads {
+		root, _, _ := strings.Cut(p.path, ".")
+		names[root] = true
+	}
+
+	columns := []string{}
+	for _, field := range collection.Fields {
+		if names[field.GetName()] {
+			columns = append(columns, "[["+collection.Name+"."+field.GetName()+"]]")
+		}
+	}
+	return columns
+}
+
+// exportedKeys returns the keys of the public export of record.
+func exportedKeys(record *core.Record) []string {
+	keys := record.Collection().Fields.FieldNames()
+	return append(keys, core.FieldNameCollectionId, core.FieldNameCollectionName, core.FieldNameExpand)
+}
+
+// keepsExpand reports whether a fields projection keeps the expand data.
+func keepsExpand(fields []string) bool {
+	tree := parseFields(fields)
+	return tree == nil || tree["*"] != nil || tree[core.FieldNameExpand] != nil
+}
+
+// projectRecords applies the fields projection to records and their
+// expanded relations.
+func projectRecords(records []*core.Record, fields []string) error {
+	tree := parseFields(fields)
+	if tree == nil {
+		return nil
+	}
+	seen := map[*core.Record]bool{}
+	for _, record := range records {
+		if err := projectRecord(record, tree, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectRecord hides the fields of record that aren't part of tree,
+// applies the value modifiers and trims the expand data.
+//
+// Expanded records may be shared between records, so seen tracks the
+// already projected ones to apply the modifiers only once.
+func projectRecord(record *core.Record, tree map[string]*fieldNode, seen map[*core.Record]bool) error {
+	if seen[record] {
+		return nil
+	}
+	seen[record] = true
+	all := tree["*"] != nil
+
+	hidden := []string{}
+	for _, name := range exportedKeys(record) {
+		node := tree[name]
+		if node == nil {
+			if !all {
+				hidden = append(hidden, name)
+			}
+			continue
+		}
+		if node.modifier != "" {
+			picked, err := picker.Pick(map[string]any{name: record.Get(name)}, name+":"+node.modifier)
+			if err != nil {
+				return fmt.Errorf("invalid modifier of field %q: %w", name, err)
+			}
+			if m, ok := picked.(map[string]any); ok {
+				record.Set(name, m[name])
+			}
+		}
+	}
+	record.Hide(hidden...)
+
+	node := tree[core.FieldNameExpand]
+	if node == nil || node.leaf {
+		return nil
+	}
+
+	trimmed := map[string]any{}
+	for relation, value := range record.Expand() {
+		child := node.children[relation]
+		if child == nil {
+			child = node.children["*"]
+		}
+		if child == nil {
+			continue
+		}
+		if !child.leaf {
+			var expanded []*core.Record
+			switch v := value.(type) {
+			case *core.Record:
+				expanded = []*core.Record{v}
+			case []*core.Record:
+				expanded = v
+			}
+			for _, r := range expanded {
+				if err := projectRecord(r, child.children, seen); err != nil {
+					return err
+				}
+			}
+		}
+		trimmed[relation] = value
+	}
+	record.SetExpand(trimmed)
+
+	return nil
+}
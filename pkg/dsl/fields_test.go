@@ -0,0 +1,107 @@
+package dsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	app := newTestApp(t)
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer with a long name", "price": 10, "category": tools.Id})
+
+	c := Collection(app, "products")
+
+	scenarios := []struct {
+		name     string
+		query    *QueryBuilder
+		expected string
+	}{
+		{
+			"top level fields",
+			Query("").Fields("id", "name"),
+			`{"id":"","name":"hammer with a long name"}`,
+		},
+		{
+			"comma-separated fields and modifiers",
+			Query("").Fields("price,name:excerpt(6,true)"),
+			`{"name":"hammer...","price":10}`,
+		},
+		{
+			"expand dropped when not picked",
+			Query("").Expand("category").Fields("name"),
+			`{"name":"hammer with a long name"}`,
+		},
+		{
+			"nested expand fields",
+			Query("").Expand("category").Fields("price", "expand.category.name", "collectionName"),
+			`{"collectionName":"products","expand":{"category":{"name":"tools"}},"price":10}`,
+		},
+		{
+			"wildcard",
+			Query("").Fields("*", "name:excerpt(3)"),
+			`{"category":"","collectionId":"","collectionName":"products","created":"","id":"","name":"ham","price":10,"status":"","tags":[],"updated":""}`,
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			record, err := c.First(*s.query)
+			if err != nil {
+				t.Fatalf("Failed to fetch record: %v", err)
+			}
+			raw, err := json.Marshal(record)
+			if err != nil {
+				t.Fatalf("Failed to serialize record: %v", err)
+			}
+
+			// blank out the generated values
+			var data map[string]any
+			json.Unmarshal(raw, &data)
+			blank(data)
+			raw, _ = json.Marshal(data)
+
+			if string(raw) != s.expected {
+				t.Errorf("Expected %s, got %s", s.expected, raw)
+			}
+		})
+	}
+}
+
+func TestFieldsColumns(t *testing.T) {
+	app := newTestApp(t)
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10, "category": tools.Id})
+
+	records, err := Collection(app, "products").List(*Query("price > 5").Sort("-price").Expand("category").Fields("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record.Id == "" || record.GetString("name") != "hammer" || record.GetString("category") != tools.Id {
+		t.Errorf("Expected the id, name and category columns to be loaded, got %v", record.FieldsData())
+	}
+	if record.GetInt("price") != 0 {
+		t.Errorf("Expected the price column not to be loaded, got %d", record.GetInt("price"))
+	}
+}
+
+// blank replaces the generated id and date values in data with empty
+// strings.
+func blank(data map[string]any) {
+	for key, value := range data {
+		switch key {
+		case "id", "collectionId", "created", "updated", "category":
+			if _, ok := value.(string); ok {
+				data[key] = ""
+			}
+		}
+		if nested, ok := value.(map[string]any); ok {
+			blank(nested)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+)
+
+// placeholderPrefix is the prefix of the auto-generated filter placeholders.
+//
+// It is deliberately unusual so that generated names don't collide with
+// hand-written placeholders such as {:status} or {:id}.
+const placeholderPrefix = "dslp"
+
+// Op is a PocketBase filter comparison operator.
+type Op string
+
+// Supported comparison operators.
+//
+// The Any* operators match when at least one of the values of a multi-valued
+// field (multiple relations, back-relations, ":each" select/file values)
+// satisfies the comparison. The plain operators require all of the values
+// to satisfy it.
+const (
+	OpEq         Op = "="
+	OpNeq        Op = "!="
+	OpGt         Op = ">"
+	OpGte        Op = ">="
+	OpLt         Op = "<"
+	OpLte        Op = "<="
+	OpLike       Op = "~"
+	OpNotLike    Op = "!~"
+	OpAnyEq      Op = "?="
+	OpAnyNeq     Op = "?!="
+	OpAnyGt      Op = "?>"
+	OpAnyGte     Op = "?>="
+	OpAnyLt      Op = "?<"
+	OpAnyLte     Op = "?<="
+	OpAnyLike    Op = "?~"
+	OpAnyNotLike Op = "?!~"
+)
+
+// negatedOps maps every operator to its logical complement.
+//
+// Negating an "all values" comparison yields an "any value" comparison with
+// the opposite operator and vice versa, e.g. NOT(tags ?= 'a') == (tags != 'a').
+var negatedOps = map[Op]Op{
+	OpEq:         OpAnyNeq,
+	OpNeq:        OpAnyEq,
+	OpGt:         OpAnyLte,
+	OpGte:        OpAnyLt,
+	OpLt:         OpAnyGte,
+	OpLte:        OpAnyGt,
+	OpLike:       OpAnyNotLike,
+	OpNotLike:    OpAnyLike,
+	OpAnyEq:      OpNeq,
+	OpAnyNeq:     OpEq,
+	OpAnyGt:      OpLte,
+	OpAnyGte:     OpLt,
+	OpAnyLt:      OpGte,
+	OpAnyLte:     OpGt,
+	OpAnyLike:    OpNotLike,
+	OpAnyNotLike: OpLike,
+}
+
+// ErrEmptyFieldName is returned when compiling a comparison without a field name.
+var ErrEmptyFieldName = errors.New("filter field name is empty")
+
+// Expr is a composable filter expression that compiles into a PocketBase
+// filter string and its bound parameters.
+//
+// Expressions are created with the comparison helpers (Eq, Gt, In, ...)
+// and combined with And, Or and Not. Values are never inlined into the
+// filter string; each one is bound to an auto-generated placeholder.
+type Expr interface {
+	// compile writes the expression into the filter compiler.
+	compile(c *filterCompiler) (string, error)
+
+	// negate returns the logical complement of the expression.
+	negate() Expr
+}
+
+// filterCompiler generates placeholder names and collects bound values
+// while compiling an expression tree.
+type filterCompiler struct {
+	params dbx.Params // Bound values, including pre-existing ones
+	next   int        // Next placeholder suffix to try
+}
+
+// bind stores value under a new, unused placeholder name and returns the
+// placeholder reference (e.g. "{:dslp0}").
+func (c *filterCompiler) bind(value any) string {
+	for {
+		name := fmt.Sprintf("%s%d", placeholderPrefix, c.next)
+		c.next++
+		if _, exists := c.params[name]; !exists {
+			c.params[name] = value
+			return "{:" + name + "}"
+		}
+	}
+}
+
+// CompileFilter compiles expr into a PocketBase filter string and its
+// parameters.
+//
+// An empty string is returned for expressions without any condition
+// (e.g. an empty And()).
+//
+// Example:
+//
+//	filter, params, err := dsl.CompileFilter(dsl.And(
+//	    dsl.Eq("status", "active"),
+//	    dsl.Gt("age", 18),
+//	))
+//	// filter: "(status = {:dslp0} && age > {:dslp1})"
+func CompileFilter(expr Expr) (string, dbx.Params, error) {
+	c := &filterCompiler{params: dbx.Params{}}
+	filter, err := expr.compile(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return filter, c.params, nil
+}
+
+// compareExpr is a single "field op value" comparison.
+type compareExpr struct {
+	field string
+	op    Op
+	value any
+}
+
+func (e compareExpr) compile(c *filterCompiler) (string, error) {
+	if strings.TrimSpace(e.field) == "" {
+		return "", ErrEmptyFieldName
+	}
+	if e.value == nil {
+		// null is a PocketBase identifier and can't be bound as a parameter
+		return e.field + " " + string(e.op) + " null", nil
+	}
+	return e.field + " " + string(e.op) + " " + c.bind(e.value), nil
+}
+
+func (e compareExpr) negate() Expr {
+	if e.value == nil {
+		// emptiness checks are plain inverses, e.g. NOT(a = null) == (a != null)
+		op := OpNeq
+		if e.op == OpNeq {
+			op = OpEq
+		}
+		return compareExpr{field: e.field, op: op}
+	}
+	return compareExpr{field: e.field, op: negatedOps[e.op], value: e.value}
+}
+
+// Compare creates a comparison expression with an explicit operator.
+//
+// Example:
+//
+//	dsl.Compare("price", dsl.OpGte, 10)
+func Compare(field string, op Op, value any) Expr {
+	return compareExpr{field: field, op: op, value: value}
+}
+
+// Eq matches records where field equals value.
+//
+// Example:
+//
+//	dsl.Eq("status", "active")      // status = {:dslp0}
+//	dsl.Eq("author.name", "Alice")  // relation fields can be traversed with "."
+func Eq(field string, value any) Expr { return Compare(field, OpEq, value) }
+
+// Neq matches records where field is not equal to value.
+func Neq(field string, value any) Expr { return Compare(field, OpNeq, value) }
+
+// Gt matches records where field is greater than value.
+func Gt(field string, value any) Expr { return Compare(field, OpGt, value) }
+
+// Gte matches records where field is greater than or equal to value.
+func Gte(field string, value any) Expr { return Compare(field, OpGte, value) }
+
+// Lt matches records where field is less than value.
+func Lt(field string, value any) Expr { return Compare(field, OpLt, value) }
+
+// Lte matches records where field is less than or equal to value.
+func Lte(field string, value any) Expr { return Compare(field, OpLte, value) }
+
+// Like matches records where field contains value (case-insensitive).
+//
+// The value is wrapped in "%" automatically unless it already contains
+// a "%" wildcard, following the PocketBase "~" operator semantics.
+func Like(field string, value any) Expr { return Compare(field, OpLike, value) }
+
+// NotLike matches records where field doesn't contain value.
+func NotLike(field string, value any) Expr { return Compare(field, OpNotLike, value) }
+
+// AnyEq matches records where at least one value of a multi-valued field
+// equals value.
+//
+// Example:
+//
+//	dsl.AnyEq(dsl.Each("tags"), "sale") // tags:each ?= {:dslp0}
+//	dsl.AnyEq(dsl.Via("comments", "post", "author"), userId)
+func AnyEq(field string, value any) Expr { return Compare(field, OpAnyEq, value) }
+
+// AnyNeq matches records where at least one value of a multi-valued field
+// is not equal to value.
+func AnyNeq(field string, value any) Expr { return Compare(field, OpAnyNeq, value) }
+
+// AnyGt matches records where at least one value of field is greater than value.
+func AnyGt(field string, value any) Expr { return Compare(field, OpAnyGt, value) }
+
+// AnyGte matches records where at least one value of field is greater than or equal to value.
+func AnyGte(field string, value any) Expr { return Compare(field, OpAnyGte, value) }
+
+// AnyLt matches records where at least one value of field is less than value.
+func AnyLt(field string, value any) Expr { return Compare(field, OpAnyLt, value) }
+
+// AnyLte matches records where at least one value of field is less than or equal to value.
+func AnyLte(field string, value any) Expr { return Compare(field, OpAnyLte, value) }
+
+// AnyLike matches records where at least one value of field contains value.
+func AnyLike(field string, value any) Expr { return Compare(field, OpAnyLike, value) }
+
+// AnyNotLike matches records where at least one value of field doesn't contain value.
+func AnyNotLike(field string, value any) Expr { return Compare(field, OpAnyNotLike, value) }
+
+// IsNull matches records where field is null or empty.
+//
+// PocketBase doesn't distinguish between null and zero values of empty
+// text, relation, select and file fields, so neither does IsNull.
+func IsNull(field string) Expr { return Compare(field, OpEq, nil) }
+
+// IsNotNull matches records where field is neither null nor empty.
+func IsNotNull(field string) Expr { return Compare(field, OpNeq, nil) }
+
+// In matches records where field equals any of the given values.
+//
+// An In without values never matches.
+//
+// Example:
+//
+//	dsl.In("status", "draft", "review") // (status = {:dslp0} || status = {:dslp1})
+func In(field string, values ...any) Expr {
+	exprs := make([]Expr, len(values))
+	for i, value := range values {
+		exprs[i] = Eq(field, value)
+	}
+	return groupExpr{join: "||", exprs: exprs, never: len(values) == 0}
+}
+
+// NotIn matches records where field equals none of the given values.
+//
+// A NotIn without values always matches.
+func NotIn(field string, values ...any) Expr {
+	return Not(In(field, values...))
+}
+
+// groupExpr joins several expressions with && or ||.
+type groupExpr struct {
+	join  string // "&&" or "||"
+	exprs []Expr
+	never bool // whether the empty group is a contradiction (empty In)
+}
+
+func (e groupExpr) compile(c *filterCompiler) (string, error) {
+	parts := make([]string, 0, len(e.exprs))
+	for _, expr := range e.exprs {
+		if expr == nil {
+			continue
+		}
+		part, err := expr.compile(c)
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		if e.never {
+			return "1 = 0", nil
+		}
+		return "", nil
+	case 1:
+		return parts[0], nil
+	}
+	return "(" + strings.Join(parts, " "+e.join+" ") + ")", nil
+}
+
+func (e groupExpr) negate() Expr {
+	// De Morgan: NOT(a && b) == (NOT a || NOT b)
+	negated := groupExpr{join: "&&", exprs: make([]Expr, 0, len(e.exprs))}
+	if e.join == "&&" {
+		negated.join = "||"
+	}
+	for _, expr := range e.exprs {
+		if expr != nil {
+			negated.exprs = append(negated.exprs, expr.negate())
+		}
+	}
+	if len(negated.exprs) == 0 && !e.never {
+		// NOT(always true) is never true
+		negated.never = true
+	}
+	return negated
+}
+
+// And matches records satisfying all of the given expressions.
+//
+// Nil and empty expressions are ignored, which makes it convenient to
+// combine optional conditions.
+//
+// Example:
+//
+//	dsl.And(dsl.Eq("status", "active"), dsl.Gte("price", 10))
+func And(exprs ...Expr) Expr {
+	return groupExpr{join: "&&", exprs: exprs}
+}
+
+// Or matches records satisfying at least one of the given expressions.
+//
+// Nil and empty expressions are ignored.
+//
+// Example:
+//
+//	dsl.Or(dsl.Eq("owner", userId), dsl.Eq("public", true))
+func Or(exprs ...Expr) Expr {
+	return groupExpr{join: "||", exprs: exprs}
+}
+
+// Not negates expr.
+//
+// PocketBase filters have no NOT operator, so the negation is pushed down
+// to the comparisons (De Morgan's laws, inverted operators). Raw
+// expressions can't be negated and produce a compile error.
+//
+// Example:
+//
+//	dsl.Not(dsl.In("status", "draft", "deleted")) // (status ?!= {:dslp0} && status ?!= {:dslp1})
+func Not(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+	return expr.negate()
+}
+
+// rawExpr is a hand-written filter fragment with its own parameters.
+type rawExpr struct {
+	filter  string
+	params  dbx.Params
+	negated bool
+}
+
+func (e rawExpr) compile(c *filterCompiler) (string, error) {
+	if e.negated {
+		return "", fmt.Errorf("cannot negate raw filter %q", e.filter)
+	}
+	if strings.TrimSpace(e.filter) == "" {
+		return "", nil
+	}
+	for name, value := range e.params {
+		if existing, exists := c.params[name]; exists && !reflect.DeepEqual(existing, value) {
+			return "", fmt.Errorf("placeholder {:%s} is bound to different values", name)
+		}
+		c.params[name] = value
+	}
+	return "(" + e.filter + ")", nil
+}
+
+func (e rawExpr) negate() Expr {
+	return rawExpr{filter: e.filter, params: e.params, negated: !e.negated}
+}
+
+// Raw wraps a hand-written PocketBase filter so it can be combined with
+// other expressions.
+//
+// The placeholders used in filter must be bound in params. Raw
+// expressions can't be negated with Not.
+//
+// Example:
+//
+//	dsl.And(
+//	    dsl.Raw("created >= @todayStart", nil),
+//	    dsl.Eq("status", "active"),
+//	)
+func Raw(filter string, params dbx.Params) Expr {
+	return rawExpr{filter: filter, params: params}
+}
+
+// Via builds the back-relation field path "collection_via_relField" used
+// to filter by records that reference the current one, optionally
+// followed by nested field names.
+//
+// Example:
+//
+//	// posts having at least one comment by userId
+//	dsl.AnyEq(dsl.Via("comments", "post", "author"), userId)
+//	// comments_via_post.author ?= {:dslp0}
+func Via(collection string, relField string, path ...string) string {
+	return Path(append([]string{collection + "_via_" + relField}, path...)...)
+}
+
+// Path joins relation field names into a dotted field path.
+//
+// Example:
+//
+//	dsl.Eq(dsl.Path("author", "profile", "country"), "NL")
+//	// author.profile.country = {:dslp0}
+func Path(fields ...string) string {
+	return strings.Join(fields, ".")
+}
+
+// Each applies the ":each" modifier to a multiple select or file field so
+// that comparisons are evaluated against its individual values.
+//
+// Example:
+//
+//	dsl.AnyEq(dsl.Each("tags"), "sale") // tags:each ?= {:dslp0}
+func Each(field string) string {
+	return field + ":each"
+}
+
+// Length applies the ":length" modifier to a multi-valued field so that
+// the number of its values can be compared.
+//
+// Example:
+//
+//	dsl.Gt(dsl.Length("tags"), 2) // tags:length > {:dslp0}
+func Length(field string) string {
+	return field + ":length"
+}
+
+// Lower applies the ":lower" modifier for case-insensitive comparisons.
+//
+// Example:
+//
+//	dsl.Eq(dsl.Lower("email"), "john@example.com")
+func Lower(field string) string {
+	return field + ":lower"
+}
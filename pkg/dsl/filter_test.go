@@ -0,0 +1,133 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestCompileFilter(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		expr     Expr
+		expected string
+		params   int
+	}{
+		{"eq", Eq("status", "active"), "status = {:dslp0}", 1},
+		{"any eq via", AnyEq(Via("comments", "post", "author"), "u1"), "comments_via_post.author ?= {:dslp0}", 1},
+		{"path", Gt(Path("author", "age"), 18), "author.age > {:dslp0}", 1},
+		{"is null", IsNull("owner"), "owner = null", 0},
+		{"and", And(Eq("a", 1), Lt("b", 2)), "(a = {:dslp0} && b < {:dslp1})", 2},
+		{"or nested", Or(Eq("a", 1), And(Like("b", "x"), Neq("c", 3))), "(a = {:dslp0} || (b ~ {:dslp1} && c != {:dslp2}))", 3},
+		{"in", In("status", "draft", "active"), "(status = {:dslp0} || status = {:dslp1})", 2},
+		{"empty in", In("status"), "1 = 0", 0},
+		{"empty not in", NotIn("status"), "", 0},
+		{"not in", NotIn("status", "draft", "active"), "(status ?!= {:dslp0} && status ?!= {:dslp1})", 2},
+		{"not any", Not(AnyEq(Each("tags"), "sale")), "tags:each != {:dslp0}", 1},
+		{"length", Gt(Length("tags"), 2), "tags:length > {:dslp0}", 1},
+		{"not is null", Not(IsNull("owner")), "owner != null", 0},
+		{"not or", Not(Or(Gt("a", 1), AnyLike("b", "x"))), "(a ?<= {:dslp0} && b !~ {:dslp1})", 2},
+		{"and skips empty", And(nil, And(), Eq("a", 1)), "a = {:dslp0}", 1},
+		{"raw", And(Raw("x = {:x}", dbx.Params{"x": 1}), Eq("y", 2)), "((x = {:x}) && y = {:dslp0})", 2},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			filter, params, err := CompileFilter(s.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if filter != s.expected {
+				t.Errorf("Expected filter '%s', got '%s'", s.expected, filter)
+			}
+			if len(params) != s.params {
+				t.Errorf("Expected %d params, got %d: %v", s.params, len(params), params)
+			}
+		})
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	if _, _, err := CompileFilter(Not(Raw("a = 1", nil))); err == nil {
+		t.Error("Expected error when negating a raw filter")
+	}
+
+	if _, _, err := CompileFilter(Eq(" ", 1)); !errors.Is(err, ErrEmptyFieldName) {
+		t.Errorf("Expected ErrEmptyFieldName, got %v", err)
+	}
+
+	expr := And(Raw("a = {:v}", dbx.Params{"v": 1}), Raw("b = {:v}", dbx.Params{"v": 2}))
+	if _, _, err := CompileFilter(expr); err == nil {
+		t.Error("Expected error for conflicting raw placeholder values")
+	}
+}
+
+func TestQueryBuilderAnd(t *testing.T) {
+	query := Query("status = {:status}").
+		Params(dbx.Params{"status": "active", "dslp0": "taken"}).
+		And(Gt("price", 10)).
+		And(Or(AnyEq(Each("tags"), "sale"), IsNull("tags")))
+
+	expected := "((status = {:status}) && (price > {:dslp1})) && ((tags:each ?= {:dslp2} || tags = null))"
+	if query.filter != expected {
+		t.Errorf("Expected filter '%s', got '%s'", expected, query.filter)
+	}
+	if query.params["dslp0"] != "taken" || query.params["dslp1"] != 10 || query.params["dslp2"] != "sale" {
+		t.Errorf("Unexpected params %v", query.params)
+	}
+
+	// copies of a base query don't write into its params
+	base := *Query("").And(Eq("status", "active"))
+	extended := base
+	extended.And(Eq("name", "a"))
+	rebound := base
+	rebound.Params(dbx.Params{"dslp0": "draft"})
+	if len(base.params) != 1 || base.params["dslp0"] != "active" {
+		t.Errorf("Expected the base params to be unchanged, got %v", base.params)
+	}
+	if len(extended.params) != 2 || rebound.params["dslp0"] != "draft" {
+		t.Errorf("Unexpected params of the copies %v and %v", extended.params, rebound.params)
+	}
+
+	failed := Where(Eq("", 1)).And(Eq("a", 1))
+	if !errors.Is(failed.err, ErrEmptyFieldName) {
+		t.Errorf("Expected deferred ErrEmptyFieldName, got %v", failed.err)
+	}
+}
+
+func TestWhereList(t *testing.T) {
+	app := newTestApp(t)
+
+	mustCreate(t, app, "products", map[string]any{"name": "a", "price": 5, "status": "active", "tags": []string{"sale"}})
+	mustCreate(t, app, "products", map[string]any{"name": "b", "price": 15, "status": "active", "tags": []string{"new", "hot"}})
+	mustCreate(t, app, "products", map[string]any{"name": "c", "price": 25, "status": "draft"})
+
+	scenarios := []struct {
+		name     string
+		query    *QueryBuilder
+		expected int
+	}{
+		{"eq", Where(Eq("status", "active")), 2},
+		{"in", Where(In("name", "a", "c")), 2},
+		{"not in", Where(NotIn("name", "a", "c")), 1},
+		{"any", Where(AnyEq(Each("tags"), "hot")), 1},
+		{"not any", Where(Not(AnyEq(Each("tags"), "hot"))), 2},
+		{"length", Where(Gte(Length("tags"), 1)), 2},
+		{"null", Where(IsNull("category")), 3},
+		{"not null", Where(Not(IsNull("category"))), 0},
+		{"raw and", Query("price > {:min}").Params(dbx.Params{"min": 10}).And(Eq("status", "active")), 1},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			records, err := Collection(app, "products").List(*s.query)
+			if err != nil {
+				t.Fatalf("Failed to list records: %v", err)
+			}
+			if len(records) != s.expected {
+				t.Errorf("Expected %d records, got %d", s.expected, len(records))
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package dsl
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"gopkg.in/yaml.v3"
+)
+
+// fixtureFilePrefix marks a fixture value as a file attachment.
+const fixtureFilePrefix = "@file:"
+
+// FixtureOptions configures LoadFixtures.
+type FixtureOptions struct {
+	FS    fs.FS // The filesystem the fixture paths are read from; the working directory when nil
+	Reset bool  // Whether the records of the fixture collections are deleted before loading
+}
+
+// FixtureResult reports the records loaded by LoadFixtures.
+type FixtureResult struct {
+	Records   map[string]*core.Record // The fixture records keyed by reference, e.g. "products.widget"
+	Created   int                     // Number of created records
+	Updated   int                     // Number of updated records
+	Unchanged int                     // Number of records that already matched their fixture
+	Deleted   int                     // Number of records deleted by Reset
+}
+
+// fixture is a single record of a fixture file.
+type fixture struct {
+	collection string         // The collection name
+	name       string         // The symbolic name of the record
+	file       string         // The fixture file path
+	data       map[string]any // The field values, with unresolved references
+	deps       []string       // The references of the record
+}
+
+// ref returns the reference of the fixture, e.g. "products.widget".
+func (f *fixture) ref() string {
+	return f.collection + "." + f.name
+}
+
+// LoadFixtures loads YAML or JSON fixture files into their collections in
+// a single transaction.
+//
+// paths are fixture files or directories, whose .yaml, .yml and .json
+// files are loaded in name order. A fixture file maps collection names to
+// named records:
+//
+//	categories:
+//	  tools:
+//	    name: Tools
+//	products:
+//	  widget:
+//	    name: Widget
+//	    price: 10
+//	    category: "@categories.tools"    # the id of another fixture record
+//	    images: ["@file:img/widget.png"] # relative to the fixture file
+//	    note: "@@home"                   # a literal "@home"
+//
+// References may point to records of any loaded file; the records are
+// created in dependency order, and reference cycles are reported as an
+// error. Each record gets a stable id derived from its reference (unless
+// it sets "id"), so loading the same fixtures again updates the records in
+// place and leaves unchanged ones alone; file fields of existing records
+// are only set when empty. Records are saved with the regular validation
+// and hooks.
+//
+// With Reset, all records of the fixture collections are deleted first
+// (dependent collections before the ones they reference) and the fixtures
+// are loaded from scratch, in the same transaction.
+//
+// Example:
+//
+//	result, err := dsl.LoadFixtures(app, []string{"fixtures"})
+//	widget := result.Records["products.widget"]
+//
+//	//go:embed testdata/fixtures
+//	var fixturesFS embed.FS
+//	_, err = dsl.LoadFixtures(app, []string{"testdata/fixtures"}, dsl.FixtureOptions{FS: fixturesFS, Reset: true})
+func LoadFixtures(app core.App, paths []string, opts ...FixtureOptions) (*FixtureResult, error) {
+	options := FixtureOptions{}
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	fsys := options.FS
+	if fsys == nil {
+		fsys = osFS{}
+	}
+
+	fixtures, err := readFixtures(fsys, paths)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := sortFixtures(fixtures)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FixtureResult{Records: map[string]*core.Record{}}
+	err = Transaction(app, func(tx *Tx) error {
+		if options.Reset {
+			if err := resetFixtureCollections(tx.App(), ordered, result); err != nil {
+				return err
+			}
+		}
+		ids := map[string]string{}
+		for _, f := range ordered {
+			if err := loadFixture(tx.App(), fsys, f, ids, result); err != nil {
+				return fmt.Errorf("fixture %s (%s): %w", f.ref(), f.file, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// osFS reads fixture paths relative to the working directory, or absolute
+// paths, from the OS filesystem.
+type osFS struct{}
+
+// Open implements fs.FS.
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.FromSlash(name))
+}
+
+// readFixtures reads the fixtures of the files and directories of paths.
+func readFixtures(fsys fs.FS, paths []string) ([]*fixture, error) {
+	files := []string{}
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := fs.ReadDir(fsys, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		for _, entry := range entries {
+			switch path.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, path.Join(p, entry.Name()))
+				}
+			}
+		}
+	}
+
+	fixtures := []*fixture{}
+	seen := map[string]string{}
+	for _, file := range files {
+		parsed, err := parseFixtureFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range parsed {
+			if other, ok := seen[f.ref()]; ok {
+				return nil, fmt.Errorf("fixture %s is defined in both %s and %s", f.ref(), other, file)
+			}
+			seen[f.ref()] = file
+			fixtures = append(fixtures, f)
+		}
+	}
+	return fixtures, nil
+}
+
+// parseFixtureFile parses a YAML or JSON fixture file, keeping the order
+// of its collections and records.
+func parseFixtureFile(fsys fs.FS, file string) ([]*fixture, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid fixture file %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid fixture file %s: expected a map of collections", file)
+	}
+	fixtures := []*fixture{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		collection, records := root.Content[i].Value, root.Content[i+1]
+		if records.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid fixture file %s: expected a map of %s records", file, collection)
+		}
+		for j := 0; j+1 < len(records.Content); j += 2 {
+			f := &fixture{collection: collection, name: records.Content[j].Value, file: file}
+			if err := records.Content[j+1].Decode(&f.data); err != nil {
+				return nil, fmt.Errorf("invalid fixture %s in %s: %w", f.ref(), file, err)
+			}
+			if f.data == nil {
+				f.data = map[string]any{}
+			}
+			f.deps = fixtureDeps(f.data)
+			fixtures = append(fixtures, f)
+		}
+	}
+	return fixtures, nil
+}
+
+// fixtureDeps returns the references of the field values of data.
+func fixtureDeps(data map[string]any) []string {
+	deps := []string{}
+	for _, value := range data {
+		for _, s := range fixtureStrings(value) {
+			if ref, ok := fixtureRef(s); ok && !slices.Contains(deps, ref) {
+				deps = append(deps, ref)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// fixtureStrings returns value if it is a string, or the strings of a
+// list value.
+func fixtureStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		strs := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// fixtureRef returns the reference of a "@collection.name" value.
+func fixtureRef(value string) (string, bool) {
+	if !strings.HasPrefix(value, "@") || strings.HasPrefix(value, "@@") || strings.HasPrefix(value, fixtureFilePrefix) {
+		return "", false
+	}
+	return value[1:], true
+}
+
+// sortFixtures orders fixtures so that every record comes after the
+// records it references, keeping the file order otherwise.
+func sortFixtures(fixtures []*fixture) ([]*fixture, error) {
+	byRef := make(map[string]*fixture, len(fixtures))
+	for _, f := range fixtures {
+		byRef[f.ref()] = f
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	ordered := make([]*fixture, 0, len(fixtures))
+	var visit func(f *fixture, path []string) error
+	visit = func(f *fixture, path []string) error {
+		switch state[f.ref()] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("fixture reference cycle: %s", strings.Join(append(path, f.ref()), " -> "))
+		}
+		state[f.ref()] = visiting
+		for _, dep := range f.deps {
+			target, ok := byRef[dep]
+			if !ok {
+				return fmt.Errorf("fixture %s (%s) references unknown fixture @%s", f.ref(), f.file, dep)
+			}
+			if err := visit(target, append(path, f.ref())); err != nil {
+				return err
+			}
+		}
+		state[f.ref()] = done
+		ordered = append(ordered, f)
+		return nil
+	}
+	for _, f := range fixtures {
+		if err := visit(f, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// fixtureId returns the stable record id of a fixture reference.
+func fixtureId(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return hex.EncodeToString(sum[:])[:15]
+}
+
+// resetFixtureCollections deletes the records of the collections of the
+// ordered fixtures, dependent collections first.
+func resetFixtureCollections(app core.App, ordered []*fixture, result *FixtureResult) error {
+	collections := []string{}
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if !slices.Contains(collections, ordered[i].collection) {
+			collections = append(collections, ordered[i].collection)
+		}
+	}
+	for _, name := range collections {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			return collectionError(err)
+		}
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := app.Delete(record); err != nil {
+				return fmt.Errorf("failed to reset %s: %w", name, saveError(name, err))
+			}
+			result.Deleted++
+		}
+	}
+	return nil
+}
+
+// loadFixture creates or updates the record of f, resolving its references
+// with ids, and adds its id to ids.
+func loadFixture(app core.App, fsys fs.FS, f *fixture, ids map[string]string, result *FixtureResult) error {
+	collection, err := app.FindCachedCollectionByNameOrId(f.collection)
+	if err != nil {
+		return collectionError(err)
+	}
+
+	id, _ := f.data[core.FieldNameId].(string)
+	if id == "" {
+		id = fixtureId(f.ref())
+	}
+	record, err := app.FindRecordById(collection, id)
+	existing := err == nil
+	if !existing {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		record = core.NewRecord(collection)
+		record.Id = id
+	}
+
+	for name, value := range f.data {
+		if name == core.FieldNameId {
+			continue
+		}
+		field := collection.Fields.GetByName(name)
+		if field == nil {
+			// PocketBase would keep the value as custom data and drop it on save
+			return fmt.Errorf("unknown field %q: %s has no such field", name, collection.Name)
+		}
+		if existing {
+			if _, ok := field.(*core.FileField); ok && !isEmptyValue(record.Get(name)) {
+				continue // files are only uploaded once
+			}
+			if _, ok := field.(*core.PasswordField); ok {
+				if password, ok := value.(string); ok && record.ValidatePassword(password) {
+					continue // keep the hash of an unchanged password
+				}
+			}
+		}
+		resolved, err := resolveFixtureValue(fsys, f, value, ids)
+		if err != nil {
+			return err
+		}
+		record.Set(name, resolved)
+	}
+
+	changed := !existing || len(recordChanges(record)) > 0
+	switch {
+	case !existing:
+		result.Created++
+	case changed:
+		result.Updated++
+	default:
+		result.Unchanged++
+	}
+	if changed {
+		if err := Collection(app, collection.Name).save(record); err != nil {
+			return err
+		}
+	}
+	ids[f.ref()] = record.Id
+	result.Records[f.ref()] = record
+	return nil
+}
+
+// resolveFixtureValue replaces the references, files and escapes of a
+// fixture value.
+func resolveFixtureValue(fsys fs.FS, f *fixture, value any, ids map[string]string) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveFixtureString(fsys, f, v, ids)
+	case []any:
+		resolved := make([]any, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				resolved[i] = item
+				continue
+			}
+			r, err := resolveFixtureString(fsys, f, s, ids)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// resolveFixtureString resolves a single string value of a fixture.
+func resolveFixtureString(fsys fs.FS, f *fixture, value string, ids map[string]string) (any, error) {
+	switch {
+	case strings.HasPrefix(value, "@@"):
+		return value[1:], nil
+	case strings.HasPrefix(value, fixtureFilePrefix):
+		name := path.Join(path.Dir(f.file), strings.TrimPrefix(value, fixtureFilePrefix))
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return filesystem.NewFileFromBytes(data, path.Base(name))
+	}
+	if ref, ok := fixtureRef(value); ok {
+		return ids[ref], nil
+	}
+	return value, nil
+}
@@ -0,0 +1,213 @@
+package dsl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newFixturesApp returns a test app whose products have an image and a
+// note field.
+func newFixturesApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	app := newTestApp(t)
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(
+		&core.FileField{Name: "image", MaxSelect: 1, MaxSize: 1 << 20},
+		&core.TextField{Name: "note"},
+	)
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	return app
+}
+
+// testFixtures returns fixtures of two tools in a YAML and a JSON file.
+func testFixtures() fstest.MapFS {
+	return fstest.MapFS{
+		"fixtures/1-products.yaml": {Data: []byte(`
+products:
+  hammer:
+    name: Hammer
+    price: 10
+    status: active
+    tags: [new, sale]
+    category: "@categories.tools"
+    image: "@file:img/hammer.png"
+    note: "@@home"
+  saw:
+    name: Saw
+    price: 20
+    category: "@categories.tools"
+`)},
+		"fixtures/2-categories.json": {Data: []byte(`{"categories": {"tools": {"name": "Tools"}}}`)},
+		"fixtures/img/hammer.png":    {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"fixtures/README.md":         {Data: []byte("ignored")},
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	app := newFixturesApp(t)
+	fsys := testFixtures()
+
+	result, err := LoadFixtures(app, []string{"fixtures"}, FixtureOptions{FS: fsys})
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	if result.Created != 3 || result.Updated != 0 || len(result.Records) != 3 {
+		t.Errorf("Expected 3 created records, got %+v", result)
+	}
+
+	// references across files are resolved in dependency order
+	tools := result.Records["categories.tools"]
+	hammer, err := Collection(app, "products").One(result.Records["products.hammer"].Id)
+	if err != nil {
+		t.Fatalf("Failed to fetch hammer: %v", err)
+	}
+	if hammer.GetString("category") != tools.Id {
+		t.Errorf("Expected the category %s, got %s", tools.Id, hammer.GetString("category"))
+	}
+	if tags := hammer.GetStringSlice("tags"); len(tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", tags)
+	}
+	if note := hammer.GetString("note"); note != "@home" {
+		t.Errorf("Expected the escaped note @home, got %q", note)
+	}
+	if image := hammer.GetString("image"); !strings.HasPrefix(image, "hammer") || !strings.HasSuffix(image, ".png") {
+		t.Errorf("Expected the uploaded hammer image, got %q", image)
+	}
+
+	// loading again leaves the records alone
+	result, err = LoadFixtures(app, []string{"fixtures"}, FixtureOptions{FS: fsys})
+	if err != nil {
+		t.Fatalf("Failed to reload fixtures: %v", err)
+	}
+	if result.Unchanged != 3 || result.Created != 0 || result.Updated != 0 {
+		t.Errorf("Expected 3 unchanged records, got %+v", result)
+	}
+	if count := mustCount(t, app, "products"); count != 2 {
+		t.Errorf("Expected 2 products, got %d", count)
+	}
+	if image := result.Records["products.hammer"].GetString("image"); image != hammer.GetString("image") {
+		t.Errorf("Expected the image to be kept, got %q", image)
+	}
+
+	// and updates the changed ones
+	fsys["fixtures/2-categories.json"] = &fstest.MapFile{Data: []byte(`{"categories": {"tools": {"name": "Hand tools"}}}`)}
+	result, err = LoadFixtures(app, []string{"fixtures"}, FixtureOptions{FS: fsys})
+	if err != nil {
+		t.Fatalf("Failed to reload fixtures: %v", err)
+	}
+	if result.Updated != 1 || result.Unchanged != 2 {
+		t.Errorf("Expected 1 updated record, got %+v", result)
+	}
+	if result.Records["categories.tools"].Id != tools.Id {
+		t.Errorf("Expected the same category id, got %s", result.Records["categories.tools"].Id)
+	}
+}
+
+func TestLoadFixturesFromDisk(t *testing.T) {
+	app := newFixturesApp(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "categories.yml")
+	if err := os.WriteFile(file, []byte("categories:\n  tools:\n    id: tools0000000000\n    name: Tools\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write fixtures: %v", err)
+	}
+
+	result, err := LoadFixtures(app, []string{file})
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	if id := result.Records["categories.tools"].Id; id != "tools0000000000" {
+		t.Errorf("Expected the explicit id, got %s", id)
+	}
+}
+
+func TestLoadFixturesReset(t *testing.T) {
+	app := newFixturesApp(t)
+	fsys := testFixtures()
+	if _, err := LoadFixtures(app, []string{"fixtures"}, FixtureOptions{FS: fsys}); err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	mustCreate(t, app, "products", map[string]any{"name": "drill"})
+	if _, err := Collection(app, "products").Update(fixtureId("products.saw"), map[string]any{"price": 99}); err != nil {
+		t.Fatalf("Failed to update saw: %v", err)
+	}
+
+	result, err := LoadFixtures(app, []string{"fixtures"}, FixtureOptions{FS: fsys, Reset: true})
+	if err != nil {
+		t.Fatalf("Failed to reset fixtures: %v", err)
+	}
+	if result.Deleted != 4 || result.Created != 3 {
+		t.Errorf("Expected 4 deleted and 3 created records, got %+v", result)
+	}
+	records, err := Collection(app, "products").List(*Query("").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list products: %v", err)
+	}
+	if names := recordNames(records); names != "Hammer,Saw" {
+		t.Errorf("Expected only the fixture products, got %s", names)
+	}
+	if price := records[1].GetInt("price"); price != 20 {
+		t.Errorf("Expected the fixture price 20, got %d", price)
+	}
+}
+
+func TestLoadFixturesErrors(t *testing.T) {
+	app := newFixturesApp(t)
+
+	cases := map[string]struct {
+		files   fstest.MapFS
+		message string
+	}{
+		"unknown reference": {fstest.MapFS{
+			"f.yaml": {Data: []byte("products:\n  saw:\n    category: \"@categories.missing\"\n")},
+		}, "unknown fixture @categories.missing"},
+		"cycle": {fstest.MapFS{
+			"f.yaml": {Data: []byte("products:\n  saw:\n    category: \"@categories.tools\"\ncategories:\n  tools:\n    name: \"@products.saw\"\n")},
+		}, "cycle"},
+		"duplicate": {fstest.MapFS{
+			"a.yaml": {Data: []byte("categories:\n  tools:\n    name: a\n")},
+			"b.yaml": {Data: []byte("categories:\n  tools:\n    name: b\n")},
+		}, "defined in both"},
+		"invalid file": {fstest.MapFS{
+			"f.yaml": {Data: []byte("- a\n- b\n")},
+		}, "expected a map"},
+		"missing file": {fstest.MapFS{
+			"f.yaml": {Data: []byte("products:\n  saw:\n    image: \"@file:missing.png\"\n")},
+		}, "missing.png"},
+		"unknown field": {fstest.MapFS{
+			"f.yaml": {Data: []byte("products:\n  saw:\n    name: saw\n    prise: 10\n")},
+		}, `fixture products.saw (f.yaml): unknown field "prise"`},
+	}
+	for name, c := range cases {
+		if _, err := LoadFixtures(app, []string{"."}, FixtureOptions{FS: c.files}); err == nil || !strings.Contains(err.Error(), c.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, c.message, err)
+		}
+	}
+
+	// a failing record rolls back the whole load
+	files := fstest.MapFS{
+		"f.yaml": {Data: []byte("categories:\n  tools:\n    name: Tools\nproducts:\n  saw:\n    status: bogus\n")},
+	}
+	_, err := LoadFixtures(app, []string{"f.yaml"}, FixtureOptions{FS: files})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "products.saw") {
+		t.Errorf("Expected the validation error of products.saw, got %v", err)
+	}
+	if count := mustCount(t, app, "categories"); count != 0 {
+		t.Errorf("Expected the categories to be rolled back, got %d", count)
+	}
+
+	files = fstest.MapFS{"f.yaml": {Data: []byte("missing:\n  a:\n    name: a\n")}}
+	if _, err := LoadFixtures(app, []string{"f.yaml"}, FixtureOptions{FS: files}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,437 @@
+package dsl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// errImportRollback rolls back the transaction of a dry run or a failed
+// import.
+var errImportRollback = errors.New("import rolled back")
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Mapping maps input columns to field names. Columns that aren't
+	// listed are imported into the field of the same name; a column
+	// mapped to "-" is skipped.
+	Mapping map[string]string
+
+	// UpsertKey lists the fields identifying existing records: rows whose
+	// key values match a record update it, the others create a record.
+	// By default every row creates a record.
+	UpsertKey []string
+
+	// DryRun validates every row, with the regular validation and hooks,
+	// and rolls everything back. The result reports what would have been
+	// imported.
+	DryRun bool
+
+	// ContinueOnError imports the valid rows even if some rows fail. By
+	// default nothing is imported when a row fails.
+	ContinueOnError bool
+}
+
+// ImportResult reports the outcome of an import.
+type ImportResult struct {
+	Rows           int               // Number of rows read
+	Created        int               // Number of created records
+	Updated        int               // Number of updated records
+	Errors         []*ImportRowError // Failures of individual rows
+	IgnoredColumns []string          // Input columns without a matching field
+}
+
+// ImportRowError describes the failure of a single row of an import.
+type ImportRowError struct {
+	Line int   // Line of the row in the input, starting at 1
+	Err  error // The underlying error, e.g. a *ValidationError
+}
+
+// Error implements the error interface.
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+// importReader reads the rows of an import.
+type importReader interface {
+	// next returns the line and the values of the next row, io.EOF at the
+	// end of the input, or an *ImportRowError for an invalid row. Other
+	// errors abort the import.
+	next() (int, map[string]any, error)
+}
+
+// Import streams the rows of r in the given format into the collection in
+// a single transaction and reports the outcome of every row.
+//
+// CSV input needs a header row naming the columns; JSON Lines input has one
+// object per line. Values are converted to the type of their field: CSV
+// cells (and JSON strings) are parsed as numbers, booleans and dates, and
+// multiple select and relation values are read from JSON arrays or
+// comma-separated lists. Columns without a matching field, autodate
+// fields and file fields are ignored and reported in the result, so an
+// Export can be imported back.
+//
+// Records are saved with the regular validation and hooks (and the API
+// rules of an As builder). Every row runs in its own savepoint, so all
+// failing rows are reported; unless ContinueOnError is set, a failing row
+// rolls back the whole import and the first failure is returned. With
+// DryRun nothing is ever written.
+//
+// Example:
+//
+//	f, err := os.Open("products.csv")
+//	defer f.Close()
+//	result, err := dsl.Collection(app, "products").Import(f, dsl.FormatCSV, dsl.ImportOptions{
+//	    Mapping:   map[string]string{"SKU": "sku", "Product name": "name"},
+//	    UpsertKey: []string{"sku"},
+//	    DryRun:    true,
+//	})
+//	for _, rowErr := range result.Errors {
+//	    log.Println(rowErr) // line 7: invalid products record: price: Must be no less than 0.
+//	}
+func (c *CollectionQueryBuilder) Import(r io.Reader, format Format, opts ...ImportOptions) (*ImportResult, error) {
+	var options ImportOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if err := c.checkContext(); err != nil {
+		return nil, err
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+
+	columns := &importColumns{collection: collection, mapping: options.Mapping, fields: map[string]core.Field{}}
+	var reader importReader
+	switch format {
+	case FormatCSV:
+		reader, err = newCSVImportReader(r, columns)
+		if err != nil {
+			return nil, err
+		}
+	case FormatJSONL:
+		reader = &jsonlImportReader{r: bufio.NewReader(r)}
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+
+	result := &ImportResult{}
+	err = Transaction(c.app, func(tx *Tx) error {
+		for {
+			if err := c.checkContext(); err != nil {
+				return err
+			}
+			line, values, err := reader.next()
+			if err == io.EOF {
+				break
+			}
+			var rowErr *ImportRowError
+			if err != nil && !errors.As(err, &rowErr) {
+				return err
+			}
+			result.Rows++
+			if rowErr == nil {
+				err = tx.Transaction(func(tx *Tx) error {
+					return c.withApp(tx.App()).importRow(result, columns, values, options)
+				})
+				if err != nil {
+					rowErr = &ImportRowError{Line: line, Err: err}
+				}
+			}
+			if rowErr != nil {
+				result.Errors = append(result.Errors, rowErr)
+			}
+		}
+		if options.DryRun || (len(result.Errors) > 0 && !options.ContinueOnError) {
+			return errImportRollback
+		}
+		return nil
+	})
+	result.IgnoredColumns = columns.ignoredColumns()
+
+	if errors.Is(err, errImportRollback) {
+		if options.DryRun {
+			return result, nil
+		}
+		result.Created, result.Updated = 0, 0
+		return result, fmt.Errorf("%d of %d rows failed, nothing was imported: %w", len(result.Errors), result.Rows, result.Errors[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importRow creates or upserts the record of a row.
+func (c *CollectionQueryBuilder) importRow(result *ImportResult, columns *importColumns, values map[string]any, options ImportOptions) error {
+	data, err := columns.data(values)
+	if err != nil {
+		return err
+	}
+	if len(options.UpsertKey) == 0 {
+		if _, err := c.Create(data); err != nil {
+			return err
+		}
+		result.Created++
+		return nil
+	}
+	_, created, err := c.Upsert(options.UpsertKey, data)
+	if err != nil {
+		return err
+	}
+	if created {
+		result.Created++
+	} else {
+		result.Updated++
+	}
+	return nil
+}
+
+// importColumns maps the input columns of an import to collection fields.
+type importColumns struct {
+	collection *core.Collection
+	mapping    map[string]string
+	fields     map[string]core.Field // Resolved columns; nil for ignored ones
+}
+
+// field returns the field column is imported into, or nil if the column is
+// ignored.
+func (ic *importColumns) field(column string) core.Field {
+	if field, ok := ic.fields[column]; ok {
+		return field
+	}
+	name := column
+	if mapped, ok := ic.mapping[column]; ok {
+		name = mapped
+	}
+	var field core.Field
+	if name != "-" {
+		field = ic.collection.Fields.GetByName(name)
+		switch field.(type) {
+		case *core.AutodateField, *core.FileField:
+			field = nil
+		}
+	}
+	ic.fields[column] = field
+	return field
+}
+
+// ignoredColumns returns the sorted input columns without a field, except
+// the ones explicitly skipped by the mapping.
+func (ic *importColumns) ignoredColumns() []string {
+	var columns []string
+	for column, field := range ic.fields {
+		if field == nil && ic.mapping[column] != "-" {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// data converts the values of a row to record data, collecting the
+// conversion errors of all fields in a *ValidationError.
+func (ic *importColumns) data(values map[string]any) (map[string]any, error) {
+	data := make(map[string]any, len(values))
+	errs := validation.Errors{}
+	for column, value := range values {
+		field := ic.field(column)
+		if field == nil {
+			continue
+		}
+		converted, err := importValue(field, value)
+		if err != nil {
+			errs[field.GetName()] = validation.NewError("validation_invalid_format", err.Error())
+			continue
+		}
+		data[field.GetName()] = converted
+	}
+	if len(errs) > 0 {
+		return nil, saveError(ic.collection.Name, errs)
+	}
+	return data, nil
+}
+
+// importValue converts value to the type of field. Strings are parsed,
+// other JSON values are kept as is.
+func importValue(field core.Field, value any) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	s = strings.TrimSpace(s)
+
+	switch f := field.(type) {
+	case *core.NumberField:
+		if s == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return n, nil
+	case *core.BoolField:
+		if s == "" {
+			return false, nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", s)
+		}
+		return b, nil
+	case *core.DateField:
+		if s == "" {
+			return "", nil
+		}
+		date, err := types.ParseDateTime(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", s)
+		}
+		return date, nil
+	case *core.SelectField:
+		if f.IsMultiple() {
+			return splitValues(s)
+		}
+	case *core.RelationField:
+		if f.IsMultiple() {
+			return splitValues(s)
+		}
+	case *core.JSONField, *core.GeoPointField:
+		if s == "" {
+			return nil, nil
+		}
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid JSON %q", s)
+		}
+		return types.JSONRaw(s), nil
+	}
+	return value, nil
+}
+
+// splitValues parses a JSON array or a comma-separated list of values.
+func splitValues(s string) ([]string, error) {
+	if strings.HasPrefix(s, "[") {
+		var values []string
+		if err := json.Unmarshal([]byte(s), &values); err != nil {
+			return nil, fmt.Errorf("invalid list %q", s)
+		}
+		return values, nil
+	}
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// csvImportReader reads CSV rows keyed by the header columns.
+type csvImportReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// newCSVImportReader reads the header row of r.
+func newCSVImportReader(r io.Reader, columns *importColumns) (*csvImportReader, error) {
+	reader := &csvImportReader{r: csv.NewReader(r)}
+	header, err := reader.r.Read()
+	if err == io.EOF {
+		return reader, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark of spreadsheet exports
+	}
+	for i, column := range header {
+		if slices.Contains(header[:i], column) {
+			return nil, fmt.Errorf("duplicate CSV column %q", column)
+		}
+		columns.field(column)
+	}
+	reader.header = header
+	return reader, nil
+}
+
+func (cr *csvImportReader) next() (int, map[string]any, error) {
+	if cr.header == nil {
+		return 0, nil, io.EOF
+	}
+	row, err := cr.r.Read()
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		if errors.Is(err, csv.ErrFieldCount) {
+			return parseErr.StartLine, nil, &ImportRowError{
+				Line: parseErr.StartLine,
+				Err:  fmt.Errorf("expected %d columns, got %d", len(cr.header), len(row)),
+			}
+		}
+		return 0, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	values := make(map[string]any, len(row))
+	for i, column := range cr.header {
+		values[column] = row[i]
+	}
+	return line, values, nil
+}
+
+// jsonlImportReader reads JSON objects, one per line.
+type jsonlImportReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (jr *jsonlImportReader) next() (int, map[string]any, error) {
+	for {
+		data, err := jr.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		if len(data) == 0 && err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		jr.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return 0, nil, io.EOF
+			}
+			continue
+		}
+
+		var values map[string]any
+		if err := json.Unmarshal(data, &values); err != nil || values == nil {
+			return jr.line, nil, &ImportRowError{Line: jr.line, Err: errors.New("expected a JSON object")}
+		}
+		return jr.line, values, nil
+	}
+}
@@ -0,0 +1,155 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	app := newTestApp(t)
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	c := Collection(app, "products")
+
+	input := "\ufeffProduct,price,tags,status,category,created,extra\n" +
+		"hammer, 10.5 ,\"new, sale\",active," + tools.Id + ",2020-01-01,x\n" +
+		"saw,20,\"[\"\"hot\"\"]\",,,,\n"
+	result, err := c.Import(strings.NewReader(input), FormatCSV, ImportOptions{Mapping: map[string]string{"Product": "name"}})
+	if err != nil {
+		t.Fatalf("Failed to import CSV: %v", err)
+	}
+	if result.Rows != 2 || result.Created != 2 || len(result.Errors) != 0 {
+		t.Errorf("Expected 2 created records, got %+v", result)
+	}
+	if ignored := strings.Join(result.IgnoredColumns, ","); ignored != "created,extra" {
+		t.Errorf("Expected the ignored columns created,extra, got %s", ignored)
+	}
+	hammer, err := c.First(*Query("name = 'hammer'"))
+	if err != nil {
+		t.Fatalf("Failed to fetch hammer: %v", err)
+	}
+	if hammer.GetFloat("price") != 10.5 || hammer.GetString("category") != tools.Id || strings.Join(hammer.GetStringSlice("tags"), ",") != "new,sale" {
+		t.Errorf("Expected the converted values, got %v", hammer.FieldsData())
+	}
+	saw, _ := c.First(*Query("name = 'saw'"))
+	if tags := saw.GetStringSlice("tags"); len(tags) != 1 || tags[0] != "hot" {
+		t.Errorf("Expected the tags from a JSON array, got %v", tags)
+	}
+
+	// upsert by key from JSON Lines, with blank lines and string numbers
+	input = `{"name": "hammer", "price": "12", "tags": ["hot"]}
+
+{"name": "drill", "price": 5, "category": "` + tools.Id + `"}
+`
+	result, err = c.Import(strings.NewReader(input), FormatJSONL, ImportOptions{UpsertKey: []string{"name"}})
+	if err != nil {
+		t.Fatalf("Failed to import JSONL: %v", err)
+	}
+	if result.Rows != 2 || result.Created != 1 || result.Updated != 1 {
+		t.Errorf("Expected 1 created and 1 updated record, got %+v", result)
+	}
+	hammer, _ = c.One(hammer.Id)
+	if hammer.GetFloat("price") != 12 || hammer.GetString("status") != "active" {
+		t.Errorf("Expected the updated price and the kept status, got %v", hammer.FieldsData())
+	}
+	if count := mustCount(t, app, "products"); count != 3 {
+		t.Errorf("Expected 3 products, got %d", count)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+	input := "name,price,status\n" +
+		"ok,1,active\n" +
+		"bad,abc,active\n" +
+		"worse,2,bogus\n" +
+		"short,1\n"
+
+	// by default a failing row rolls back everything, and all rows are reported
+	result, err := c.Import(strings.NewReader(input), FormatCSV)
+	var rowErr *ImportRowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 3 {
+		t.Fatalf("Expected the error of line 3, got %v", err)
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields["price"].Code != "validation_invalid_format" {
+		t.Errorf("Expected a validation error of the price, got %v", err)
+	}
+	if result.Rows != 4 || result.Created != 0 || len(result.Errors) != 3 {
+		t.Fatalf("Expected 3 row errors and no records, got %+v", result)
+	}
+	for i, line := range []int{3, 4, 5} {
+		if result.Errors[i].Line != line {
+			t.Errorf("Expected an error at line %d, got %v", line, result.Errors[i])
+		}
+	}
+	if !errors.As(result.Errors[1], &validationErr) || validationErr.Fields["status"].Code == "" {
+		t.Errorf("Expected a validation error of the status, got %v", result.Errors[1])
+	}
+	if count := mustCount(t, app, "products"); count != 0 {
+		t.Errorf("Expected nothing to be imported, got %d records", count)
+	}
+
+	// a dry run reports without writing
+	result, err = c.Import(strings.NewReader(input), FormatCSV, ImportOptions{DryRun: true})
+	if err != nil || result.Created != 1 || len(result.Errors) != 3 {
+		t.Errorf("Expected 1 valid row and 3 errors, got %+v (%v)", result, err)
+	}
+	if count := mustCount(t, app, "products"); count != 0 {
+		t.Errorf("Expected a dry run to write nothing, got %d records", count)
+	}
+
+	// ContinueOnError imports the valid rows
+	result, err = c.Import(strings.NewReader(input), FormatCSV, ImportOptions{ContinueOnError: true})
+	if err != nil || result.Created != 1 || len(result.Errors) != 3 {
+		t.Errorf("Expected 1 created record and 3 errors, got %+v (%v)", result, err)
+	}
+	if count := mustCount(t, app, "products"); count != 1 {
+		t.Errorf("Expected the valid row to be imported, got %d records", count)
+	}
+
+	result, err = c.Import(strings.NewReader("{\"name\": \"a\"}\n[1]\nnot json\n"), FormatJSONL, ImportOptions{DryRun: true})
+	if err != nil || len(result.Errors) != 2 || result.Errors[0].Line != 2 || result.Errors[1].Line != 3 {
+		t.Errorf("Expected errors at lines 2 and 3, got %+v (%v)", result, err)
+	}
+	if _, err := c.Import(strings.NewReader("name\n\"unterminated\n"), FormatCSV); err == nil || errors.As(err, &rowErr) {
+		t.Errorf("Expected a CSV syntax error, got %v", err)
+	}
+	if _, err := c.Import(strings.NewReader("name,name\n"), FormatCSV); err == nil {
+		t.Error("Expected error for duplicate columns")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	app := newTestApp(t)
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10, "tags": []string{"new", "sale"}, "category": tools.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "status": "active"})
+
+	for _, format := range []Format{FormatCSV, FormatJSONL} {
+		var out strings.Builder
+		c := Collection(app, "products")
+		if err := c.Export(*Query("").Expand("category"), format, &out); err != nil {
+			t.Fatalf("%s: failed to export: %v", format, err)
+		}
+		if _, err := c.Update(hammer.Id, map[string]any{"price": 99, "tags": []string{}}); err != nil {
+			t.Fatalf("%s: failed to update hammer: %v", format, err)
+		}
+
+		result, err := c.Import(strings.NewReader(out.String()), format, ImportOptions{UpsertKey: []string{"id"}})
+		if err != nil {
+			t.Fatalf("%s: failed to import: %v", format, err)
+		}
+		if result.Updated != 2 || result.Created != 0 {
+			t.Errorf("%s: expected 2 updated records, got %+v", format, result)
+		}
+		if ignored := strings.Join(result.IgnoredColumns, ","); ignored != "category.id,category.name,created,updated" {
+			t.Errorf("%s: expected the expanded and autodate columns to be ignored, got %s", format, ignored)
+		}
+		record, _ := c.One(hammer.Id)
+		if record.GetInt("price") != 10 || len(record.GetStringSlice("tags")) != 2 {
+			t.Errorf("%s: expected the exported values to be restored, got %v", format, record.FieldsData())
+		}
+	}
+}
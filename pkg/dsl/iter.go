@@ -0,0 +1,228 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultChunkSize is the number of records loaded per chunk by Each,
+// EachChunk, Iter and Seq when the query doesn't specify a perPage value.
+const DefaultChunkSize = 500
+
+// ErrStopIteration can be returned from an Each or EachChunk callback to
+// stop the iteration early without reporting an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+// chunkQuery prepares query for chunked iteration: the chunk size defaults
+// to DefaultChunkSize and a previous Before cursor is discarded.
+func chunkQuery(query QueryBuilder) QueryBuilder {
+	if query.perPage <= 0 {
+		query.perPage = DefaultChunkSize
+	}
+	if query.cursorBefore {
+		query.cursor = ""
+		query.cursorBefore = false
+	}
+	return query
+}
+
+// EachChunk walks all records matching the query in chunks, using keyset
+// pagination so that memory stays bounded and records aren't skipped or
+// repeated even if the callback modifies or deletes them.
+//
+// The chunk size is taken from the perPage value of Page and defaults to
+// DefaultChunkSize. Relations listed in Expand are expanded per chunk. An
+// After cursor, if set, is used as the starting point.
+//
+// Iteration stops when fn returns an error or ctx is done. Returning
+// ErrStopIteration stops without error; ctx errors are returned as is.
+//
+// Example:
+//
+//	query := dsl.Query("status = 'expired'").Sort("created").Page(0, 1000)
+//	err := dsl.Collection(app, "orders").EachChunk(ctx, *query, func(records []*core.Record) error {
+//	    return archive(records)
+//	})
+func (c *CollectionQueryBuilder) EachChunk(ctx context.Context, query QueryBuilder, fn func(records []*core.Record) error, params ...dbx.Params) error {
+	query = chunkQuery(query)
+	c = c.WithContext(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := c.ListCursor(query, params...)
+		if err != nil {
+			return err
+		}
+		if len(page.Items) > 0 {
+			if err := fn(page.Items); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		if !page.HasNext {
+			return nil
+		}
+		query.After(page.NextCursor)
+	}
+}
+
+// Each calls fn for every record matching the query, loading the records
+// in chunks. See EachChunk for the iteration rules.
+//
+// Example:
+//
+//	err := dsl.Collection(app, "products").Each(ctx, *dsl.Query(""), func(record *core.Record) error {
+//	    return app.Delete(record)
+//	})
+func (c *CollectionQueryBuilder) Each(ctx context.Context, query QueryBuilder, fn func(record *core.Record) error, params ...dbx.Params) error {
+	return c.EachChunk(ctx, query, func(records []*core.Record) error {
+		for _, record := range records {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, params...)
+}
+
+// RecordIterator iterates over the records matching a query, loading them
+// in chunks. It is created with CollectionQueryBuilder.Iter.
+//
+// Example:
+//
+//	it := dsl.Collection(app, "products").Iter(ctx, *dsl.Query("").Sort("name"))
+//	for it.Next() {
+//	    record := it.Record()
+//	    // ...
+//	}
+//	if err := it.Err(); err != nil {
+//	    // Handle error
+//	}
+type RecordIterator struct {
+	ctx        context.Context         // The iteration context
+	collection *CollectionQueryBuilder // The iterated collection
+	query      QueryBuilder            // The query, advanced after every chunk
+	params     []dbx.Params            // The query parameters
+	chunk      []*core.Record          // The currently loaded chunk
+	index      int                     // The position in the current chunk
+	done       bool                    // Whether there are no more chunks
+	err        error                   // The first error encountered
+}
+
+// Iter returns an iterator over the records matching the query.
+//
+// See EachChunk for how chunks are loaded.
+func (c *CollectionQueryBuilder) Iter(ctx context.Context, query QueryBuilder, params ...dbx.Params) *RecordIterator {
+	return &RecordIterator{
+		ctx:        ctx,
+		collection: c.WithContext(ctx),
+		query:      chunkQuery(query),
+		params:     params,
+		index:      -1,
+	}
+}
+
+// Next advances the iterator to the next record, loading the next chunk
+// when needed. It returns false when there are no more records or an error
+// occurred.
+func (it *RecordIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	it.index++
+	for it.index >= len(it.chunk) {
+		if it.done {
+			return false
+		}
+		page, err := it.collection.ListCursor(it.query, it.params...)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.chunk = page.Items
+		it.index = 0
+		it.done = !page.HasNext
+		it.query.After(page.NextCursor)
+	}
+	return true
+}
+
+// Record returns the current record.
+func (it *RecordIterator) Record() *core.Record {
+	if it.index < 0 || it.index >= len(it.chunk) {
+		return nil
+	}
+	return it.chunk[it.index]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RecordIterator) Err() error {
+	return it.err
+}
+
+// Seq returns a range-over-func iterator over the records matching the
+// query, loading them in chunks. See EachChunk for how chunks are loaded.
+//
+// An error stops the iteration and is yielded together with a nil record.
+//
+// Example:
+//
+//	for record, err := range dsl.Collection(app, "products").Seq(ctx, *dsl.Query("")) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // ...
+//	}
+func (c *CollectionQueryBuilder) Seq(ctx context.Context, query QueryBuilder, params ...dbx.Params) iter.Seq2[*core.Record, error] {
+	return func(yield func(*core.Record, error) bool) {
+		it := c.Iter(ctx, query, params...)
+		for it.Next() {
+			if !yield(it.Record(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Each calls fn for every item matching the query, loading the records
+// in chunks. See CollectionQueryBuilder.EachChunk for the iteration rules.
+//
+// Example:
+//
+//	err := repo.Each(ctx, *dsl.Query(""), func(product Product) error {
+//	    return index(product)
+//	})
+func (r *Repo[T]) Each(ctx context.Context, query QueryBuilder, fn func(item T) error, params ...dbx.Params) error {
+	return r.collection.EachChunk(ctx, query, func(records []*core.Record) error {
+		items, err := r.FromRecords(records)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, params...)
+}
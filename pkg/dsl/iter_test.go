@@ -0,0 +1,127 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestEachChunk(t *testing.T) {
+	app := newTestApp(t)
+
+	for i := 0; i < 10; i++ {
+		mustCreate(t, app, "products", map[string]any{"name": fmt.Sprintf("p%d", i), "price": i})
+	}
+
+	c := Collection(app, "products")
+
+	var sizes []int
+	err := c.EachChunk(context.Background(), *Query("price >= 1").Page(0, 4), func(records []*core.Record) error {
+		sizes = append(sizes, len(records))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if fmt.Sprint(sizes) != "[4 4 1]" {
+		t.Errorf("Expected chunks [4 4 1], got %v", sizes)
+	}
+
+	// deleting while iterating neither skips nor repeats records
+	var deleted int
+	err = c.Each(context.Background(), *Query("").Sort("-price").Page(0, 3), func(record *core.Record) error {
+		deleted++
+		return app.Delete(record)
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete while iterating: %v", err)
+	}
+	if total := mustCount(t, app, "products"); deleted != 10 || total != 0 {
+		t.Errorf("Expected 10 deleted and 0 left, got %d deleted and %d left", deleted, total)
+	}
+}
+
+func TestEachStop(t *testing.T) {
+	app := newTestApp(t)
+
+	for i := 0; i < 5; i++ {
+		mustCreate(t, app, "products", map[string]any{"name": fmt.Sprintf("p%d", i), "price": i})
+	}
+
+	c := Collection(app, "products")
+
+	var visited int
+	err := c.Each(context.Background(), *Query("").Page(0, 2), func(record *core.Record) error {
+		visited++
+		if visited == 3 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || visited != 3 {
+		t.Errorf("Expected to stop after 3 records without error, got %d and %v", visited, err)
+	}
+
+	failure := errors.New("failure")
+	err = c.Each(context.Background(), *Query(""), func(record *core.Record) error { return failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	visited = 0
+	err = c.Each(ctx, *Query("").Page(0, 2), func(record *core.Record) error {
+		visited++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || visited != 1 {
+		t.Errorf("Expected context.Canceled after 1 record, got %d and %v", visited, err)
+	}
+}
+
+func TestIterAndSeq(t *testing.T) {
+	app := newTestApp(t)
+
+	for i := 0; i < 5; i++ {
+		mustCreate(t, app, "products", map[string]any{"name": fmt.Sprintf("p%d", i), "price": i})
+	}
+
+	c := Collection(app, "products")
+	query := Query("").Sort("-price").Page(0, 2)
+
+	var names []string
+	it := c.Iter(context.Background(), *query)
+	for it.Next() {
+		names = append(names, it.Record().GetString("name"))
+	}
+	if it.Err() != nil {
+		t.Fatalf("Unexpected iterator error: %v", it.Err())
+	}
+	if fmt.Sprint(names) != "[p4 p3 p2 p1 p0]" {
+		t.Errorf("Expected [p4 p3 p2 p1 p0], got %v", names)
+	}
+
+	names = nil
+	for record, err := range c.Seq(context.Background(), *query) {
+		if err != nil {
+			t.Fatalf("Unexpected sequence error: %v", err)
+		}
+		names = append(names, record.GetString("name"))
+		if len(names) == 3 {
+			break
+		}
+	}
+	if fmt.Sprint(names) != "[p4 p3 p2]" {
+		t.Errorf("Expected [p4 p3 p2], got %v", names)
+	}
+
+	for _, err := range Collection(app, "missing").Seq(context.Background(), *query) {
+		if err == nil {
+			t.Error("Expected error for a missing collection")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package dsl
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DefaultUpdatedField is the autodate field compared by UpdateIfUnchanged.
+const DefaultUpdatedField = "updated"
+
+// DefaultVersionField is the number field compared and incremented by
+// UpdateVersion.
+const DefaultVersionField = "version"
+
+// ConflictError is returned by UpdateIfUnchanged and UpdateVersion when the
+// record doesn't have the expected updated time or version anymore.
+//
+// It matches ErrConflict with errors.Is and reports the HTTP status 409
+// Conflict to the rpc package.
+type ConflictError struct {
+	Collection string // The collection name
+	Id         string // The id of the modified record
+	Field      string // The compared field, e.g. "updated" or "version"
+	Expected   any    // The value the caller expected
+	Actual     any    // The current value of the record
+}
+
+// Error implements the error interface.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict: %s record %q was modified concurrently (%s is %v, expected %v)", e.Collection, e.Id, e.Field, e.Actual, e.Expected)
+}
+
+// Is reports whether target is ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// HTTPStatus returns the HTTP status code of the error.
+func (e *ConflictError) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// UpdateIfUnchanged updates a record by ID like Update, but only if its
+// "updated" field still equals expectedUpdated, and returns a
+// *ConflictError otherwise.
+//
+// expectedUpdated is the updated value the caller read the record with,
+// as a types.DateTime, a time.Time or a string in the record JSON format.
+// The check and the update run in a single transaction.
+//
+// Example:
+//
+//	// req.Updated is the "updated" value the client loaded the form with
+//	record, err := dsl.Collection(app, "products").UpdateIfUnchanged(req.Id, req.Updated, data)
+//	if errors.Is(err, dsl.ErrConflict) {
+//	    // somebody else saved the product in the meantime
+//	}
+func (c *CollectionQueryBuilder) UpdateIfUnchanged(id string, expectedUpdated any, recordMap map[string]any) (*core.Record, error) {
+	expected, err := types.ParseDateTime(expectedUpdated)
+	if err != nil || expected.IsZero() {
+		return nil, fmt.Errorf("invalid expected updated value %v", expectedUpdated)
+	}
+	return c.updateIf(id, DefaultUpdatedField, recordMap, func(record *core.Record) error {
+		if _, ok := record.Collection().Fields.GetByName(DefaultUpdatedField).(*core.AutodateField); !ok {
+			return fmt.Errorf("collection %q has no autodate field %q", record.Collection().Name, DefaultUpdatedField)
+		}
+		// compare with the millisecond precision of the stored value
+		actual := record.GetDateTime(DefaultUpdatedField)
+		if actual.String() != expected.String() {
+			return &ConflictError{
+				Collection: record.Collection().Name,
+				Id:         record.Id,
+				Field:      DefaultUpdatedField,
+				Expected:   expected,
+				Actual:     actual,
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateVersion updates a record by ID like Update, but only if its
+// "version" number field still equals expectedVersion, and returns a
+// *ConflictError otherwise. The version is incremented by the update.
+//
+// Unlike UpdateIfUnchanged, it doesn't depend on the timestamp precision,
+// so concurrent updates within the same millisecond are detected too.
+//
+// Example:
+//
+//	record, err := dsl.Collection(app, "products").UpdateVersion(req.Id, req.Version, data)
+func (c *CollectionQueryBuilder) UpdateVersion(id string, expectedVersion int, recordMap map[string]any) (*core.Record, error) {
+	return c.updateIf(id, DefaultVersionField, recordMap, func(record *core.Record) error {
+		if _, ok := record.Collection().Fields.GetByName(DefaultVersionField).(*core.NumberField); !ok {
+			return fmt.Errorf("collection %q has no number field %q", record.Collection().Name, DefaultVersionField)
+		}
+		actual := record.GetInt(DefaultVersionField)
+		if actual != expectedVersion {
+			return &ConflictError{
+				Collection: record.Collection().Name,
+				Id:         record.Id,
+				Field:      DefaultVersionField,
+				Expected:   expectedVersion,
+				Actual:     actual,
+			}
+		}
+		record.Set(DefaultVersionField, actual+1)
+		return nil
+	})
+}
+
+// updateIf loads the record, lets check verify and adjust it, applies
+// recordMap without the compared field and saves it, all in a single
+// transaction.
+func (c *CollectionQueryBuilder) updateIf(id string, field string, recordMap map[string]any, check func(record *core.Record) error) (*core.Record, error) {
+	var record *core.Record
+	err := Transaction(c.app, func(tx *Tx) error {
+		var err error
+		record, err = c.withApp(tx.App()).One(id)
+		if err != nil {
+			return err
+		}
+		if err := check(record); err != nil {
+			return err
+		}
+		patch := maps.Clone(recordMap)
+		delete(patch, field)
+		record.Load(patch)
+		return c.withApp(tx.App()).save(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// UpdateIfUnchanged updates the record with the given ID from item if its
+// "updated" field still equals expectedUpdated. See
+// CollectionQueryBuilder.UpdateIfUnchanged.
+//
+// Example:
+//
+//	product, err := repo.UpdateIfUnchanged(product.Id, product.Updated, product)
+func (r *Repo[T]) UpdateIfUnchanged(id string, expectedUpdated any, item T) (T, error) {
+	var zero T
+	data, err := r.ToMap(item)
+	if err != nil {
+		return zero, err
+	}
+	delete(data, core.FieldNameId)
+	record, err := r.collection.UpdateIfUnchanged(id, expectedUpdated, data)
+	if err != nil {
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
+
+// UpdateVersion updates the record with the given ID from item if its
+// "version" field still equals expectedVersion. See
+// CollectionQueryBuilder.UpdateVersion.
+//
+// Example:
+//
+//	product, err := repo.UpdateVersion(product.Id, product.Version, product)
+func (r *Repo[T]) UpdateVersion(id string, expectedVersion int, item T) (T, error) {
+	var zero T
+	data, err := r.ToMap(item)
+	if err != nil {
+		return zero, err
+	}
+	delete(data, core.FieldNameId)
+	record, err := r.collection.UpdateVersion(id, expectedVersion, data)
+	if err != nil {
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
@@ -0,0 +1,114 @@
+package dsl
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestUpdateIfUnchanged(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	record := mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+	loaded := record.GetDateTime("updated")
+
+	// make sure the next update gets a different timestamp
+	time.Sleep(5 * time.Millisecond)
+
+	updated, err := c.UpdateIfUnchanged(record.Id, loaded, map[string]any{"price": 12})
+	if err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if updated.GetInt("price") != 12 {
+		t.Errorf("Expected price 12, got %d", updated.GetInt("price"))
+	}
+
+	// stale timestamp, as sent by a client in the record JSON format
+	_, err = c.UpdateIfUnchanged(record.Id, loaded.String(), map[string]any{"price": 15})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected *ConflictError, got %T", err)
+	}
+	if conflict.Id != record.Id || conflict.Collection != "products" || conflict.Field != "updated" {
+		t.Errorf("Unexpected conflict details %+v", conflict)
+	}
+	if conflict.HTTPStatus() != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", conflict.HTTPStatus())
+	}
+
+	current, err := c.One(record.Id)
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if current.GetInt("price") != 12 {
+		t.Errorf("Expected the conflicting update to be discarded, got price %d", current.GetInt("price"))
+	}
+
+	if _, err := c.UpdateIfUnchanged(record.Id, current.GetString("updated"), map[string]any{"price": 15}); err != nil {
+		t.Errorf("Expected the update with the current timestamp to succeed, got %v", err)
+	}
+	if _, err := c.UpdateIfUnchanged(record.Id, "not a date", map[string]any{"price": 15}); err == nil || errors.Is(err, ErrConflict) {
+		t.Errorf("Expected an invalid date error, got %v", err)
+	}
+}
+
+func TestUpdateVersion(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	record := mustCreate(t, app, "products", map[string]any{"name": "hammer"})
+	if _, err := c.UpdateVersion(record.Id, 0, map[string]any{"price": 1}); err == nil || errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a missing version field error, got %v", err)
+	}
+
+	products, err := app.FindCollectionByNameOrId("products")
+	if err != nil {
+		t.Fatalf("Failed to find products collection: %v", err)
+	}
+	products.Fields.Add(&core.NumberField{Name: "version"})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+
+	updated, err := c.UpdateVersion(record.Id, 0, map[string]any{"price": 12, "version": 100})
+	if err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if updated.GetInt("version") != 1 || updated.GetInt("price") != 12 {
+		t.Errorf("Expected version 1 and price 12, got %d and %d", updated.GetInt("version"), updated.GetInt("price"))
+	}
+
+	// two editors loaded version 1, the second save conflicts
+	if _, err := c.UpdateVersion(record.Id, 1, map[string]any{"price": 13}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	_, err = c.UpdateVersion(record.Id, 1, map[string]any{"price": 14})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected *ConflictError, got %v", err)
+	}
+	if conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Errorf("Expected version 1 vs 2, got %v vs %v", conflict.Expected, conflict.Actual)
+	}
+
+	type product struct {
+		ID      string `pb:"id"`
+		Name    string `pb:"name"`
+		Version int    `pb:"version"`
+	}
+	repo := NewRepo[product](app, "products")
+	item, err := repo.UpdateVersion(record.Id, 2, product{Name: "claw hammer"})
+	if err != nil {
+		t.Fatalf("Failed to update record through the repository: %v", err)
+	}
+	if item.Name != "claw hammer" || item.Version != 3 {
+		t.Errorf("Expected the name to be updated at version 3, got %+v", item)
+	}
+}
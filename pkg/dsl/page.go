@@ -0,0 +1,137 @@
+package dsl
+
+import (
+	"math"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// ListResult is a single page of records together with its pagination
+// metadata. It serializes to the same JSON shape as the PocketBase
+// records list API.
+//
+// When the total was skipped (see QueryBuilder.SkipTotal), TotalItems and
+// TotalPages are -1.
+type ListResult[T any] struct {
+	Items      []T `json:"items"`      // The records of the current page
+	Page       int `json:"page"`       // The current page number (1-based)
+	PerPage    int `json:"perPage"`    // The maximum number of items per page
+	TotalItems int `json:"totalItems"` // The number of records matching the filter
+	TotalPages int `json:"totalPages"` // The number of available pages
+}
+
+// SkipTotal disables counting the total number of matching records in
+// ListPage, in the same way as the PocketBase "skipTotal" query parameter.
+//
+// This saves a COUNT query and is useful for cheap "load more" lists that
+// don't need to display the number of pages.
+//
+// Example:
+//
+//	query := dsl.Query("").Page(2, 50).SkipTotal(true)
+func (q *QueryBuilder) SkipTotal(skip bool) *QueryBuilder {
+	q.skipTotal = skip
+	return q
+}
+
+// ListPage retrieves a single page of records matching the query criteria
+// along with the total number of items and pages.
+//
+// The page and perPage values are normalized like in the PocketBase list
+// API: page defaults to 1, perPage defaults to 30 and is capped at 1000.
+// The filter, params, sort and expand options are applied exactly as in
+// List, and the same filter is used for the count.
+//
+// Example:
+//
+//	query := dsl.Query("status = {:status}").Page(2, 20).Sort("-created")
+//	result, err := dsl.Collection(app, "posts").ListPage(*query, dbx.Params{"status": "published"})
+//	// result.Items, result.TotalItems, result.TotalPages
+func (c *CollectionQueryBuilder) ListPage(query QueryBuilder, params ...dbx.Params) (*ListResult[*core.Record], error) {
+	if query.cacheTTL > 0 {
+		return cachedQuery(c, "page", query, params, func(query QueryBuilder) (*ListResult[*core.Record], error) {
+			return c.ListPage(query, params...)
+		}, func(result *ListResult[*core.Record]) *ListResult[*core.Record] {
+			clone := *result
+			clone.Items = cloneRecords(result.Items)
+			return &clone
+		})
+	}
+	q, collection, err := c.recordQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	page := query.page
+	if page <= 0 {
+		page = 1
+	}
+	perPage := query.perPage
+	if perPage <= 0 {
+		perPage = search.DefaultPerPage
+	} else if perPage > search.MaxPerPage {
+		perPage = search.MaxPerPage
+	}
+
+	result := &ListResult[*core.Record]{
+		Page:       page,
+		PerPage:    perPage,
+		TotalItems: -1,
+		TotalPages: -1,
+	}
+
+	if !query.skipTotal {
+		// note: the count query is a shallow clone and shouldn't be modified in place
+		var total int
+		countQuery := *q
+		err := countQuery.Distinct(false).
+			Select("COUNT(DISTINCT [[" + collection.Name + ".id]])").
+			OrderBy( /* reset */ ).
+			Row(&total)
+		if err != nil {
+			return nil, c.contextError(err)
+		}
+		result.TotalItems = total
+		result.TotalPages = int(math.Ceil(float64(total) / float64(perPage)))
+	}
+
+	records := []*core.Record{}
+	err = q.Limit(int64(perPage)).Offset(int64(perPage * (page - 1))).All(&records)
+	if err != nil {
+		return nil, c.contextError(err)
+	}
+	if err := c.finishRecords(records, query); err != nil {
+		return nil, err
+	}
+	result.Items = records
+
+	return result, nil
+}
+
+// ListPage retrieves a single page of items matching the query criteria
+// along with the total number of items and pages.
+//
+// See CollectionQueryBuilder.ListPage for the pagination rules.
+//
+// Example:
+//
+//	result, err := repo.ListPage(*dsl.Query("").Page(1, 20).Sort("name"))
+func (r *Repo[T]) ListPage(query QueryBuilder, params ...dbx.Params) (*ListResult[T], error) {
+	page, err := r.collection.ListPage(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.FromRecords(page.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResult[T]{
+		Items:      items,
+		Page:       page.Page,
+		PerPage:    page.PerPage,
+		TotalItems: page.TotalItems,
+		TotalPages: page.TotalPages,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package dsl
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestListPage(t *testing.T) {
+	app := newTestApp(t)
+
+	category := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	for i := 0; i < 7; i++ {
+		data := map[string]any{"name": fmt.Sprintf("p%d", i), "price": i}
+		if i%2 == 0 {
+			data["category"] = category.Id
+		}
+		mustCreate(t, app, "products", data)
+	}
+
+	scenarios := []struct {
+		name          string
+		query         *QueryBuilder
+		expectedItems int
+		expectedPage  int
+		expectedPer   int
+		expectedTotal int
+		expectedPages int
+		expectedFirst string
+	}{
+		{"defaults", Query(""), 7, 1, 30, 7, 1, ""},
+		{"second page", Query("").Page(2, 3).Sort("name"), 3, 2, 3, 7, 3, "p3"},
+		{"last page", Query("").Page(3, 3).Sort("name"), 1, 3, 3, 7, 3, "p6"},
+		{"past the end", Query("").Page(5, 3), 0, 5, 3, 7, 3, ""},
+		{"filtered", Where(Gte("price", 4)).Page(1, 2).Sort("-price"), 2, 1, 2, 3, 2, "p6"},
+		{"relation filter", Where(Eq("category.name", "tools")).Page(1, 10), 4, 1, 10, 4, 1, ""},
+		{"skip total", Query("").Page(1, 5).SkipTotal(true), 5, 1, 5, -1, -1, ""},
+		{"per page cap", Query("").Page(0, 5000), 7, 1, 1000, 7, 1, ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			result, err := Collection(app, "products").ListPage(*s.query)
+			if err != nil {
+				t.Fatalf("Failed to list page: %v", err)
+			}
+			if len(result.Items) != s.expectedItems {
+				t.Errorf("Expected %d items, got %d", s.expectedItems, len(result.Items))
+			}
+			if result.Page != s.expectedPage || result.PerPage != s.expectedPer {
+				t.Errorf("Expected page %d/%d, got %d/%d", s.expectedPage, s.expectedPer, result.Page, result.PerPage)
+			}
+			if result.TotalItems != s.expectedTotal || result.TotalPages != s.expectedPages {
+				t.Errorf("Expected totals %d/%d, got %d/%d", s.expectedTotal, s.expectedPages, result.TotalItems, result.TotalPages)
+			}
+			if s.expectedFirst != "" && result.Items[0].GetString("name") != s.expectedFirst {
+				t.Errorf("Expected first item '%s', got '%s'", s.expectedFirst, result.Items[0].GetString("name"))
+			}
+		})
+	}
+}
+
+func TestRepoListPage(t *testing.T) {
+	app := newTestApp(t)
+
+	for i := 0; i < 3; i++ {
+		mustCreate(t, app, "products", map[string]any{"name": fmt.Sprintf("p%d", i), "price": i})
+	}
+
+	type product struct {
+		Name  string `pb:"name"`
+		Price int    `pb:"price"`
+	}
+
+	result, err := NewRepo[product](app, "products").ListPage(*Query("").Page(1, 2).Sort("-price"))
+	if err != nil {
+		t.Fatalf("Failed to list page: %v", err)
+	}
+	if len(result.Items) != 2 || result.Items[0].Name != "p2" || result.Items[0].Price != 2 {
+		t.Errorf("Unexpected items %+v", result.Items)
+	}
+	if result.TotalItems != 3 || result.TotalPages != 2 {
+		t.Errorf("Expected totals 3/2, got %d/%d", result.TotalItems, result.TotalPages)
+	}
+}
@@ -0,0 +1,308 @@
+package dsl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ganigeorgiev/fexpr"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// DefaultMaxPerPage is the largest perPage accepted by ParseQuery when the
+// policy doesn't set MaxPerPage.
+const DefaultMaxPerPage = 100
+
+// QueryPolicy restricts the client input accepted by ParseQuery.
+//
+// Fields and Sort entries are field names or dotted relation paths, e.g.
+// "name" or "category.name"; a trailing ".*" allows every path below a
+// relation, e.g. "category.*", except the hidden fields of the related
+// collections. Identifiers starting with "@", like "@request.auth.id" or
+// "@now", are only accepted when listed in Fields.
+type QueryPolicy struct {
+	Fields     []string // Fields that can be used in the filter
+	Operators  []string // Allowed filter operators, e.g. "=", "~", "?="; all when empty
+	Sort       []string // Fields that can be sorted by
+	Expand     []string // Relations that can be expanded; "category.owner" also allows "category"
+	MaxPerPage int      // The largest accepted perPage; DefaultMaxPerPage when 0
+}
+
+// QueryError is returned by ParseQuery when a query parameter isn't
+// well-formed or isn't allowed by the policy.
+//
+// It matches ErrInvalidQuery with errors.Is and reports the HTTP status
+// 400 Bad Request to the rpc package.
+type QueryError struct {
+	Param   string // The rejected parameter: "filter", "sort", "expand", "page" or "perPage"
+	Value   string // The rejected value, e.g. the field name or operator
+	Message string // Why the value was rejected
+}
+
+// Error implements the error interface.
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Param, e.Message)
+}
+
+// Is reports whether target is ErrInvalidQuery.
+func (e *QueryError) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+// HTTPStatus returns the HTTP status code of the error.
+func (e *QueryError) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ParseQuery builds a QueryBuilder from the "filter", "sort", "page",
+// "perPage" and "expand" parameters of a client request, rejecting
+// anything policy doesn't allow with a *QueryError.
+//
+// input is either url.Values or a struct (or pointer to struct) whose
+// fields are matched to the parameters by their json tag or name; other
+// fields and parameters are ignored. The filter is parsed and every field,
+// operator and function in it is checked, so clients can't filter by
+// hidden fields or bypass the policy through relations. Like the PocketBase
+// list API, the filter and sort of the returned query are resolved against
+// the collection schema with hidden fields rejected, including conditions
+// added with And. perPage defaults to 30 (or MaxPerPage if lower), so List
+// never returns an unbounded result for a client query.
+//
+// Server side conditions can be added to the returned query with And.
+//
+// Example:
+//
+//	policy := dsl.QueryPolicy{
+//	    Fields:     []string{"name", "price", "status", "category.name"},
+//	    Operators:  []string{"=", "!=", ">", ">=", "<", "<=", "~"},
+//	    Sort:       []string{"name", "price", "created"},
+//	    Expand:     []string{"category"},
+//	    MaxPerPage: 50,
+//	}
+//
+//	query, err := dsl.ParseQuery(e.Request.URL.Query(), policy)
+//	if err != nil {
+//	    return e.BadRequestError(err.Error(), nil)
+//	}
+//	result, err := dsl.Collection(app, "products").ListPage(*query.And(dsl.Eq("status", "active")))
+func ParseQuery(input any, policy QueryPolicy) (*QueryBuilder, error) {
+	values, err := queryValues(input)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := strings.TrimSpace(values.Get("filter"))
+	if filter != "" {
+		groups, err := fexpr.Parse(filter)
+		if err != nil {
+			return nil, &QueryError{Param: "filter", Value: filter, Message: err.Error()}
+		}
+		if err := policy.checkGroups(groups); err != nil {
+			return nil, err
+		}
+	}
+
+	sort := strings.TrimSpace(values.Get("sort"))
+	if sort != "" {
+		for _, field := range search.ParseSortFromString(sort) {
+			if !allowedPath(policy.Sort, field.Name) {
+				return nil, &QueryError{Param: "sort", Value: field.Name, Message: fmt.Sprintf("sorting by %q is not allowed", field.Name)}
+			}
+		}
+	}
+
+	expand := strings.TrimSpace(values.Get("expand"))
+	if expand != "" {
+		for _, path := range strings.Split(expand, ",") {
+			path = strings.TrimSpace(path)
+			if !policy.allowedExpand(path) {
+				return nil, &QueryError{Param: "expand", Value: path, Message: fmt.Sprintf("expanding %q is not allowed", path)}
+			}
+		}
+	}
+
+	maxPerPage := policy.MaxPerPage
+	if maxPerPage <= 0 {
+		maxPerPage = DefaultMaxPerPage
+	}
+	page, err := positiveInt(values, "page", 1)
+	if err != nil {
+		return nil, err
+	}
+	perPage, err := positiveInt(values, "perPage", min(search.DefaultPerPage, maxPerPage))
+	if err != nil {
+		return nil, err
+	}
+	if perPage > maxPerPage {
+		return nil, &QueryError{Param: "perPage", Value: strconv.Itoa(perPage), Message: fmt.Sprintf("must be at most %d", maxPerPage)}
+	}
+
+	query := Query(filter).Sort(sort).Expand(expand).Page(page, perPage)
+	query.noHiddenFields = true
+	return query, nil
+}
+
+// checkGroups checks the fields and operators of a parsed filter.
+func (p QueryPolicy) checkGroups(groups []fexpr.ExprGroup) error {
+	for _, group := range groups {
+		switch item := group.Item.(type) {
+		case []fexpr.ExprGroup:
+			if err := p.checkGroups(item); err != nil {
+				return err
+			}
+		case fexpr.Expr:
+			if len(p.Operators) > 0 && !slices.Contains(p.Operators, string(item.Op)) {
+				return &QueryError{Param: "filter", Value: string(item.Op), Message: fmt.Sprintf("operator %q is not allowed", item.Op)}
+			}
+			if err := p.checkToken(item.Left); err != nil {
+				return err
+			}
+			if err := p.checkToken(item.Right); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkToken checks that an operand of a filter expression is a literal or
+// an allowed field.
+func (p QueryPolicy) checkToken(token fexpr.Token) error {
+	switch token.Type {
+	case fexpr.TokenText, fexpr.TokenNumber:
+		return nil
+	case fexpr.TokenIdentifier:
+		switch strings.ToLower(token.Literal) {
+		case "null", "true", "false":
+			return nil
+		}
+		// modifiers like "name:lower" apply to the allowed field
+		field, _, _ := strings.Cut(token.Literal, ":")
+		if allowedPath(p.Fields, field) {
+			return nil
+		}
+		return &QueryError{Param: "filter", Value: token.Literal, Message: fmt.Sprintf("field %q is not allowed", field)}
+	case fexpr.TokenFunction:
+		return &QueryError{Param: "filter", Value: token.Literal, Message: fmt.Sprintf("function %q is not allowed", token.Literal)}
+	}
+	return &QueryError{Param: "filter", Value: token.Literal, Message: fmt.Sprintf("unexpected %s %q", token.Type, token.Literal)}
+}
+
+// allowedExpand reports whether path or a longer path starting with it is
+// in the Expand list.
+func (p QueryPolicy) allowedExpand(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, allowed := range p.Expand {
+		if allowed == path || strings.HasPrefix(allowed, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// alwaysHiddenFields are the fields of auth collections that are hidden
+// regardless of the schema options.
+var alwaysHiddenFields = []string{core.FieldNamePassword, core.FieldNameTokenKey}
+
+// allowedPath reports whether path matches one of patterns, either exactly
+// or below a pattern ending with ".*".
+//
+// Wildcards never match the password and tokenKey fields of auth
+// collections; the other hidden fields are rejected when the query runs.
+func allowedPath(patterns []string, path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == path {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, ".") && strings.HasPrefix(path, prefix) {
+			if slices.ContainsFunc(strings.Split(path, "."), func(name string) bool {
+				return slices.Contains(alwaysHiddenFields, name)
+			}) {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// positiveInt returns the integer parameter name of values, or def if it
+// isn't set.
+func positiveInt(values url.Values, name string, def int) (int, error) {
+	raw := strings.TrimSpace(values.Get(name))
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, &QueryError{Param: name, Value: raw, Message: "must be a positive integer"}
+	}
+	return n, nil
+}
+
+// queryParams are the parameters read by ParseQuery.
+var queryParams = []string{"filter", "sort", "page", "perPage", "expand"}
+
+// queryValues returns the query parameters of input, which is url.Values
+// or a struct.
+func queryValues(input any) (url.Values, error) {
+	if values, ok := input.(url.Values); ok {
+		return values, nil
+	}
+
+	v := reflect.ValueOf(input)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ParseQuery expects url.Values or a struct, got %T", input)
+	}
+
+	values := url.Values{}
+	for _, field := range reflect.VisibleFields(v.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			name = tag
+		}
+		i := slices.IndexFunc(queryParams, func(param string) bool { return strings.EqualFold(param, name) })
+		if i < 0 {
+			continue
+		}
+
+		value, err := v.FieldByIndexErr(field.Index)
+		if err != nil {
+			continue // nil embedded pointer
+		}
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.String:
+			if value.String() != "" {
+				values.Set(queryParams[i], value.String())
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value.Int() != 0 {
+				values.Set(queryParams[i], strconv.FormatInt(value.Int(), 10))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if value.Uint() != 0 {
+				values.Set(queryParams[i], strconv.FormatUint(value.Uint(), 10))
+			}
+		}
+	}
+	return values, nil
+}
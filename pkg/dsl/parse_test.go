@@ -0,0 +1,227 @@
+package dsl
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// testPolicy is the policy of the ParseQuery tests.
+var testPolicy = QueryPolicy{
+	Fields:     []string{"name", "price", "status", "tags", "category.*"},
+	Operators:  []string{"=", "!=", ">", ">=", "<", "<=", "~", "?="},
+	Sort:       []string{"name", "price", "created"},
+	Expand:     []string{"category"},
+	MaxPerPage: 50,
+}
+
+func TestParseQuery(t *testing.T) {
+	app := newTestApp(t)
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10, "status": "active", "category": tools.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20, "status": "active", "category": tools.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "drill", "price": 30, "status": "draft"})
+
+	values := url.Values{
+		"filter":  {"(price >= 10 && category.name = 'tools') || name:lower ~ 'dri'"},
+		"sort":    {"-price"},
+		"page":    {"1"},
+		"perPage": {"2"},
+		"expand":  {"category"},
+		"other":   {"ignored"},
+	}
+	query, err := ParseQuery(values, testPolicy)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	result, err := Collection(app, "products").ListPage(*query)
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(result.Items); names != "drill,saw" {
+		t.Errorf("Expected drill,saw, got %s", names)
+	}
+	if result.TotalItems != 3 || result.PerPage != 2 {
+		t.Errorf("Expected 3 total items and 2 per page, got %d and %d", result.TotalItems, result.PerPage)
+	}
+	if result.Items[1].ExpandedOne("category") == nil {
+		t.Error("Expected the category to be expanded")
+	}
+
+	// server side conditions can be added to the parsed query
+	records, err := Collection(app, "products").List(*query.And(Eq("status", "active")).Page(0, 0))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "saw,hammer" {
+		t.Errorf("Expected saw,hammer, got %s", names)
+	}
+
+	// perPage defaults to a bounded page
+	query, err = ParseQuery(url.Values{}, QueryPolicy{MaxPerPage: 10})
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if query.page != 1 || query.perPage != 10 {
+		t.Errorf("Expected page 1 with 10 items, got %d and %d", query.page, query.perPage)
+	}
+	if query, _ := ParseQuery(url.Values{}, QueryPolicy{}); query.perPage != 30 {
+		t.Errorf("Expected 30 items per page, got %d", query.perPage)
+	}
+}
+
+func TestParseQueryStruct(t *testing.T) {
+	type Paging struct {
+		Page    int `json:"page"`
+		PerPage int `json:"perPage"`
+	}
+	type listRequest struct {
+		*Paging
+		Filter  string  `json:"filter"`
+		OrderBy string  `json:"sort"`
+		Expand  *string `json:"expand"`
+		Search  string  `json:"search"`
+	}
+
+	expand := "category"
+	query, err := ParseQuery(&listRequest{
+		Paging:  &Paging{Page: 2, PerPage: 5},
+		Filter:  "status = 'active'",
+		OrderBy: "name",
+		Expand:  &expand,
+		Search:  "ignored",
+	}, testPolicy)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if query.filter != "status = 'active'" || query.sort != "name" || query.expand != "category" || query.page != 2 || query.perPage != 5 {
+		t.Errorf("Expected the request parameters, got %+v", query)
+	}
+
+	// a nil embedded struct leaves its parameters unset
+	query, err = ParseQuery(listRequest{}, testPolicy)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if query.page != 1 || query.perPage != 30 {
+		t.Errorf("Expected the default page, got %d and %d", query.page, query.perPage)
+	}
+
+	if _, err := ParseQuery("filter=name", testPolicy); err == nil || errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected an input type error, got %v", err)
+	}
+}
+
+func TestParseQueryRejected(t *testing.T) {
+	cases := map[string]struct {
+		values url.Values
+		param  string
+		value  string
+	}{
+		"hidden field":        {url.Values{"filter": {"secret = 1"}}, "filter", "secret"},
+		"nested hidden field": {url.Values{"filter": {"name = 'a' && (price > 1 || owner.email = 'a')"}}, "filter", "owner.email"},
+		"field on the right":  {url.Values{"filter": {"name = secret"}}, "filter", "secret"},
+		"modifier":            {url.Values{"filter": {"secret:lower = 'a'"}}, "filter", "secret:lower"},
+		"request identifier":  {url.Values{"filter": {"@request.auth.id != ''"}}, "filter", "@request.auth.id"},
+		"collection join":     {url.Values{"filter": {"@collection.users.email = 'a'"}}, "filter", "@collection.users.email"},
+		"operator":            {url.Values{"filter": {"name !~ 'a'"}}, "filter", "!~"},
+		"function":            {url.Values{"filter": {"geoDistance(1, 2, 3, 4) < 5"}}, "filter", "geoDistance"},
+		"syntax":              {url.Values{"filter": {"name = "}}, "filter", "name ="},
+		"sort":                {url.Values{"sort": {"name,-secret"}}, "sort", "secret"},
+		"random sort":         {url.Values{"sort": {"@random"}}, "sort", "@random"},
+		"expand":              {url.Values{"expand": {"category,owner"}}, "expand", "owner"},
+		"nested expand":       {url.Values{"expand": {"category.owner"}}, "expand", "category.owner"},
+		"page":                {url.Values{"page": {"0"}}, "page", "0"},
+		"perPage":             {url.Values{"perPage": {"abc"}}, "perPage", "abc"},
+		"max perPage":         {url.Values{"perPage": {"51"}}, "perPage", "51"},
+	}
+	for name, c := range cases {
+		_, err := ParseQuery(c.values, testPolicy)
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", name, err)
+			continue
+		}
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%s: expected a *QueryError, got %v", name, err)
+			continue
+		}
+		if queryErr.Param != c.param || queryErr.Value != c.value || queryErr.Message == "" {
+			t.Errorf("%s: expected %s %q, got %+v", name, c.param, c.value, queryErr)
+		}
+		if queryErr.HTTPStatus() != 400 {
+			t.Errorf("%s: expected status 400, got %d", name, queryErr.HTTPStatus())
+		}
+	}
+
+	// macros and literals are accepted when allowed
+	policy := testPolicy
+	policy.Fields = append(policy.Fields, "@now", "created")
+	if _, err := ParseQuery(url.Values{"filter": {"created < @now && status != null && name != true"}}, policy); err != nil {
+		t.Errorf("Expected the macro to be accepted, got %v", err)
+	}
+}
+
+func TestParseQueryHiddenFields(t *testing.T) {
+	app := newRulesApp(t)
+	users := mustCollection(t, app, "users")
+	users.Fields.Add(&core.TextField{Name: "secret", Hidden: true})
+	if err := app.Save(users); err != nil {
+		t.Fatalf("Failed to update users collection: %v", err)
+	}
+	alice := mustCreate(t, app, "users", map[string]any{"email": "alice@example.com", "password": "1234567890", "emailVisibility": true, "secret": "a"})
+	bob := mustCreate(t, app, "users", map[string]any{"email": "bob@example.com", "password": "1234567890", "secret": "b"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "owner": alice.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "status": "active", "owner": bob.Id})
+
+	policy := QueryPolicy{Fields: []string{"name", "owner.*"}, Sort: []string{"owner.*"}}
+
+	// the wildcard never matches the password and tokenKey fields
+	for _, values := range []url.Values{
+		{"filter": {"owner.tokenKey != '' && owner.password ~ '$2a'"}},
+		{"filter": {"owner.password:lower ~ '$2a'"}},
+		{"sort": {"owner.tokenKey"}},
+	} {
+		if _, err := ParseQuery(values, policy); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected %v to be rejected, got %v", values, err)
+		}
+	}
+
+	// the other hidden fields are rejected against the schema
+	for _, values := range []url.Values{
+		{"filter": {"owner.secret = 'a'"}},
+		{"sort": {"owner.secret"}},
+	} {
+		query, err := ParseQuery(values, policy)
+		if err != nil {
+			t.Fatalf("Failed to parse query: %v", err)
+		}
+		if records, err := Collection(app, "products").List(*query); err == nil {
+			t.Errorf("Expected %v to fail, got %s", values, recordNames(records))
+		}
+	}
+
+	// emails only match the users who made them visible
+	query, err := ParseQuery(url.Values{"filter": {"owner.email ~ 'example.com'"}}, policy)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	records, err := Collection(app, "products").List(*query)
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "hammer" {
+		t.Errorf("Expected only the product of the user with a visible email, got %s", names)
+	}
+
+	// server side queries still can
+	records, err = Collection(app, "products").List(*Query("owner.secret = 'b'"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "saw" {
+		t.Errorf("Expected the hidden field in a server query, got %s", names)
+	}
+}
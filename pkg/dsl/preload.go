@@ -0,0 +1,412 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// backRelationRegex matches back-relation names such as "comments_via_post".
+var backRelationRegex = regexp.MustCompile(`^(\w+)_via_(\w+)$`)
+
+// preload is a relation path registered with QueryBuilder.Preload.
+type preload struct {
+	path    string        // Dot-separated relation path
+	options *QueryBuilder // Filter, sort and limit of the last relation
+}
+
+// preloadNode is a relation of the preload tree, loaded once per level.
+type preloadNode struct {
+	name     string         // Relation field or back-relation name
+	options  *QueryBuilder  // Filter, sort and limit of the relation
+	children []*preloadNode // Nested relations
+}
+
+// Preload loads a relation path for all the returned records in batches,
+// with one query per relation level, and stores it in the record expand
+// data like Expand.
+//
+// The path may contain nested relations ("author.profile") and
+// back-relations ("comments_via_post"). The optional options query filters
+// and sorts the records of the last relation of the path, and its perPage
+// value (see Page) limits the number of related records per parent
+// record. The limit is applied by the database, so only the kept records
+// are loaded and preloaded further. Its other settings are ignored.
+//
+// Example:
+//
+//	query := dsl.Query("").
+//	    Preload("author.profile").
+//	    Preload("comments_via_post", dsl.Query("approved = true").Sort("-created").Page(0, 3))
+//	posts, err := dsl.Collection(app, "posts").List(*query)
+//
+//	comments := posts[0].ExpandedAll("comments_via_post")
+func (q *QueryBuilder) Preload(path string, options ...*QueryBuilder) *QueryBuilder {
+	p := preload{path: strings.TrimSpace(path)}
+	if len(options) > 0 {
+		p.options = options[0]
+	}
+	q.preloads = append(q.preloads, p)
+	return q
+}
+
+// preloadTree merges the preload paths into a tree of relations.
+func preloadTree(preloads []preload) []*preloadNode {
+	root := &preloadNode{}
+	for _, p := range preloads {
+		node := root
+		for _, name := range strings.Split(p.path, ".") {
+			var child *preloadNode
+			for _, existing := range node.children {
+				if existing.name == name {
+					child = existing
+					break
+				}
+			}
+			if child == nil {
+				child = &preloadNode{name: name}
+				node.children = append(node.children, child)
+			}
+			node = child
+		}
+		if p.options != nil {
+			node.options = p.options
+		}
+	}
+	return root.children
+}
+
+// preloadRecords loads the preloads of query into the expand data of
+// records.
+func (c *CollectionQueryBuilder) preloadRecords(records []*core.Record, preloads []preload) error {
+	if len(preloads) == 0 || len(records) == 0 {
+		return nil
+	}
+	return preloadLevel(c, records[0].Collection(), records, preloadTree(preloads))
+}
+
+// preloadLevel loads the relations of nodes for records of collection with
+// the app, context and API rules of c, and then recurses into the nested
+// relations.
+func preloadLevel(c *CollectionQueryBuilder, collection *core.Collection, records []*core.Record, nodes []*preloadNode) error {
+	for _, node := range nodes {
+		var related []*core.Record
+		var relCollection *core.Collection
+		var err error
+
+		if field, ok := collection.Fields.GetByName(node.name).(*core.RelationField); ok {
+			related, relCollection, err = preloadForward(c, records, field, node)
+		} else if match := backRelationRegex.FindStringSubmatch(node.name); match != nil {
+			related, relCollection, err = preloadBack(c, collection, records, match[1], match[2], node)
+		} else {
+			err = fmt.Errorf("unknown relation %q of collection %q", node.name, collection.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(node.children) > 0 && len(related) > 0 {
+			if err := preloadLevel(c, relCollection, related, node.children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// preloadQuery loads the records of collection matching condition and the
+// filter and sort of the node options.
+func preloadQuery(c *CollectionQueryBuilder, collection *core.Collection, node *preloadNode, condition dbx.Expression) ([]*core.Record, error) {
+	options := QueryBuilder{}
+	if node.options != nil {
+		options = *node.options
+	}
+
+	rc := c.related(collection.Id)
+	s, err := rc.filteredSelect(options, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preload %q: %w", node.name, err)
+	}
+	s.query.AndWhere(condition)
+	if err := s.sortBy(options.sort); err != nil {
+		return nil, fmt.Errorf("invalid preload %q: %w", node.name, err)
+	}
+
+	records := []*core.Record{}
+	if err := s.build().All(&records); err != nil {
+		return nil, rc.contextError(err)
+	}
+	return records, nil
+}
+
+// related returns a builder for the related collection with the app,
+// context and API rules of c.
+func (c *CollectionQueryBuilder) related(collection string) *CollectionQueryBuilder {
+	return &CollectionQueryBuilder{
+		app:         c.app,
+		collection:  collection,
+		ctx:         c.ctx,
+		requestInfo: c.requestInfo,
+	}
+}
+
+// limit returns the maximum number of related records per parent record.
+func (node *preloadNode) limit() int {
+	if node.options == nil {
+		return 0
+	}
+	return node.options.perPage
+}
+
+// preloadForward loads the records referenced by the relation field of
+// records.
+func preloadForward(c *CollectionQueryBuilder, records []*core.Record, field *core.RelationField, node *preloadNode) ([]*core.Record, *core.Collection, error) {
+	relCollection, err := c.app.FindCachedCollectionByNameOrId(field.CollectionId)
+	if err != nil {
+		return nil, nil, collectionError(err)
+	}
+
+	seen := map[string]bool{}
+	ids := []any{}
+	for _, record := range records {
+		for _, id := range record.GetStringSlice(field.Name) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, relCollection, nil
+	}
+
+	condition := dbx.In("[["+relCollection.Name+".id]]", ids...)
+	if limit := node.limit(); limit > 0 && field.IsMultiple() {
+		// one row per parent and related record, ranked in the order of the
+		// relation values unless sorted
+		parentIds := make([]any, len(records))
+		for i, record := range records {
+			parentIds[i] = record.Id
+		}
+		join := func(q *dbx.SelectQuery) {
+			q.InnerJoin("{{"+records[0].Collection().Name+"}} {{dsl_parent}}", dbx.In("dsl_parent.id", parentIds...))
+			q.InnerJoin("json_each([[dsl_parent."+field.Name+"]]) {{dsl_item}}", dbx.NewExp("[[dsl_item.value]] = [["+relCollection.Name+".id]]"))
+		}
+		byParent, related, err := preloadLimited(c, relCollection, node, condition, join, "[[dsl_parent.id]]", "[[dsl_item.key]]")
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			setExpand(record, node.name, byParent[record.Id])
+		}
+		return related, relCollection, nil
+	}
+
+	related, err := preloadQuery(c, relCollection, node, condition)
+	if err != nil {
+		return nil, nil, err
+	}
+	byId := make(map[string]*core.Record, len(related))
+	for _, r := range related {
+		byId[r.Id] = r
+	}
+
+	sorted := node.options != nil && node.options.sort != ""
+	for _, record := range records {
+		ids := record.GetStringSlice(field.Name)
+		items := make([]*core.Record, 0, len(ids))
+		if sorted {
+			// keep the order of the query
+			wanted := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				wanted[id] = true
+			}
+			for _, r := range related {
+				if wanted[r.Id] {
+					items = append(items, r)
+				}
+			}
+		} else {
+			// keep the order of the relation values
+			for _, id := range ids {
+				if r, ok := byId[id]; ok {
+					items = append(items, r)
+				}
+			}
+		}
+
+		if field.IsMultiple() {
+			setExpand(record, node.name, items)
+		} else if len(items) > 0 {
+			setExpand(record, node.name, items[0])
+		}
+	}
+
+	return related, relCollection, nil
+}
+
+// preloadBack loads the records of the back-relation
+// "<collectionName>_via_<fieldName>" of records.
+func preloadBack(c *CollectionQueryBuilder, collection *core.Collection, records []*core.Record, collectionName, fieldName string, node *preloadNode) ([]*core.Record, *core.Collection, error) {
+	relCollection, err := c.app.FindCachedCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, nil, collectionError(err)
+	}
+	field, ok := relCollection.Fields.GetByName(fieldName).(*core.RelationField)
+	if !ok || field.CollectionId != collection.Id {
+		return nil, nil, fmt.Errorf("invalid back-relation %q: %s.%s is not a relation to %s", node.name, collectionName, fieldName, collection.Name)
+	}
+
+	placeholders := make([]string, len(records))
+	params := dbx.Params{}
+	for i, record := range records {
+		name := fmt.Sprintf("dslv%d", i)
+		placeholders[i] = "{:" + name + "}"
+		params[name] = record.Id
+	}
+	column := "[[" + relCollection.Name + "." + field.Name + "]]"
+	var condition dbx.Expression
+	if field.IsMultiple() {
+		condition = dbx.NewExp("EXISTS (SELECT 1 FROM json_each("+column+") WHERE json_each.value IN ("+strings.Join(placeholders, ",")+"))", params)
+	} else {
+		condition = dbx.NewExp(column+" IN ("+strings.Join(placeholders, ",")+")", params)
+	}
+
+	var related []*core.Record
+	var byParent map[string][]*core.Record
+	if node.limit() > 0 {
+		parent := column
+		var join func(q *dbx.SelectQuery)
+		if field.IsMultiple() {
+			// one row per parent listed in the relation values
+			parent = "[[dsl_item.value]]"
+			join = func(q *dbx.SelectQuery) {
+				q.InnerJoin("json_each("+column+") {{dsl_item}}", dbx.NewExp("[[dsl_item.value]] IN ("+strings.Join(placeholders, ",")+")", params))
+			}
+		}
+		byParent, related, err = preloadLimited(c, relCollection, node, condition, join, parent, "")
+	} else {
+		related, err = preloadQuery(c, relCollection, node, condition)
+		byParent = map[string][]*core.Record{}
+		for _, r := range related {
+			for _, parentId := range r.GetStringSlice(field.Name) {
+				byParent[parentId] = append(byParent[parentId], r)
+			}
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, record := range records {
+		if items := byParent[record.Id]; len(items) > 0 {
+			setExpand(record, node.name, items)
+		}
+	}
+
+	return related, relCollection, nil
+}
+
+// preloadLimited loads the first node.limit() records of collection per
+// parent record, for the records matching condition. join adds the joins
+// the parent and position expressions need: parent is the id of the parent
+// record of a row and position, if not empty, orders the records of a
+// parent when the node isn't sorted (the record id breaks ties).
+//
+// The rows are ranked with a window function, so the database only returns
+// the kept records. It returns them by parent id, in order, and once each.
+func preloadLimited(c *CollectionQueryBuilder, collection *core.Collection, node *preloadNode, condition dbx.Expression, join func(q *dbx.SelectQuery), parent, position string) (map[string][]*core.Record, []*core.Record, error) {
+	options := *node.options
+
+	rc := c.related(collection.Id)
+	s, err := rc.filteredSelect(options, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid preload %q: %w", node.name, err)
+	}
+	if join != nil {
+		join(s.query)
+	}
+	s.query.AndWhere(condition)
+	order, err := s.sortExprs(options.sort)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid preload %q: %w", node.name, err)
+	}
+	if len(order) == 0 && position != "" {
+		order = append(order, position)
+	}
+	order = append(order, "[["+collection.Name+".id]]")
+	// DENSE_RANK gives the same rank to the duplicated rows of a DISTINCT
+	// query with relation joins
+	s.query.AndSelect(
+		"DENSE_RANK() OVER (PARTITION BY "+parent+" ORDER BY "+strings.Join(order, ", ")+") AS [[dsl_rank]]",
+		parent+" AS [[dsl_parent]]",
+	)
+
+	inner := s.build().Build()
+	params := dbx.Params{"dslLimit": node.limit()}
+	for name, value := range inner.Params() {
+		params[name] = value
+	}
+	q := c.app.DB().NewQuery("SELECT * FROM (" + inner.SQL() + ") WHERE [[dsl_rank]] <= {:dslLimit} ORDER BY [[dsl_parent]], [[dsl_rank]]").Bind(params)
+	if c.ctx != nil {
+		q.WithContext(c.ctx)
+	}
+	instrumentQuery(c.app, collection.Name, q, options.debug)
+
+	rows := []dbx.NullStringMap{}
+	if err := q.All(&rows); err != nil {
+		return nil, nil, rc.contextError(err)
+	}
+
+	byId := map[string]*core.Record{}
+	byParent := map[string][]*core.Record{}
+	related := []*core.Record{}
+	for _, row := range rows {
+		record, ok := byId[row[core.FieldNameId].String]
+		if !ok {
+			record, err = recordFromRow(collection, row)
+			if err != nil {
+				return nil, nil, err
+			}
+			byId[record.Id] = record
+			related = append(related, record)
+		}
+		parentId := row["dsl_parent"].String
+		if !slices.Contains(byParent[parentId], record) {
+			byParent[parentId] = append(byParent[parentId], record)
+		}
+	}
+	return byParent, related, nil
+}
+
+// recordFromRow loads a record of collection from a database row, like the
+// record queries of the app do.
+func recordFromRow(collection *core.Collection, row dbx.NullStringMap) (*core.Record, error) {
+	record := core.NewRecord(collection)
+	for _, field := range collection.Fields {
+		var value any
+		if column, ok := row[field.GetName()]; ok && column.Valid {
+			value = column.String
+		}
+		prepared, err := field.PrepareValue(record, value)
+		if err != nil {
+			return nil, err
+		}
+		record.SetRaw(field.GetName(), prepared)
+	}
+	if err := record.PostScan(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// setExpand sets a single expand entry of record.
+func setExpand(record *core.Record, name string, value any) {
+	expand := record.Expand()
+	expand[name] = value
+	record.SetExpand(expand)
+}
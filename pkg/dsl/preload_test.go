@@ -0,0 +1,226 @@
+package dsl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newPreloadApp extends the test app with "reviews" (single relation to
+// products) and "bundles" (multiple relation to products) and seeds them.
+func newPreloadApp(t *testing.T) *tests.TestApp {
+	app := newTestApp(t)
+
+	products, err := app.FindCollectionByNameOrId("products")
+	if err != nil {
+		t.Fatalf("Failed to find products collection: %v", err)
+	}
+
+	reviews := core.NewBaseCollection("reviews")
+	reviews.Fields.Add(
+		&core.RelationField{Name: "product", CollectionId: products.Id, MaxSelect: 1},
+		&core.NumberField{Name: "score"},
+	)
+	if err := app.Save(reviews); err != nil {
+		t.Fatalf("Failed to create reviews collection: %v", err)
+	}
+
+	bundles := core.NewBaseCollection("bundles")
+	bundles.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.RelationField{Name: "products", CollectionId: products.Id, MaxSelect: 10},
+	)
+	if err := app.Save(bundles); err != nil {
+		t.Fatalf("Failed to create bundles collection: %v", err)
+	}
+
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	books := mustCreate(t, app, "categories", map[string]any{"name": "books"})
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "category": tools.Id})
+	saw := mustCreate(t, app, "products", map[string]any{"name": "saw", "category": tools.Id})
+	novel := mustCreate(t, app, "products", map[string]any{"name": "novel", "category": books.Id})
+
+	for i, id := range []string{hammer.Id, hammer.Id, hammer.Id, hammer.Id, saw.Id} {
+		mustCreate(t, app, "reviews", map[string]any{"product": id, "score": i + 1})
+	}
+	mustCreate(t, app, "bundles", map[string]any{"name": "diy", "products": []string{saw.Id, hammer.Id}})
+	mustCreate(t, app, "bundles", map[string]any{"name": "gift", "products": []string{novel.Id, hammer.Id}})
+
+	return app
+}
+
+// countQueries returns a function reporting the number of SELECT queries
+// executed since countQueries was called.
+func countQueries(app core.App) func() int {
+	count := 0
+	db := app.ConcurrentDB().(*dbx.DB)
+	db.QueryLogFunc = func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
+		count++
+	}
+	return func() int {
+		return count
+	}
+}
+
+// expandedNames returns the names of the records expanded under relation.
+func expandedNames(record *core.Record, relation string) string {
+	return recordNames(record.ExpandedAll(relation))
+}
+
+func TestPreloadForward(t *testing.T) {
+	app := newPreloadApp(t)
+
+	queries := countQueries(app)
+	bundles, err := Collection(app, "bundles").List(*Query("").Sort("name").Preload("products.category"))
+	if err != nil {
+		t.Fatalf("Failed to list bundles: %v", err)
+	}
+	if n := queries(); n != 3 {
+		t.Errorf("Expected 3 queries (bundles, products, categories), got %d", n)
+	}
+
+	if names := expandedNames(bundles[0], "products"); names != "saw,hammer" {
+		t.Errorf("Expected saw,hammer in relation order, got %s", names)
+	}
+	if names := expandedNames(bundles[1], "products"); names != "novel,hammer" {
+		t.Errorf("Expected novel,hammer in relation order, got %s", names)
+	}
+	novel := bundles[1].ExpandedAll("products")[0]
+	if category := novel.ExpandedOne("category"); category == nil || category.GetString("name") != "books" {
+		t.Errorf("Expected the nested books category, got %v", category)
+	}
+
+	// per-relation filter, sort and limit
+	bundles, err = Collection(app, "bundles").List(*Query("").Sort("name").Preload("products", Query("name != 'saw'").Sort("name").Page(0, 1)))
+	if err != nil {
+		t.Fatalf("Failed to list bundles: %v", err)
+	}
+	if names := expandedNames(bundles[0], "products"); names != "hammer" {
+		t.Errorf("Expected only hammer, got %s", names)
+	}
+	if names := expandedNames(bundles[1], "products"); names != "hammer" {
+		t.Errorf("Expected hammer first by name, got %s", names)
+	}
+
+	// single relations are expanded as a single record
+	products, err := Collection(app, "products").List(*Query("").Sort("name").Preload("category"))
+	if err != nil {
+		t.Fatalf("Failed to list products: %v", err)
+	}
+	if category, ok := products[0].Expand()["category"].(*core.Record); !ok || category.GetString("name") != "tools" {
+		t.Errorf("Expected a single tools category, got %v", products[0].Expand()["category"])
+	}
+}
+
+func TestPreloadBack(t *testing.T) {
+	app := newPreloadApp(t)
+
+	queries := countQueries(app)
+	products, err := Collection(app, "products").List(*Query("").Sort("name").
+		Preload("reviews_via_product", Query("score > 1").Sort("-score").Page(0, 2)).
+		Preload("bundles_via_products"))
+	if err != nil {
+		t.Fatalf("Failed to list products: %v", err)
+	}
+	if n := queries(); n != 3 {
+		t.Errorf("Expected 3 queries (products, reviews, bundles), got %d", n)
+	}
+
+	scores := map[string]string{}
+	bundles := map[string]string{}
+	for _, product := range products {
+		var s []string
+		for _, review := range product.ExpandedAll("reviews_via_product") {
+			s = append(s, review.GetString("score"))
+		}
+		scores[product.GetString("name")] = fmt.Sprint(s)
+
+		var b []string
+		for _, bundle := range product.ExpandedAll("bundles_via_products") {
+			b = append(b, bundle.GetString("name"))
+		}
+		bundles[product.GetString("name")] = fmt.Sprint(b)
+	}
+
+	expectedScores := map[string]string{"hammer": "[4 3]", "saw": "[5]", "novel": "[]"}
+	if fmt.Sprint(scores) != fmt.Sprint(expectedScores) {
+		t.Errorf("Expected review scores %v, got %v", expectedScores, scores)
+	}
+	if bundles["hammer"] != "[diy gift]" || bundles["novel"] != "[gift]" {
+		t.Errorf("Unexpected bundles %v", bundles)
+	}
+}
+
+func TestPreloadLimit(t *testing.T) {
+	app := newPreloadApp(t)
+	saw, err := Collection(app, "products").First(*Query("name = 'saw'"))
+	if err != nil {
+		t.Fatalf("Failed to find saw: %v", err)
+	}
+
+	var statements []string
+	db := app.ConcurrentDB().(*dbx.DB)
+	db.QueryLogFunc = func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
+		statements = append(statements, sql)
+	}
+
+	categories, err := Collection(app, "categories").List(*Query("").Sort("name").
+		Preload("products_via_category", Query("").Sort("name").Page(0, 1)).
+		Preload("products_via_category.reviews_via_product"))
+	if err != nil {
+		t.Fatalf("Failed to list categories: %v", err)
+	}
+	if names := expandedNames(categories[0], "products_via_category"); names != "novel" {
+		t.Errorf("Expected novel for books, got %s", names)
+	}
+	tools := categories[1].ExpandedAll("products_via_category")
+	if names := recordNames(tools); names != "hammer" {
+		t.Errorf("Expected only hammer for tools, got %s", names)
+	}
+	if len(tools) == 1 && len(tools[0].ExpandedAll("reviews_via_product")) != 4 {
+		t.Errorf("Expected the 4 nested hammer reviews, got %v", tools[0].ExpandedAll("reviews_via_product"))
+	}
+
+	if len(statements) != 3 {
+		t.Fatalf("Expected 3 queries, got %d: %v", len(statements), statements)
+	}
+	if !strings.Contains(statements[1], "DENSE_RANK") {
+		t.Errorf("Expected the limit to be applied in SQL, got %s", statements[1])
+	}
+	if strings.Contains(statements[2], saw.Id) {
+		t.Errorf("Expected the nested preload to skip the discarded saw, got %s", statements[2])
+	}
+
+	// limited multiple relation, in relation order
+	bundles, err := Collection(app, "bundles").List(*Query("").Sort("name").Preload("products", Query("").Page(0, 1)))
+	if err != nil {
+		t.Fatalf("Failed to list bundles: %v", err)
+	}
+	if names := expandedNames(bundles[0], "products") + "," + expandedNames(bundles[1], "products"); names != "saw,novel" {
+		t.Errorf("Expected the first product of each bundle, got %s", names)
+	}
+}
+
+func TestPreloadErrors(t *testing.T) {
+	app := newPreloadApp(t)
+	c := Collection(app, "products")
+
+	for _, path := range []string{"missing", "name", "reviews_via_score", "missing_via_product", "category.missing"} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := c.List(*Query("").Preload(path)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, err := c.List(*Query("").Preload("reviews_via_product", Query("missing = 1"))); err == nil {
+		t.Error("Expected error for an invalid preload filter")
+	}
+}
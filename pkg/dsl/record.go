@@ -18,12 +18,17 @@
 package dsl
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
 	"strings"
+	"time"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
 )
 
 // QueryBuilder represents a query configuration for building complex
@@ -33,11 +38,24 @@ import (
 // QueryBuilder provides a fluent interface that allows chaining multiple
 // operations together to create sophisticated queries.
 type QueryBuilder struct {
-	filter  string       // The filter expression (e.g., "status = 'active'")
-	page    int          // Current page number (1-based)
-	perPage int          // Number of items per page
-	expand  string       // Comma-separated list of relations to expand
-	sort    string       // Sort expression (e.g., "-created,name")
+	filter    string        // The filter expression (e.g., "status = 'active'")
+	page      int           // Current page number (1-based)
+	perPage   int           // Number of items per page
+	expand    string        // Comma-separated list of relations to expand
+	sort      string        // Sort expression (e.g., "-created,name")
+	params    dbx.Params    // Parameters bound to the filter placeholders
+	err       error         // Deferred error from composing filter expressions
+	skipTotal bool          // Whether ListPage skips counting the total items
+	fields    []string      // Projected fields, see Fields
+	preloads  []preload     // Relations to load in batches, see Preload
+	cacheTTL  time.Duration // Result cache duration, see Cache
+	debug     bool          // Whether the executed SQL is logged, see Debug
+	search    string        // Full-text search terms, see Search
+
+	noHiddenFields bool // Whether the filter and sort can't use hidden fields, see ParseQuery
+
+	cursor       string // Keyset pagination cursor for ListCursor
+	cursorBefore bool   // Whether to fetch the items before the cursor
 }
 
 // Query creates a new QueryBuilder with the specified filter expression.
@@ -58,6 +76,71 @@ func Query(filter string) *QueryBuilder {
 	}
 }
 
+// Where creates a new QueryBuilder from structured filter expressions.
+//
+// All expressions are ANDed together and their values are bound to
+// auto-generated placeholders, so no separate dbx.Params map is needed.
+//
+// Example:
+//
+//	query := dsl.Where(dsl.Eq("status", "active"), dsl.Gte("age", 18))
+func Where(exprs ...Expr) *QueryBuilder {
+	return Query("").And(exprs...)
+}
+
+// And ANDs the given filter expressions into the query.
+//
+// It can be called multiple times and combined with a raw filter passed to
+// Query; generated placeholders never collide with the ones already bound.
+// A compile error is deferred and returned by the collection method the
+// query is passed to.
+//
+// Example:
+//
+//	func onlyVisible(userId string) dsl.Expr {
+//	    return dsl.Or(dsl.Eq("public", true), dsl.Eq("owner", userId))
+//	}
+//
+//	query := dsl.Query("status = 'active'").And(onlyVisible(userId))
+func (q *QueryBuilder) And(exprs ...Expr) *QueryBuilder {
+	if q.err != nil {
+		return q
+	}
+	// copies of a query share the map, so it's never written in place
+	q.params = maps.Clone(q.params)
+	if q.params == nil {
+		q.params = dbx.Params{}
+	}
+	c := &filterCompiler{params: q.params}
+	filter, err := And(exprs...).compile(c)
+	if err != nil {
+		q.err = err
+		return q
+	}
+	q.filter = andFilters(q.filter, filter)
+	return q
+}
+
+// Params binds values to the placeholders used in the filter expression.
+//
+// Params can be called multiple times; later values override earlier
+// ones with the same name. Parameters passed directly to List, First, etc.
+// take precedence over the ones bound here.
+//
+// Example:
+//
+//	query := dsl.Query("status = {:status}").Params(dbx.Params{"status": "active"})
+func (q *QueryBuilder) Params(params dbx.Params) *QueryBuilder {
+	q.params = maps.Clone(q.params)
+	if q.params == nil {
+		q.params = dbx.Params{}
+	}
+	for k, v := range params {
+		q.params[k] = v
+	}
+	return q
+}
+
 // Page sets the pagination parameters for the query.
 //
 // page specifies the page number (1-based), and perPage specifies
@@ -110,20 +193,55 @@ func (q *QueryBuilder) Sort(sort string) *QueryBuilder {
 	return q
 }
 
+// andFilters joins two PocketBase filter strings with "&&", skipping empty ones.
+func andFilters(a, b string) string {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return "(" + a + ") && (" + b + ")"
+}
+
+// mergeParams merges the query parameters with the ones passed to a
+// collection method into a single dbx.Params.
+func (q *QueryBuilder) mergeParams(params []dbx.Params) []dbx.Params {
+	if len(q.params) == 0 {
+		return params
+	}
+	merged := dbx.Params{}
+	for k, v := range q.params {
+		merged[k] = v
+	}
+	for _, p := range params {
+		for k, v := range p {
+			merged[k] = v
+		}
+	}
+	return []dbx.Params{merged}
+}
+
 // CollectionQueryBuilder represents a collection query builder that provides
 // methods for performing CRUD operations on a specific PocketBase collection.
 //
 // CollectionQueryBuilder is created by calling Collection() and provides
 // methods like One(), First(), List(), Create(), Update(), and Delete().
 type CollectionQueryBuilder struct {
-	app        core.App // The PocketBase app instance
-	collection string   // The collection name or ID
+	app         core.App          // The PocketBase app instance
+	collection  string            // The collection name or ID
+	scope       deletedScope      // Visible soft-deleted records, see WithDeleted
+	ctx         context.Context   // Context of the queries, see WithContext
+	requestInfo *core.RequestInfo // Request data the API rules are evaluated with, see As
+	err         error             // Deferred error of AsRequest, returned by every operation
 }
 
 // One retrieves a single record by ID from the collection.
 //
 // Returns the record if found, or nil if not found. An error is returned
 // if the collection doesn't exist or if there's a database error.
+// Soft-deleted records are reported as not found, see EnableSoftDelete.
 //
 // Example:
 //
@@ -132,7 +250,17 @@ type CollectionQueryBuilder struct {
 //	    // Handle error
 //	}
 func (c *CollectionQueryBuilder) One(id string) (*core.Record, error) {
-	return c.app.FindRecordById(c.collection, id)
+	record, err := c.findRecordById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !c.visible(record) {
+		return nil, notFoundError(sql.ErrNoRows)
+	}
+	if err := c.checkViewRule(record); err != nil {
+		return nil, err
+	}
+	return record, nil
 }
 
 // First retrieves the first record matching the query criteria.
@@ -149,32 +277,28 @@ func (c *CollectionQueryBuilder) One(id string) (*core.Record, error) {
 //	query := dsl.Query("email = {:email}").Sort("-created")
 //	record, err := dsl.Collection(app, "users").First(query, dbx.Params{"email": "user@example.com"})
 func (c *CollectionQueryBuilder) First(query QueryBuilder, params ...dbx.Params) (*core.Record, error) {
-	records, err := c.app.FindRecordsByFilter(
-		c.collection,
-		query.filter,
-		query.sort,
-		1, // limit to 1
-		0, // offset 0
-		params...,
-	)
+	if query.cacheTTL > 0 {
+		return cachedQuery(c, "first", query, params, func(query QueryBuilder) (*core.Record, error) {
+			return c.First(query, params...)
+		}, func(record *core.Record) *core.Record {
+			return cloneRecord(record, map[*core.Record]*core.Record{})
+		})
+	}
+	q, _, err := c.recordQuery(query, params)
 	if err != nil {
 		return nil, err
 	}
+	records := []*core.Record{}
+	if err := q.Limit(1).All(&records); err != nil {
+		return nil, c.contextError(err)
+	}
 	if len(records) == 0 {
-		return nil, sql.ErrNoRows
+		return nil, notFoundError(sql.ErrNoRows)
 	}
-	record := records[0]
-	if query.expand != "" {
-		expands := strings.Split(query.expand, ",")
-		for i, expand := range expands {
-			expands[i] = strings.TrimSpace(expand)
-		}
-		errs := c.app.ExpandRecord(record, expands, nil)
-		if len(errs) > 0 {
-			return nil, fmt.Errorf("failed to expand relations: %v", errs)
-		}
+	if err := c.finishRecords(records, query); err != nil {
+		return nil, err
 	}
-	return record, nil
+	return records[0], nil
 }
 
 // List retrieves multiple records based on the query criteria.
@@ -188,34 +312,264 @@ func (c *CollectionQueryBuilder) First(query QueryBuilder, params ...dbx.Params)
 //	query := dsl.Query("status = 'active'").Page(1, 10).Sort("-created").Expand("profile")
 //	records, err := dsl.Collection(app, "users").List(query)
 func (c *CollectionQueryBuilder) List(query QueryBuilder, params ...dbx.Params) ([]*core.Record, error) {
+	if query.cacheTTL > 0 {
+		return cachedQuery(c, "list", query, params, func(query QueryBuilder) ([]*core.Record, error) {
+			return c.List(query, params...)
+		}, cloneRecords)
+	}
+	q, _, err := c.listQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+	records := []*core.Record{}
+	if err := q.All(&records); err != nil {
+		return nil, c.contextError(err)
+	}
+	if err := c.finishRecords(records, query); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// listQuery builds the select query of List, including the pagination.
+func (c *CollectionQueryBuilder) listQuery(query QueryBuilder, params []dbx.Params) (*dbx.SelectQuery, *core.Collection, error) {
+	q, collection, err := c.recordQuery(query, params)
+	if err != nil {
+		return nil, nil, err
+	}
 	offset := (query.page - 1) * query.perPage
-	if offset < 0 {
-		offset = 0
-	}
-	records, err := c.app.FindRecordsByFilter(
-		c.collection,
-		query.filter,
-		query.sort,
-		query.perPage,
-		offset,
-		params...,
-	)
+	if offset > 0 {
+		q.Offset(int64(offset))
+	}
+	if query.perPage > 0 {
+		q.Limit(int64(query.perPage))
+	}
+	return q, collection, nil
+}
+
+// recordQuery builds the select query for the filter and sort of query,
+// including the joins required by relation fields, the same way
+// app.FindRecordsByFilter does. Pagination is left to the caller.
+func (c *CollectionQueryBuilder) recordQuery(query QueryBuilder, params []dbx.Params) (*dbx.SelectQuery, *core.Collection, error) {
+	s, err := c.filteredSelect(query, params)
 	if err != nil {
+		return nil, nil, err
+	}
+	sort := query.sort
+	if sort == "" && s.searchTable != "" {
+		sort = RankSort
+	}
+	if err := s.sortBy(sort); err != nil {
+		return nil, nil, err
+	}
+	return s.build(), s.collection, nil
+}
+
+// recordSelect is a record select query under construction together with
+// the field resolver that translates filter and sort fields into columns.
+//
+// Fields may be resolved as long as build hasn't been called, since the
+// joins they require are attached to the query only at that point.
+type recordSelect struct {
+	app         core.App                  // The PocketBase app instance
+	query       *dbx.SelectQuery          // The select query
+	collection  *core.Collection          // The queried collection
+	resolver    *core.RecordFieldResolver // The resolver collecting joins
+	debug       bool                      // Whether the executed SQL is logged
+	searchTable string                    // The joined search index table, see Search
+	built       bool                      // Whether build was called
+}
+
+// filteredSelect starts a record select query with the filter of query applied.
+func (c *CollectionQueryBuilder) filteredSelect(query QueryBuilder, params []dbx.Params) (*recordSelect, error) {
+	if query.err != nil {
+		return nil, query.err
+	}
+	if err := c.checkContext(); err != nil {
 		return nil, err
 	}
-	if query.expand != "" {
-		expands := strings.Split(query.expand, ",")
-		for i, expand := range expands {
-			expands[i] = strings.TrimSpace(expand)
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+
+	s := &recordSelect{
+		app:        c.app,
+		debug:      query.debug,
+		query:      c.withQueryContext(c.app.RecordQuery(collection)),
+		collection: collection,
+		resolver: core.NewRecordFieldResolver(
+			c.app,
+			collection,    // the base collection
+			c.requestInfo, // the @request data of As, if any
+			true,          // allow searching hidden/protected fields like "email"
+		),
+	}
+
+	if columns := projectedColumns(collection, query); columns != nil {
+		s.query.Select(columns...)
+	}
+
+	if expr := c.deletedExpr(collection); expr != nil {
+		s.query.AndWhere(expr)
+	}
+
+	if c.rules() != nil {
+		expr, err := ruleExpr(s.resolver, collection, collection.ListRule, "list")
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			s.query.AndWhere(expr)
 		}
-		for _, record := range records {
-			errs := c.app.ExpandRecord(record, expands, nil)
-			if len(errs) > 0 {
-				return nil, fmt.Errorf("failed to expand relations: %v", errs)
+	}
+
+	// like the PocketBase list API, the rule may use hidden fields but the
+	// filter and sort only can for superusers; the client input of
+	// ParseQuery never can
+	s.resolver.SetAllowHiddenFields(c.rules() == nil && !query.noHiddenFields)
+
+	if query.filter != "" {
+		expr, err := search.FilterData(query.filter).BuildExpr(s.resolver, query.mergeParams(params)...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter expression: %w", err)
+		}
+		s.query.AndWhere(expr)
+	}
+
+	if strings.TrimSpace(query.search) != "" {
+		if err := s.search(query.search); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// sortBy appends the ORDER BY clauses of a PocketBase sort expression.
+func (s *recordSelect) sortBy(sort string) error {
+	exprs, err := s.sortExprs(sort)
+	if err != nil {
+		return err
+	}
+	for _, expr := range exprs {
+		s.query.AndOrderBy(expr)
+	}
+	return nil
+}
+
+// sortExprs resolves a PocketBase sort expression into ORDER BY terms.
+func (s *recordSelect) sortExprs(sort string) ([]string, error) {
+	if sort == "" {
+		return nil, nil
+	}
+	var exprs []string
+	for _, sortField := range search.ParseSortFromString(sort) {
+		if sortField.Name == RankSort {
+			if s.searchTable == "" {
+				return nil, errors.New("sorting by " + RankSort + " requires Search")
 			}
+			exprs = append(exprs, "[["+s.searchTable+".rank]] "+sortField.Direction)
+			continue
+		}
+		expr, err := sortField.BuildExpr(s.resolver)
+		if err != nil {
+			return nil, err
+		}
+		if expr != "" {
+			exprs = append(exprs, expr)
 		}
 	}
-	return records, nil
+	return exprs, nil
+}
+
+// build attaches the joins collected by the resolver and the query
+// instrumentation, and returns the query.
+func (s *recordSelect) build() *dbx.SelectQuery {
+	s.resolver.UpdateQuery(s.query) // attaches any adhoc joins and aliases
+	if !s.built {
+		instrument(s.app, s.collection.Name, s.query, s.debug)
+		s.built = true
+	}
+	return s.query
+}
+
+// finishRecords expands the relations of query on the loaded records and
+// applies the fields projection.
+func (c *CollectionQueryBuilder) finishRecords(records []*core.Record, query QueryBuilder) error {
+	if keepsExpand(query.fields) {
+		if err := c.expandRecords(records, query.expand); err != nil {
+			return err
+		}
+		if err := c.preloadRecords(records, query.preloads); err != nil {
+			return err
+		}
+	}
+	return projectRecords(records, query.fields)
+}
+
+// splitExpand splits a comma-separated list of relations to expand.
+func splitExpand(expand string) []string {
+	if expand == "" {
+		return nil
+	}
+	expands := strings.Split(expand, ",")
+	for i, expand := range expands {
+		expands[i] = strings.TrimSpace(expand)
+	}
+	return expands
+}
+
+// expandRecords expands the comma-separated relations of expand on records.
+//
+// The relations are loaded for all records at once, with one query per
+// relation level.
+func (c *CollectionQueryBuilder) expandRecords(records []*core.Record, expand string) error {
+	expands := splitExpand(expand)
+	if len(expands) == 0 || len(records) == 0 {
+		return nil
+	}
+	errs := c.app.ExpandRecords(records, expands, c.expandFetch())
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to expand relations: %v", errs)
+	}
+	return nil
+}
+
+// expandFetch returns the function loading the expanded relations, which
+// skips the soft deleted related records like Preload and, for a
+// rule-aware builder, only loads the related records allowed by the
+// ViewRule of their collection.
+func (c *CollectionQueryBuilder) expandFetch() core.ExpandFetchFunc {
+	var info *core.RequestInfo
+	if rules := c.rules(); rules != nil {
+		expandInfo := *rules
+		expandInfo.Context = core.RequestInfoContextExpand
+		info = &expandInfo
+	}
+
+	return func(relCollection *core.Collection, relIds []string) ([]*core.Record, error) {
+		if info != nil && relCollection.ViewRule == nil {
+			return nil, nil // locked relations are left unexpanded
+		}
+		return c.app.FindRecordsByIds(relCollection.Id, relIds, func(q *dbx.SelectQuery) error {
+			c.withQueryContext(q)
+			if expr := Collection(c.app, relCollection.Id).deletedExpr(relCollection); expr != nil {
+				q.AndWhere(expr)
+			}
+			if info == nil {
+				return nil
+			}
+			resolver := core.NewRecordFieldResolver(c.app, relCollection, info, true)
+			expr, err := ruleExpr(resolver, relCollection, relCollection.ViewRule, "view")
+			if err != nil || expr == nil {
+				return err
+			}
+			resolver.UpdateQuery(q)
+			q.AndWhere(expr)
+			return nil
+		})
+	}
 }
 
 // Create creates a new record in the collection with the provided data.
@@ -234,12 +588,12 @@ func (c *CollectionQueryBuilder) List(query QueryBuilder, params ...dbx.Params)
 func (c *CollectionQueryBuilder) Create(recordMap map[string]any) (*core.Record, error) {
 	collection, err := c.app.FindCollectionByNameOrId(c.collection)
 	if err != nil {
-		return nil, fmt.Errorf("collection not found: %v", err)
+		return nil, collectionError(err)
 	}
 
 	record := core.NewRecord(collection)
 	record.Load(recordMap)
-	if err := c.app.Save(record); err != nil {
+	if err := c.save(record); err != nil {
 		return nil, err
 	}
 	return record, nil
@@ -259,12 +613,12 @@ func (c *CollectionQueryBuilder) Create(recordMap map[string]any) (*core.Record,
 //	}
 //	record, err := dsl.Collection(app, "users").Update("user123", data)
 func (c *CollectionQueryBuilder) Update(id string, recordMap map[string]any) (*core.Record, error) {
-	record, err := c.app.FindRecordById(c.collection, id)
+	record, err := c.One(id)
 	if err != nil {
 		return nil, err
 	}
 	record.Load(recordMap)
-	if err := c.app.Save(record); err != nil {
+	if err := c.save(record); err != nil {
 		return nil, err
 	}
 	return record, nil
@@ -273,17 +627,19 @@ func (c *CollectionQueryBuilder) Update(id string, recordMap map[string]any) (*c
 // Delete deletes a record by ID from the collection.
 //
 // The id parameter specifies the record to delete. The method returns
-// an error if the record doesn't exist or if deletion fails.
+// an error if the record doesn't exist or if deletion fails. Records of
+// soft-deletable collections are only marked as deleted, see
+// EnableSoftDelete and ForceDelete.
 //
 // Example:
 //
 //	err := dsl.Collection(app, "users").Delete("user123")
 func (c *CollectionQueryBuilder) Delete(id string) error {
-	record, err := c.app.FindRecordById(c.collection, id)
+	record, err := c.One(id)
 	if err != nil {
 		return err
 	}
-	return c.app.Delete(record)
+	return c.deleteRecord(record)
 }
 
 // Count returns the total number of records matching the filter criteria.
@@ -302,7 +658,53 @@ func (c *CollectionQueryBuilder) Delete(id string) error {
 //	// Count with parameters
 //	count, err := dsl.Collection(app, "users").Count("status = {:status}", dbx.Params{"status": "active"})
 func (c *CollectionQueryBuilder) Count(filter string, params ...dbx.Params) (int64, error) {
-	return c.app.CountRecords(c.collection, dbx.NewExp(filter, params...))
+	if err := c.checkContext(); err != nil {
+		return 0, err
+	}
+	collection, err := c.app.FindCachedCollectionByNameOrId(c.collection)
+	if err != nil {
+		return 0, collectionError(err)
+	}
+	exprs := []dbx.Expression{}
+	if strings.TrimSpace(filter) != "" {
+		exprs = append(exprs, dbx.NewExp(filter, params...))
+	}
+	if expr := c.deletedExpr(collection); expr != nil {
+		exprs = append(exprs, expr)
+	}
+
+	q := c.withQueryContext(c.app.RecordQuery(collection).Select("count(*)"))
+	if c.rules() != nil {
+		resolver := core.NewRecordFieldResolver(c.app, collection, c.requestInfo, true)
+		expr, err := ruleExpr(resolver, collection, collection.ListRule, "list")
+		if err != nil {
+			return 0, err
+		}
+		if expr != nil {
+			// the filter is applied to the bare collection through an id
+			// subquery, so that its columns aren't ambiguous with the joins
+			// of the rule, which may also repeat records
+			if len(exprs) > 0 {
+				ids := c.app.RecordQuery(collection).Select("[[" + collection.Name + ".id]]").AndWhere(dbx.And(exprs...)).Build()
+				exprs = []dbx.Expression{dbx.NewExp("[["+collection.Name+".id]] IN ("+ids.SQL()+")", ids.Params())}
+			}
+			exprs = append(exprs, expr)
+			q.Select("count(DISTINCT [[" + collection.Name + ".id]])")
+			if err := resolver.UpdateQuery(q); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if len(exprs) > 0 {
+		q.AndWhere(dbx.And(exprs...))
+	}
+	instrument(c.app, collection.Name, q, false)
+
+	var total int64
+	if err := q.Row(&total); err != nil {
+		return 0, c.contextError(err)
+	}
+	return total, nil
 }
 
 // Collection creates a new CollectionQueryBuilder for the specified collection.
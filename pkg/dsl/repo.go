@@ -0,0 +1,486 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// RepoTag is the struct tag used by Repo to map struct fields to
+// collection fields.
+//
+// The tag value is the collection field name, optionally followed by
+// comma-separated options:
+//   - omitempty: zero values are not written on Create/Update
+//   - readonly: the field is populated from records but never written
+//
+// Use "-" to explicitly skip a field. Fields without the tag are ignored.
+//
+// Example:
+//
+//	type Product struct {
+//	    ID      string         `pb:"id"`
+//	    Name    string         `pb:"name"`
+//	    Price   float64        `pb:"price"`
+//	    Tags    []string       `pb:"tags"`      // multiple select
+//	    Owner   string         `pb:"owner"`     // single relation
+//	    Meta    map[string]any `pb:"meta"`      // json
+//	    Created types.DateTime `pb:"created"`   // autodate, always readonly
+//	}
+const RepoTag = "pb"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	dateTimeType   = reflect.TypeOf(types.DateTime{})
+	geoPointType   = reflect.TypeOf(types.GeoPoint{})
+	jsonRawType    = reflect.TypeOf(types.JSONRaw{})
+	fileType       = reflect.TypeOf((*filesystem.File)(nil))
+	fileSliceType  = reflect.TypeOf([]*filesystem.File{})
+	stringSlice    = reflect.TypeOf([]string{})
+	structFieldsMu sync.RWMutex
+	structFields   = map[reflect.Type][]repoField{}
+)
+
+// repoField describes a single tagged struct field.
+type repoField struct {
+	index     []int  // The reflect field index path
+	goName    string // The Go struct field name (used in error messages)
+	name      string // The collection field name
+	omitEmpty bool   // Whether zero values are skipped on write
+	readOnly  bool   // Whether the field is never written
+}
+
+// MappingError reports mismatches between a struct type and the schema of
+// the collection it is mapped to.
+//
+// Each entry in Problems describes a single offending struct field.
+type MappingError struct {
+	Type       string   // The mapped Go type name
+	Collection string   // The collection name
+	Problems   []string // One message per mismatched field
+}
+
+// Error implements the error interface.
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("cannot map %s to collection %q: %s", e.Type, e.Collection, strings.Join(e.Problems, "; "))
+}
+
+// Repo is a typed repository that maps records of a single collection to
+// values of the struct type T.
+//
+// Repo is built on top of CollectionQueryBuilder and offers the same
+// operations, but accepts and returns T instead of *core.Record. Struct
+// fields are mapped to collection fields through the RepoTag struct tag,
+// and the mapping is checked against the collection schema before every
+// read or write so that schema drift is reported as a *MappingError
+// instead of silently producing zero values.
+//
+// Example:
+//
+//	repo := dsl.NewRepo[Product](app, "products")
+//	products, err := repo.List(*dsl.Query("price > 0").Sort("-created"))
+type Repo[T any] struct {
+	collection *CollectionQueryBuilder // The underlying collection query builder
+}
+
+// NewRepo creates a new Repo for the specified collection.
+//
+// T must be a struct type. The mapping is validated lazily, on first use,
+// against the current collection schema.
+//
+// Example:
+//
+//	repo := dsl.NewRepo[Product](app, "products")
+func NewRepo[T any](app core.App, collection string) *Repo[T] {
+	return &Repo[T]{
+		collection: Collection(app, collection),
+	}
+}
+
+// Collection returns the CollectionQueryBuilder the repository is built on,
+// which can be used for operations that work with raw records.
+func (r *Repo[T]) Collection() *CollectionQueryBuilder {
+	return r.collection
+}
+
+// One retrieves a single record by ID and maps it to T.
+//
+// Example:
+//
+//	product, err := dsl.NewRepo[Product](app, "products").One("abc123")
+func (r *Repo[T]) One(id string) (T, error) {
+	record, err := r.collection.One(id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
+
+// First retrieves the first record matching the query criteria and maps it to T.
+//
+// Example:
+//
+//	query := dsl.Query("name = {:name}")
+//	product, err := repo.First(*query, dbx.Params{"name": "Widget"})
+func (r *Repo[T]) First(query QueryBuilder, params ...dbx.Params) (T, error) {
+	record, err := r.collection.First(query, params...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
+
+// List retrieves multiple records matching the query criteria and maps
+// each of them to T.
+//
+// Example:
+//
+//	products, err := repo.List(*dsl.Query("").Page(1, 20).Sort("name"))
+func (r *Repo[T]) List(query QueryBuilder, params ...dbx.Params) ([]T, error) {
+	records, err := r.collection.List(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	return r.FromRecords(records)
+}
+
+// Create inserts a new record built from item and returns the stored
+// value, including the generated ID and autodate fields.
+//
+// Example:
+//
+//	created, err := repo.Create(Product{Name: "Widget", Price: 10})
+func (r *Repo[T]) Create(item T) (T, error) {
+	data, err := r.ToMap(item)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	record, err := r.collection.Create(data)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
+
+// Update overwrites the mapped fields of the record with the given ID with
+// the values of item and returns the stored value.
+//
+// Fields tagged with omitempty are left untouched when they hold their
+// zero value, which allows partial updates.
+//
+// Example:
+//
+//	updated, err := repo.Update(product.ID, product)
+func (r *Repo[T]) Update(id string, item T) (T, error) {
+	data, err := r.ToMap(item)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	delete(data, core.FieldNameId)
+	record, err := r.collection.Update(id, data)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.FromRecord(record)
+}
+
+// Delete deletes the record with the given ID.
+//
+// Example:
+//
+//	err := repo.Delete("abc123")
+func (r *Repo[T]) Delete(id string) error {
+	return r.collection.Delete(id)
+}
+
+// FromRecord maps a single record to T.
+//
+// Returns a *MappingError if T does not match the record's collection schema.
+func (r *Repo[T]) FromRecord(record *core.Record) (T, error) {
+	var item T
+	fields, err := mappedFields(reflect.TypeOf(item), record.Collection())
+	if err != nil {
+		return item, err
+	}
+	v := reflect.ValueOf(&item).Elem()
+	for _, f := range fields {
+		if err := decodeField(v.FieldByIndex(f.index), record, f.name); err != nil {
+			return item, fmt.Errorf("failed to decode field %q: %w", f.name, err)
+		}
+	}
+	return item, nil
+}
+
+// FromRecords maps a list of records to a slice of T.
+func (r *Repo[T]) FromRecords(records []*core.Record) ([]T, error) {
+	items := make([]T, len(records))
+	for i, record := range records {
+		item, err := r.FromRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// ToMap converts item into a data map suitable for CollectionQueryBuilder.Create
+// and CollectionQueryBuilder.Update.
+//
+// Readonly fields, autodate fields and empty IDs are omitted.
+func (r *Repo[T]) ToMap(item T) (map[string]any, error) {
+	collection, err := r.collection.app.FindCachedCollectionByNameOrId(r.collection.collection)
+	if err != nil {
+		return nil, collectionError(err)
+	}
+	fields, err := mappedFields(reflect.TypeOf(item), collection)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(item)
+	data := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if f.readOnly {
+			continue
+		}
+		if _, ok := collection.Fields.GetByName(f.name).(*core.AutodateField); ok {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if fv.IsZero() && (f.omitEmpty || f.name == core.FieldNameId) {
+			continue
+		}
+		data[f.name] = encodeField(fv)
+	}
+	return data, nil
+}
+
+// mappedFields returns the tagged fields of t after validating them against
+// the collection schema.
+func mappedFields(t reflect.Type, collection *core.Collection) ([]repoField, error) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repo type must be a struct, got %v", t)
+	}
+	fields := parseStructFields(t)
+
+	var problems []string
+	for _, f := range fields {
+		field := collection.Fields.GetByName(f.name)
+		if field == nil {
+			problems = append(problems, fmt.Sprintf("field %s: collection has no field %q", f.goName, f.name))
+			continue
+		}
+		ft := t.FieldByIndex(f.index).Type
+		if !isCompatible(ft, field) {
+			problems = append(problems, fmt.Sprintf("field %s: Go type %v is incompatible with %s field %q", f.goName, ft, describeField(field), f.name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &MappingError{Type: t.String(), Collection: collection.Name, Problems: problems}
+	}
+	return fields, nil
+}
+
+// parseStructFields extracts and caches the RepoTag-tagged fields of t,
+// including the fields of embedded structs.
+func parseStructFields(t reflect.Type) []repoField {
+	structFieldsMu.RLock()
+	fields, ok := structFields[t]
+	structFieldsMu.RUnlock()
+	if ok {
+		return fields
+	}
+
+	fields = collectStructFields(t, nil)
+
+	structFieldsMu.Lock()
+	structFields[t] = fields
+	structFieldsMu.Unlock()
+	return fields
+}
+
+func collectStructFields(t reflect.Type, parent []int) []repoField {
+	var fields []repoField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag, hasTag := sf.Tag.Lookup(RepoTag)
+		if !hasTag {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				fields = append(fields, collectStructFields(sf.Type, index)...)
+			}
+			continue
+		}
+		if tag == "-" || !sf.IsExported() {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := repoField{index: index, goName: sf.Name, name: strings.TrimSpace(parts[0])}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range parts[1:] {
+			switch strings.TrimSpace(opt) {
+			case "omitempty":
+				f.omitEmpty = true
+			case "readonly":
+				f.readOnly = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// isMultiple reports whether the collection field holds a list of values.
+func isMultiple(field core.Field) bool {
+	if m, ok := field.(interface{ IsMultiple() bool }); ok {
+		return m.IsMultiple()
+	}
+	return false
+}
+
+func describeField(field core.Field) string {
+	if isMultiple(field) {
+		return "multiple " + field.Type()
+	}
+	return field.Type()
+}
+
+// isCompatible reports whether values of the Go type t can be mapped to
+// and from the collection field.
+func isCompatible(t reflect.Type, field core.Field) bool {
+	switch field.Type() {
+	case core.FieldTypeText, core.FieldTypeEmail, core.FieldTypeURL,
+		core.FieldTypeEditor, core.FieldTypePassword:
+		return t.Kind() == reflect.String
+	case core.FieldTypeNumber:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	case core.FieldTypeBool:
+		return t.Kind() == reflect.Bool
+	case core.FieldTypeDate, core.FieldTypeAutodate:
+		return t == dateTimeType || t == timeType || t.Kind() == reflect.String
+	case core.FieldTypeGeoPoint:
+		return t == geoPointType
+	case core.FieldTypeJSON:
+		return true
+	case core.FieldTypeRelation, core.FieldTypeSelect:
+		if isMultiple(field) {
+			return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+		}
+		return t.Kind() == reflect.String
+	case core.FieldTypeFile:
+		if isMultiple(field) {
+			return t == fileSliceType || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String)
+		}
+		return t == fileType || t.Kind() == reflect.String
+	}
+	return false
+}
+
+// decodeField sets dst from the normalized record value of the named field.
+func decodeField(dst reflect.Value, record *core.Record, name string) error {
+	t := dst.Type()
+	field := record.Collection().Fields.GetByName(name)
+
+	if field.Type() == core.FieldTypeJSON {
+		if t == jsonRawType {
+			raw, _ := record.Get(name).(types.JSONRaw)
+			dst.Set(reflect.ValueOf(raw))
+			return nil
+		}
+		raw, _ := record.Get(name).(types.JSONRaw)
+		if len(raw) == 0 || string(raw) == "null" {
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+		return json.Unmarshal(raw, dst.Addr().Interface())
+	}
+
+	switch {
+	case t == fileType || t == fileSliceType:
+		// pending uploads are write-only and never populated from records
+		return nil
+	case t == dateTimeType:
+		dst.Set(reflect.ValueOf(record.GetDateTime(name)))
+		return nil
+	case t == timeType:
+		dst.Set(reflect.ValueOf(record.GetDateTime(name).Time()))
+		return nil
+	case t == geoPointType:
+		dst.Set(reflect.ValueOf(record.GetGeoPoint(name)))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		dst.SetString(record.GetString(name))
+	case reflect.Bool:
+		dst.SetBool(record.GetBool(name))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(int64(record.GetFloat(name)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dst.SetUint(uint64(record.GetFloat(name)))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(record.GetFloat(name))
+	case reflect.Slice:
+		values := record.GetStringSlice(name)
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			slice.Index(i).SetString(value)
+		}
+		dst.Set(slice)
+	default:
+		return fmt.Errorf("unsupported Go type %v", t)
+	}
+	return nil
+}
+
+// encodeField converts a struct field value into a value accepted by
+// core.Record.Set.
+func encodeField(v reflect.Value) any {
+	switch v.Type() {
+	case timeType:
+		dt, _ := types.ParseDateTime(v.Interface().(time.Time))
+		return dt
+	case fileSliceType:
+		files := v.Interface().([]*filesystem.File)
+		values := make([]any, len(files))
+		for i, f := range files {
+			values[i] = f
+		}
+		return values
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && v.Type() != stringSlice {
+		// named element types, e.g. []Status for select enums
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).String()
+		}
+		return values
+	}
+	if v.Kind() == reflect.String && v.Type() != reflect.TypeOf("") {
+		return v.String()
+	}
+	return v.Interface()
+}
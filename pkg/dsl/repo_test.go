@@ -0,0 +1,179 @@
+package dsl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+type repoTestStatus string
+
+type repoTestMeta struct {
+	Color string `json:"color"`
+}
+
+type repoTestProduct struct {
+	ID       string           `pb:"id"`
+	Name     string           `pb:"name"`
+	Price    int              `pb:"price"`
+	Active   bool             `pb:"active"`
+	Status   repoTestStatus   `pb:"status"`
+	Tags     []repoTestStatus `pb:"tags,omitempty"`
+	Owner    string           `pb:"owner"`
+	Meta     repoTestMeta     `pb:"meta"`
+	Released time.Time        `pb:"released"`
+	Images   []string         `pb:"images,omitempty"`
+	Created  types.DateTime   `pb:"created"`
+	Note     string           `pb:"name,readonly"`
+	Ignored  string
+	Skipped  string `pb:"-"`
+}
+
+func newRepoTestCollection() *core.Collection {
+	collection := core.NewBaseCollection("products")
+	collection.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.NumberField{Name: "price"},
+		&core.BoolField{Name: "active"},
+		&core.SelectField{Name: "status", Values: []string{"draft", "published"}, MaxSelect: 1},
+		&core.SelectField{Name: "tags", Values: []string{"a", "b"}, MaxSelect: 2},
+		&core.RelationField{Name: "owner", CollectionId: "users", MaxSelect: 1},
+		&core.JSONField{Name: "meta"},
+		&core.DateField{Name: "released"},
+		&core.FileField{Name: "images", MaxSelect: 5},
+		&core.AutodateField{Name: "created", OnCreate: true},
+	)
+	return collection
+}
+
+func TestRepoFieldMapping(t *testing.T) {
+	collection := newRepoTestCollection()
+	fields, err := mappedFields(reflect.TypeOf(repoTestProduct{}), collection)
+	if err != nil {
+		t.Fatalf("Expected mapping to succeed, got %v", err)
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	expected := "id,name,price,active,status,tags,owner,meta,released,images,created,name"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("Expected mapped fields '%s', got '%s'", expected, strings.Join(names, ","))
+	}
+}
+
+func TestRepoFromRecord(t *testing.T) {
+	collection := newRepoTestCollection()
+	record := core.NewRecord(collection)
+	record.Id = "p1"
+	record.Load(map[string]any{
+		"name":     "Widget",
+		"price":    12,
+		"active":   true,
+		"status":   "published",
+		"tags":     []string{"a", "b"},
+		"owner":    "u1",
+		"meta":     map[string]any{"color": "red"},
+		"released": "2024-01-02 03:04:05.000Z",
+		"images":   []string{"a.png", "b.png"},
+	})
+
+	repo := &Repo[repoTestProduct]{}
+	product, err := repo.FromRecord(record)
+	if err != nil {
+		t.Fatalf("Failed to map record: %v", err)
+	}
+
+	if product.ID != "p1" || product.Name != "Widget" || product.Price != 12 || !product.Active {
+		t.Errorf("Unexpected scalar values: %+v", product)
+	}
+	if product.Status != "published" {
+		t.Errorf("Expected status 'published', got '%s'", product.Status)
+	}
+	if len(product.Tags) != 2 || product.Tags[1] != "b" {
+		t.Errorf("Expected tags [a b], got %v", product.Tags)
+	}
+	if product.Owner != "u1" {
+		t.Errorf("Expected owner 'u1', got '%s'", product.Owner)
+	}
+	if product.Meta.Color != "red" {
+		t.Errorf("Expected meta color 'red', got '%s'", product.Meta.Color)
+	}
+	if product.Released.Year() != 2024 || product.Released.Second() != 5 {
+		t.Errorf("Unexpected released time %v", product.Released)
+	}
+	if len(product.Images) != 2 {
+		t.Errorf("Expected 2 images, got %v", product.Images)
+	}
+	if product.Note != "Widget" {
+		t.Errorf("Expected readonly field to be populated, got '%s'", product.Note)
+	}
+}
+
+func TestRepoEncodeFields(t *testing.T) {
+	collection := newRepoTestCollection()
+	fields, _ := mappedFields(reflect.TypeOf(repoTestProduct{}), collection)
+
+	product := repoTestProduct{
+		Name:     "Widget",
+		Status:   "draft",
+		Tags:     []repoTestStatus{"a"},
+		Released: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	v := reflect.ValueOf(product)
+
+	for _, f := range fields {
+		if f.name != "tags" && f.name != "released" && f.name != "status" {
+			continue
+		}
+		switch value := encodeField(v.FieldByIndex(f.index)).(type) {
+		case []string:
+			if len(value) != 1 || value[0] != "a" {
+				t.Errorf("Expected tags [a], got %v", value)
+			}
+		case string:
+			if value != "draft" {
+				t.Errorf("Expected status 'draft', got '%s'", value)
+			}
+		case types.DateTime:
+			if value.String() != "2024-01-02 03:04:05.000Z" {
+				t.Errorf("Unexpected released value '%s'", value.String())
+			}
+		default:
+			t.Errorf("Unexpected encoded type %T for field %s", value, f.name)
+		}
+	}
+}
+
+func TestRepoMappingErrors(t *testing.T) {
+	type badProduct struct {
+		Name   int      `pb:"name"`
+		Tags   string   `pb:"tags"`
+		Owner  []string `pb:"owner"`
+		Unknow string   `pb:"missing"`
+	}
+
+	_, err := mappedFields(reflect.TypeOf(badProduct{}), newRepoTestCollection())
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) {
+		t.Fatalf("Expected *MappingError, got %v", err)
+	}
+
+	if len(mappingErr.Problems) != 4 {
+		t.Fatalf("Expected 4 problems, got %d: %v", len(mappingErr.Problems), mappingErr.Problems)
+	}
+	if mappingErr.Collection != "products" {
+		t.Errorf("Expected collection 'products', got '%s'", mappingErr.Collection)
+	}
+	for _, expected := range []string{"text field", "multiple select field", "relation field", `no field "missing"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %q, got '%s'", expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package dsl
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// errRulesSkipHooks is returned by the bulk operations of a rule-aware
+// builder when SkipHooks is set.
+var errRulesSkipHooks = errors.New("SkipHooks bypasses the collection API rules and can't be used with As")
+
+// As returns a copy of the builder that enforces the API rules of the
+// collection, with @request.auth resolved to auth, the way the PocketBase
+// record endpoints do. A nil auth evaluates the rules for a guest, and a
+// superuser auth bypasses them.
+//
+//   - List, ListPage, First, Count, the aggregations, cursors and bulk
+//     operations only see the records allowed by the ListRule; their
+//     filter and sort can't use hidden fields and only match the emails of
+//     the users with emailVisibility, like the list API, and the grouped
+//     and aggregated fields can't use hidden fields nor emails (Count's
+//     filter is plain SQL and isn't checked)
+//   - One checks the ViewRule and reports hidden records as not found
+//   - Create, Update and Delete (and the other writes) check the
+//     CreateRule, UpdateRule and DeleteRule and fail with ErrForbidden;
+//     @request.body holds the changed fields of the record
+//   - Expand and Preload only load the related records the ViewRule and
+//     ListRule of the related collection allow
+//
+// Operations on a collection whose rule is locked (superusers only) fail
+// with ErrForbidden. Query caching is skipped for rule-aware builders and
+// bulk operations can't use SkipHooks.
+//
+// Example:
+//
+//	// only the posts the auth record may see and edit
+//	posts := dsl.Collection(app, "posts").As(e.Auth)
+//	records, err := posts.List(*dsl.Query("published = true"))
+//	_, err = posts.Update(id, map[string]any{"title": "New title"})
+//	if errors.Is(err, dsl.ErrForbidden) {
+//	    return e.ForbiddenError("", err)
+//	}
+func (c *CollectionQueryBuilder) As(auth *core.Record) *CollectionQueryBuilder {
+	clone := *c
+	clone.requestInfo = &core.RequestInfo{Auth: auth, Context: core.RequestInfoContextDefault}
+	clone.err = nil
+	return &clone
+}
+
+// AsRequest returns a copy of the builder that enforces the API rules of
+// the collection for the request of e, like As, with @request.auth,
+// @request.query, @request.headers and @request.body taken from the
+// request.
+//
+// An error reading the request, e.g. an invalid JSON body, is returned by
+// the operations of the builder.
+//
+// Example:
+//
+//	se.Router.GET("/api/my/posts", func(e *core.RequestEvent) error {
+//	    records, err := dsl.Collection(e.App, "posts").AsRequest(e).List(*dsl.Query("").Sort("-created"))
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return e.JSON(http.StatusOK, records)
+//	})
+func (c *CollectionQueryBuilder) AsRequest(e *core.RequestEvent) *CollectionQueryBuilder {
+	clone := *c
+	info, err := e.RequestInfo()
+	if err != nil {
+		clone.err = fmt.Errorf("failed to read the request info: %w", err)
+		info = &core.RequestInfo{Auth: e.Auth, Context: core.RequestInfoContextDefault}
+	} else {
+		clone.err = nil
+	}
+	clone.requestInfo = info
+	return &clone
+}
+
+// As returns a copy of the repository that enforces the API rules of the
+// collection for auth. See CollectionQueryBuilder.As.
+func (r *Repo[T]) As(auth *core.Record) *Repo[T] {
+	return &Repo[T]{collection: r.collection.As(auth)}
+}
+
+// AsRequest returns a copy of the repository that enforces the API rules
+// of the collection for the request of e. See
+// CollectionQueryBuilder.AsRequest.
+func (r *Repo[T]) AsRequest(e *core.RequestEvent) *Repo[T] {
+	return &Repo[T]{collection: r.collection.AsRequest(e)}
+}
+
+// rules returns the request info the API rules are evaluated with, or nil
+// when the builder doesn't enforce them.
+func (c *CollectionQueryBuilder) rules() *core.RequestInfo {
+	if c.requestInfo == nil || c.requestInfo.HasSuperuserAuth() {
+		return nil
+	}
+	return c.requestInfo
+}
+
+// withoutRules returns a copy of the builder that doesn't enforce the API
+// rules, for writes whose rule was already checked.
+func (c *CollectionQueryBuilder) withoutRules() *CollectionQueryBuilder {
+	clone := *c
+	clone.requestInfo = nil
+	return &clone
+}
+
+// ruleExpr returns the condition of the rule of collection for action, or
+// nil for a public rule.
+func ruleExpr(resolver *core.RecordFieldResolver, collection *core.Collection, rule *string, action string) (dbx.Expression, error) {
+	if rule == nil {
+		return nil, lockedRuleError(collection, action)
+	}
+	if *rule == "" {
+		return nil, nil
+	}
+	expr, err := search.FilterData(*rule).BuildExpr(resolver)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s rule of collection %q: %w", action, collection.Name, err)
+	}
+	return expr, nil
+}
+
+// lockedRuleError returns the error of an action whose rule only allows
+// superusers.
+func lockedRuleError(collection *core.Collection, action string) error {
+	return fmt.Errorf("%w: only superusers can %s %s records", ErrForbidden, action, collection.Name)
+}
+
+// checkRule checks that rule allows action on record, with the changed
+// fields of record as @request.body. It is a no-op when the builder
+// doesn't enforce the API rules.
+func (c *CollectionQueryBuilder) checkRule(record *core.Record, rule *string, action string) error {
+	info := c.rules()
+	if info == nil {
+		return nil
+	}
+	if rule == nil {
+		return lockedRuleError(record.Collection(), action)
+	}
+	return c.checkRuleWith(record, c.ruleRequestInfo(record), rule, action)
+}
+
+// checkRuleWith checks that rule allows action on the stored record with
+// the given request info.
+func (c *CollectionQueryBuilder) checkRuleWith(record *core.Record, info *core.RequestInfo, rule *string, action string) error {
+	ok, err := c.app.CanAccessRecord(record, info, rule)
+	if err != nil {
+		return fmt.Errorf("failed to check the %s rule of collection %q: %w", action, record.Collection().Name, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: the %s rule of %s doesn't allow record %q", ErrForbidden, action, record.Collection().Name, record.Id)
+	}
+	return nil
+}
+
+// checkViewRule checks the ViewRule for record and reports records it
+// hides as not found, like the PocketBase view endpoint.
+func (c *CollectionQueryBuilder) checkViewRule(record *core.Record) error {
+	rule := record.Collection().ViewRule
+	err := c.checkRule(record, rule, "view")
+	if err != nil && rule != nil && errors.Is(err, ErrForbidden) {
+		return notFoundError(sql.ErrNoRows)
+	}
+	return err
+}
+
+// createWithRules saves the new record and checks the CreateRule against
+// it in a transaction (or savepoint), which is rolled back if the rule
+// doesn't allow the record.
+func (c *CollectionQueryBuilder) createWithRules(record *core.Record) error {
+	rule := record.Collection().CreateRule
+	if rule == nil {
+		return lockedRuleError(record.Collection(), "create")
+	}
+	info := c.ruleRequestInfo(record)
+	return Transaction(c.app, func(tx *Tx) error {
+		txc := c.withApp(tx.App())
+		if err := txc.withoutRules().save(record); err != nil {
+			return err
+		}
+		return txc.checkRuleWith(record, info, rule, "create")
+	})
+}
+
+// ruleRequestInfo returns the request info of the builder with the changed
+// fields of record as body, unless the request info already has a body.
+func (c *CollectionQueryBuilder) ruleRequestInfo(record *core.Record) *core.RequestInfo {
+	if len(c.requestInfo.Body) > 0 {
+		return c.requestInfo
+	}
+	info := *c.requestInfo
+	info.Body = recordChanges(record)
+	return &info
+}
+
+// recordChanges returns the fields of record that differ from the stored
+// values, or the non-empty fields of a new record.
+func recordChanges(record *core.Record) map[string]any {
+	changes := map[string]any{}
+	isNew := record.IsNew()
+	original := record.Original()
+	for _, field := range record.Collection().Fields {
+		name := field.GetName()
+		value := record.Get(name)
+		if (isNew && isEmptyValue(value)) || (!isNew && reflect.DeepEqual(value, original.Get(name))) {
+			continue
+		}
+		changes[name] = value
+	}
+	return changes
+}
+
+// isEmptyValue reports whether v is nil, a zero value or an empty slice.
+func isEmptyValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map {
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,324 @@
+package dsl
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// newRulesApp returns a test app with products owned by users, whose API rules let everybody see the active products
+// and the owners see and edit their own ones.
+func newRulesApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	app := newTestApp(t)
+	users := mustCollection(t, app, "users")
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(&core.RelationField{Name: "owner", CollectionId: users.Id, MaxSelect: 1})
+	products.ListRule = types.Pointer("status = 'active' || owner = @request.auth.id")
+	products.ViewRule = products.ListRule
+	products.CreateRule = types.Pointer("@request.auth.id != '' && owner = @request.auth.id")
+	products.UpdateRule = types.Pointer("owner = @request.auth.id && @request.body.owner:isset = false")
+	products.DeleteRule = nil
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	return app
+}
+
+// mustUser creates a user with the given email or fails the test.
+func mustUser(t *testing.T, app core.App, email string) *core.Record {
+	t.Helper()
+	return mustCreate(t, app, "users", map[string]any{"email": email, "password": "1234567890"})
+}
+
+func TestAsQueries(t *testing.T) {
+	app := newRulesApp(t)
+	alice := mustUser(t, app, "alice@example.com")
+	bob := mustUser(t, app, "bob@example.com")
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "owner": bob.Id, "price": 10})
+	draft := mustCreate(t, app, "products", map[string]any{"name": "draft", "status": "draft", "owner": alice.Id, "price": 20})
+	mustCreate(t, app, "products", map[string]any{"name": "secret", "status": "draft", "owner": bob.Id, "price": 30})
+
+	list := func(c *CollectionQueryBuilder) string {
+		records, err := c.List(*Query("").Sort("name"))
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		return recordNames(records)
+	}
+	if names := list(Collection(app, "products").As(alice)); names != "draft,hammer" {
+		t.Errorf("Expected the active and own products, got %s", names)
+	}
+	if names := list(Collection(app, "products").As(nil)); names != "hammer" {
+		t.Errorf("Expected the active products for a guest, got %s", names)
+	}
+	if names := list(Collection(app, "products")); names != "draft,hammer,secret" {
+		t.Errorf("Expected all products without As, got %s", names)
+	}
+
+	superuser := core.NewRecord(mustCollection(t, app, core.CollectionNameSuperusers))
+	if names := list(Collection(app, "products").As(superuser)); names != "draft,hammer,secret" {
+		t.Errorf("Expected all products for a superuser, got %s", names)
+	}
+
+	c := Collection(app, "products").As(alice)
+	if count, err := c.Count(""); err != nil || count != 2 {
+		t.Errorf("Expected a count of 2, got %d (%v)", count, err)
+	}
+	if total, err := c.Sum("price", *Query("")); err != nil || total != 30 {
+		t.Errorf("Expected the sum of the visible prices 30, got %v (%v)", total, err)
+	}
+	if _, err := c.First(*Query("name = 'secret'")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a hidden record, got %v", err)
+	}
+	if records, err := c.List(*Query("owner = @request.auth.id")); err != nil || recordNames(records) != "draft" {
+		t.Errorf("Expected @request.auth in the query filter, got %s (%v)", recordNames(records), err)
+	}
+
+	// One checks the view rule and hides the existence of records
+	if _, err := c.One(draft.Id); err != nil {
+		t.Errorf("Expected the own draft to be visible, got %v", err)
+	}
+	if _, err := c.One(hammer.Id); err != nil {
+		t.Errorf("Expected the active product to be visible, got %v", err)
+	}
+	secret, _ := Collection(app, "products").First(*Query("name = 'secret'"))
+	if _, err := c.One(secret.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a hidden record, got %v", err)
+	}
+
+	// locked rules are reserved to superusers
+	if _, err := Collection(app, "categories").As(alice).List(*Query("")); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a locked list rule, got %v", err)
+	}
+	if _, err := Collection(app, "categories").As(alice).Count(""); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a locked list rule, got %v", err)
+	}
+}
+
+func TestAsHiddenFields(t *testing.T) {
+	app := newRulesApp(t)
+	products := mustCollection(t, app, "products")
+	products.ListRule = types.Pointer("status = 'active' || owner.email = @request.auth.email")
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	alice := mustCreate(t, app, "users", map[string]any{"email": "alice@example.com", "password": "1234567890", "emailVisibility": true})
+	bob := mustUser(t, app, "bob@example.com")
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "owner": alice.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "status": "active", "owner": bob.Id})
+	mustCreate(t, app, "products", map[string]any{"name": "draft", "status": "draft", "owner": bob.Id})
+
+	// the filter and sort can't reach the hidden fields of the owner
+	guest := Collection(app, "products").As(nil)
+	for _, query := range []*QueryBuilder{
+		Query("owner.tokenKey != '' && owner.password ~ '$2a'"),
+		Query("").Sort("owner.tokenKey"),
+	} {
+		if records, err := guest.List(*query); err == nil {
+			t.Errorf("Expected the hidden fields to be rejected, got %s", recordNames(records))
+		}
+		if _, err := guest.ListPage(*query); err == nil {
+			t.Errorf("Expected the hidden fields to be rejected by ListPage")
+		}
+	}
+
+	// emails only match when visible
+	records, err := guest.List(*Query("owner.email ~ 'example.com'"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "hammer" {
+		t.Errorf("Expected only the product of the user with a visible email, got %s", names)
+	}
+
+	// the rule itself can use them
+	records, err = Collection(app, "products").As(bob).List(*Query("").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "draft,hammer,saw" {
+		t.Errorf("Expected the rule to match the hidden email, got %s", names)
+	}
+
+	// Count applies the raw filter apart from the joins of the rule
+	saw, _ := Collection(app, "products").First(*Query("name = 'saw'"))
+	if count, err := Collection(app, "products").As(bob).Count("id = {:id}", dbx.Params{"id": saw.Id}); err != nil || count != 1 {
+		t.Errorf("Expected a count of 1, got %d (%v)", count, err)
+	}
+
+	// the aggregations can't group or aggregate by hidden fields either
+	if rows, err := guest.GroupBy("owner.email").Aggregate(CountAll()).Rows(*Query("")); err == nil {
+		t.Errorf("Expected the hidden group field to be rejected, got %v", rows)
+	}
+	if rows, err := guest.Aggregate(MaxOf("owner.tokenKey")).Rows(*Query("")); err == nil {
+		t.Errorf("Expected the hidden aggregate field to be rejected, got %v", rows)
+	}
+	if rows, err := Collection(app, "products").GroupBy("owner.email").Aggregate(CountAll()).Rows(*Query("")); err != nil || len(rows) != 2 {
+		t.Errorf("Expected the owner emails without the rules, got %v (%v)", rows, err)
+	}
+
+	// and superusers can filter by them
+	superuser := core.NewRecord(mustCollection(t, app, core.CollectionNameSuperusers))
+	records, err = Collection(app, "products").As(superuser).List(*Query("owner.email = 'bob@example.com' && owner.tokenKey != ''").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "draft,saw" {
+		t.Errorf("Expected the superuser to filter by hidden fields, got %s", names)
+	}
+}
+
+func TestAsExpand(t *testing.T) {
+	app := newRulesApp(t)
+	alice := mustUser(t, app, "alice@example.com")
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "category": tools.Id})
+
+	c := Collection(app, "products").As(alice)
+	record, err := c.First(*Query("").Expand("category"))
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if record.ExpandedOne("category") != nil {
+		t.Error("Expected the locked category not to be expanded")
+	}
+	if _, err := c.First(*Query("").Preload("category")); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for preloading a locked relation, got %v", err)
+	}
+
+	categories := mustCollection(t, app, "categories")
+	categories.ListRule = types.Pointer("")
+	categories.ViewRule = types.Pointer("")
+	if err := app.Save(categories); err != nil {
+		t.Fatalf("Failed to update categories collection: %v", err)
+	}
+	record, err = c.First(*Query("").Expand("category"))
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if record.ExpandedOne("category") == nil {
+		t.Error("Expected the public category to be expanded")
+	}
+	record, err = c.First(*Query("").Preload("category"))
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if record.ExpandedOne("category") == nil {
+		t.Error("Expected the public category to be preloaded")
+	}
+}
+
+func TestAsWrites(t *testing.T) {
+	app := newRulesApp(t)
+	alice := mustUser(t, app, "alice@example.com")
+	bob := mustUser(t, app, "bob@example.com")
+	c := Collection(app, "products").As(alice)
+
+	// create rule
+	own, err := c.Create(map[string]any{"name": "saw", "status": "active", "owner": alice.Id})
+	if err != nil {
+		t.Fatalf("Failed to create own record: %v", err)
+	}
+	if _, err := c.Create(map[string]any{"name": "forged", "owner": bob.Id}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for creating a record of another user, got %v", err)
+	}
+	if _, err := Collection(app, "products").As(nil).Create(map[string]any{"name": "anonymous"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a guest, got %v", err)
+	}
+	if count := mustCount(t, app, "products"); count != 1 {
+		t.Errorf("Expected the denied records to be rolled back, got %d records", count)
+	}
+
+	// denied creates inside a transaction only roll back their savepoint
+	err = Transaction(app, func(tx *Tx) error {
+		if _, err := tx.Collection("products").As(alice).Create(map[string]any{"name": "forged", "owner": bob.Id}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected ErrForbidden inside a transaction, got %v", err)
+		}
+		_, err := tx.Collection("products").As(alice).Create(map[string]any{"name": "drill", "owner": alice.Id})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to run transaction: %v", err)
+	}
+	if count := mustCount(t, app, "products"); count != 2 {
+		t.Errorf("Expected only the allowed record to be committed, got %d records", count)
+	}
+
+	// update rule, with the changed fields as @request.body
+	if _, err := c.Update(own.Id, map[string]any{"price": 12}); err != nil {
+		t.Errorf("Failed to update own record: %v", err)
+	}
+	if _, err := c.Update(own.Id, map[string]any{"owner": bob.Id}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for changing the owner, got %v", err)
+	}
+	if _, err := Collection(app, "products").As(bob).Update(own.Id, map[string]any{"price": 1}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for updating a visible record of another user, got %v", err)
+	}
+	if _, err := c.UpdateWhere(*Query(""), map[string]any{"price": 5}, BulkOptions{SkipHooks: true}); err == nil {
+		t.Error("Expected error for SkipHooks with As")
+	}
+
+	// locked delete rule
+	if err := c.Delete(own.Id); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a locked delete rule, got %v", err)
+	}
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(&core.DateField{Name: "deleted"})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	if err := EnableSoftDelete(app, "products"); err != nil {
+		t.Fatalf("Failed to enable soft delete: %v", err)
+	}
+	if err := c.Delete(own.Id); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a locked soft delete, got %v", err)
+	}
+
+	products = mustCollection(t, app, "products")
+	products.DeleteRule = types.Pointer("owner = @request.auth.id")
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	if err := c.Delete(own.Id); err != nil {
+		t.Errorf("Failed to delete own record: %v", err)
+	}
+	if _, err := Collection(app, "products").WithDeleted().One(own.Id); err != nil {
+		t.Errorf("Expected the record to be soft deleted, got %v", err)
+	}
+}
+
+func TestAsRequest(t *testing.T) {
+	app := newRulesApp(t)
+	alice := mustUser(t, app, "alice@example.com")
+	mustCreate(t, app, "products", map[string]any{"name": "draft", "status": "draft", "owner": alice.Id})
+
+	products := mustCollection(t, app, "products")
+	products.ListRule = types.Pointer("owner = @request.auth.id && @request.query.scope = 'mine'")
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+
+	e := &core.RequestEvent{App: app}
+	e.Request = httptest.NewRequest("GET", "/api/products?scope=mine", nil)
+	e.Response = httptest.NewRecorder()
+	e.Auth = alice
+	if count, err := Collection(app, "products").AsRequest(e).Count(""); err != nil || count != 1 {
+		t.Errorf("Expected the request data in the rules, got %d (%v)", count, err)
+	}
+
+	e = &core.RequestEvent{App: app}
+	e.Request = httptest.NewRequest("POST", "/api/products", strings.NewReader("{"))
+	e.Request.Header.Set("Content-Type", "application/json")
+	e.Response = httptest.NewRecorder()
+	if _, err := Collection(app, "products").AsRequest(e).List(*Query("")); err == nil {
+		t.Error("Expected the error of the invalid request body")
+	}
+}
@@ -0,0 +1,570 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// indexNameRegex matches the characters dropped from generated index names.
+var indexNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// FieldOption configures a field added by a CollectionSchema, e.g.
+// Required or Max(100). An option returns an error when it doesn't apply
+// to the field type.
+type FieldOption func(field core.Field) error
+
+var (
+	// Required makes the field value required (non-empty).
+	Required FieldOption = func(field core.Field) error {
+		return setFieldSetting(field, "Required", true)
+	}
+
+	// Hidden hides the field from the API responses.
+	Hidden FieldOption = func(field core.Field) error {
+		field.SetHidden(true)
+		return nil
+	}
+
+	// Presentable marks the field as the one shown for the record in the
+	// dashboard relation pickers.
+	Presentable FieldOption = func(field core.Field) error {
+		return setFieldSetting(field, "Presentable", true)
+	}
+
+	// OnlyInt only allows integers in a number field.
+	OnlyInt FieldOption = func(field core.Field) error {
+		return setFieldSetting(field, "OnlyInt", true)
+	}
+
+	// CascadeDelete deletes the records of a relation field when the
+	// related record is deleted.
+	CascadeDelete FieldOption = func(field core.Field) error {
+		return setFieldSetting(field, "CascadeDelete", true)
+	}
+)
+
+// Min sets the minimum of a field: the length of a text field, the value
+// of a number field or the number of records of a relation field.
+func Min(n float64) FieldOption {
+	return func(field core.Field) error {
+		switch f := field.(type) {
+		case *core.TextField:
+			f.Min = int(n)
+		case *core.NumberField:
+			f.Min = &n
+		case *core.RelationField:
+			f.MinSelect = int(n)
+		default:
+			return optionError(field, "Min")
+		}
+		return nil
+	}
+}
+
+// Max sets the maximum of a field: the length of a text field, the value
+// of a number field or the number of values of a select, relation or file
+// field (more than 1 makes the field multiple).
+func Max(n float64) FieldOption {
+	return func(field core.Field) error {
+		switch f := field.(type) {
+		case *core.TextField:
+			f.Max = int(n)
+		case *core.NumberField:
+			f.Max = &n
+		case *core.SelectField:
+			f.MaxSelect = int(n)
+		case *core.RelationField:
+			f.MaxSelect = int(n)
+		case *core.FileField:
+			f.MaxSelect = int(n)
+		default:
+			return optionError(field, "Max")
+		}
+		return nil
+	}
+}
+
+// MaxSize sets the maximum size in bytes of an editor, JSON or file
+// field value.
+func MaxSize(bytes int64) FieldOption {
+	return func(field core.Field) error {
+		return setFieldSetting(field, "MaxSize", bytes)
+	}
+}
+
+// Pattern sets the regular expression the values of a text field must
+// match.
+func Pattern(regex string) FieldOption {
+	return func(field core.Field) error {
+		if _, err := regexp.Compile(regex); err != nil {
+			return fmt.Errorf("invalid pattern of field %q: %w", field.GetName(), err)
+		}
+		return setFieldSetting(field, "Pattern", regex)
+	}
+}
+
+// setFieldSetting sets the named setting of the field struct.
+func setFieldSetting(field core.Field, name string, value any) error {
+	v := reflect.ValueOf(field).Elem().FieldByName(name)
+	if !v.IsValid() || !v.CanSet() || !reflect.TypeOf(value).ConvertibleTo(v.Type()) {
+		return optionError(field, name)
+	}
+	v.Set(reflect.ValueOf(value).Convert(v.Type()))
+	return nil
+}
+
+// optionError returns the error of an option that doesn't apply to field.
+func optionError(field core.Field, option string) error {
+	return fmt.Errorf("option %s doesn't apply to the %s field %q", option, field.Type(), field.GetName())
+}
+
+// CollectionRules holds the API rules of a collection. A nil rule only
+// allows superusers and an empty rule allows everyone, see Rule.
+type CollectionRules struct {
+	List   *string // Rule of the list and search requests
+	View   *string // Rule of the view requests
+	Create *string // Rule of the create requests
+	Update *string // Rule of the update requests
+	Delete *string // Rule of the delete requests
+	Manage *string // Rule granting full management of auth records (auth collections only)
+}
+
+// Rule returns a pointer to the filter expression of an API rule; Rule("")
+// allows everyone.
+func Rule(expr string) *string {
+	return &expr
+}
+
+// schemaOp is a change of a CollectionSchema and its reverse.
+type schemaOp struct {
+	apply   func(app core.App, collection *core.Collection) error
+	reverse func(app core.App, collection *core.Collection) error
+}
+
+// CollectionSchema is a fluent builder of a collection schema, for
+// migrations. It is created with DefineCollection, DefineAuthCollection or
+// AlterCollection.
+//
+// Errors, e.g. an option that doesn't apply to a field, are deferred and
+// returned by Build, Up and Down.
+type CollectionSchema struct {
+	name           string     // The collection name
+	collectionType string     // Type of a defined collection; empty in alter mode
+	ops            []schemaOp // Changes in call order
+	rules          *CollectionRules
+	err            error // First error of the builder calls
+}
+
+// DefineCollection starts the definition of a new base collection.
+//
+// Up creates the collection and Down deletes it, so both sides of a
+// migration come from a single definition.
+//
+// Example:
+//
+//	func init() {
+//	    m.Register(dsl.DefineCollection("products").
+//	        Text("name", dsl.Required, dsl.Max(100)).
+//	        Number("price", dsl.Required, dsl.Min(0)).
+//	        Select("status", []string{"draft", "active"}).
+//	        Relation("category", "categories", dsl.CascadeDelete).
+//	        Timestamps().
+//	        Index("name").
+//	        Rules(dsl.CollectionRules{List: dsl.Rule(""), View: dsl.Rule("")}).
+//	        Migration())
+//	}
+func DefineCollection(name string) *CollectionSchema {
+	return &CollectionSchema{name: name, collectionType: core.CollectionTypeBase}
+}
+
+// DefineAuthCollection starts the definition of a new auth collection,
+// which also gets the system auth fields (email, password, ...).
+// See DefineCollection.
+func DefineAuthCollection(name string) *CollectionSchema {
+	return &CollectionSchema{name: name, collectionType: core.CollectionTypeAuth}
+}
+
+// AlterCollection starts a change of an existing collection.
+//
+// Up adds, renames and drops fields and adds indexes in call order; Down
+// applies the reverse operations in the opposite order. Renamed fields
+// keep their data. DropField needs the definition of the dropped field so
+// that Down can add it back (without the dropped values).
+//
+// Example:
+//
+//	func init() {
+//	    m.Register(dsl.AlterCollection("products").
+//	        Text("sku", dsl.Max(20)).
+//	        UniqueIndex("sku").
+//	        RenameField("description", "summary").
+//	        DropField(&core.TextField{Name: "legacy_code", Max: 10}).
+//	        Migration())
+//	}
+func AlterCollection(name string) *CollectionSchema {
+	return &CollectionSchema{name: name}
+}
+
+// isAlter reports whether the schema changes an existing collection.
+func (s *CollectionSchema) isAlter() bool {
+	return s.collectionType == ""
+}
+
+// fail records the first error of the builder.
+func (s *CollectionSchema) fail(err error) *CollectionSchema {
+	if s.err == nil {
+		s.err = err
+	}
+	return s
+}
+
+// Text adds a text field.
+func (s *CollectionSchema) Text(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.TextField{Name: name} }, opts)
+}
+
+// Email adds an email field.
+func (s *CollectionSchema) Email(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.EmailField{Name: name} }, opts)
+}
+
+// URL adds a URL field.
+func (s *CollectionSchema) URL(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.URLField{Name: name} }, opts)
+}
+
+// Editor adds a rich text (HTML) field.
+func (s *CollectionSchema) Editor(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.EditorField{Name: name} }, opts)
+}
+
+// Number adds a number field.
+func (s *CollectionSchema) Number(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.NumberField{Name: name} }, opts)
+}
+
+// Bool adds a bool field.
+func (s *CollectionSchema) Bool(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.BoolField{Name: name} }, opts)
+}
+
+// Date adds a date field.
+func (s *CollectionSchema) Date(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.DateField{Name: name} }, opts)
+}
+
+// JSON adds a JSON field.
+func (s *CollectionSchema) JSON(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.JSONField{Name: name} }, opts)
+}
+
+// GeoPoint adds a geographic point field.
+func (s *CollectionSchema) GeoPoint(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.GeoPointField{Name: name} }, opts)
+}
+
+// Select adds a select field with the given values. It holds a single
+// value unless Max allows more.
+func (s *CollectionSchema) Select(name string, values []string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field {
+		return &core.SelectField{Name: name, Values: values, MaxSelect: 1}
+	}, opts)
+}
+
+// Relation adds a relation field to the collection with the given name
+// (or id). It holds a single record unless Max allows more.
+func (s *CollectionSchema) Relation(name string, collection string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field {
+		return &core.RelationField{Name: name, CollectionId: collection, MaxSelect: 1}
+	}, opts)
+}
+
+// File adds a file field. It holds a single file unless Max allows more.
+func (s *CollectionSchema) File(name string, opts ...FieldOption) *CollectionSchema {
+	return s.addField(func() core.Field { return &core.FileField{Name: name, MaxSelect: 1} }, opts)
+}
+
+// Timestamps adds the "created" and "updated" autodate fields.
+func (s *CollectionSchema) Timestamps() *CollectionSchema {
+	s.addField(func() core.Field { return &core.AutodateField{Name: "created", OnCreate: true} }, nil)
+	return s.addField(func() core.Field { return &core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true} }, nil)
+}
+
+// Field adds a field defined with the core field types, for settings the
+// typed methods don't cover.
+func (s *CollectionSchema) Field(field core.Field) *CollectionSchema {
+	return s.addField(func() core.Field { return field }, nil)
+}
+
+// addField adds the field returned by newField, configured with opts. In
+// alter mode the reverse removes the field.
+func (s *CollectionSchema) addField(newField func() core.Field, opts []FieldOption) *CollectionSchema {
+	// check the options once at build time, so that errors are reported early
+	field := newField()
+	for _, opt := range opts {
+		if err := opt(field); err != nil {
+			return s.fail(err)
+		}
+	}
+
+	name := field.GetName()
+	s.ops = append(s.ops, schemaOp{
+		apply: func(app core.App, collection *core.Collection) error {
+			if collection.Fields.GetByName(name) != nil {
+				return fmt.Errorf("collection %q already has a field %q", collection.Name, name)
+			}
+			field := newField()
+			for _, opt := range opts {
+				if err := opt(field); err != nil {
+					return err
+				}
+			}
+			return addSchemaField(app, collection, field)
+		},
+		reverse: func(app core.App, collection *core.Collection) error {
+			collection.Fields.RemoveByName(name)
+			return nil
+		},
+	})
+	return s
+}
+
+// addSchemaField adds field to collection, resolving the collection name
+// of a relation field to its id.
+func addSchemaField(app core.App, collection *core.Collection, field core.Field) error {
+	if relation, ok := field.(*core.RelationField); ok {
+		if relation.CollectionId == collection.Name || relation.CollectionId == collection.Id {
+			relation.CollectionId = collection.Id // self relation
+		} else {
+			related, err := app.FindCachedCollectionByNameOrId(relation.CollectionId)
+			if err != nil {
+				return fmt.Errorf("relation field %q: %w", relation.Name, collectionError(err))
+			}
+			relation.CollectionId = related.Id
+		}
+	}
+	collection.Fields.Add(field)
+	return nil
+}
+
+// Index adds a non-unique index on the columns, named
+// idx_<collection>_<columns>. Columns may include a sort order, e.g.
+// "created DESC".
+func (s *CollectionSchema) Index(columns ...string) *CollectionSchema {
+	return s.addIndex(false, columns)
+}
+
+// UniqueIndex adds a unique index on the columns, named
+// idx_<collection>_<columns>.
+func (s *CollectionSchema) UniqueIndex(columns ...string) *CollectionSchema {
+	return s.addIndex(true, columns)
+}
+
+// addIndex adds an index; in alter mode the reverse removes it.
+func (s *CollectionSchema) addIndex(unique bool, columns []string) *CollectionSchema {
+	if len(columns) == 0 {
+		return s.fail(errors.New("an index needs at least one column"))
+	}
+	name := "idx_" + s.name + "_" + strings.Trim(indexNameRegex.ReplaceAllString(strings.Join(columns, "_"), "_"), "_")
+	s.ops = append(s.ops, schemaOp{
+		apply: func(app core.App, collection *core.Collection) error {
+			collection.AddIndex(name, unique, strings.Join(columns, ", "), "")
+			return nil
+		},
+		reverse: func(app core.App, collection *core.Collection) error {
+			collection.RemoveIndex(name)
+			return nil
+		},
+	})
+	return s
+}
+
+// Rules sets the API rules of a defined collection. Rules can't be
+// changed in alter mode, since Down couldn't restore the previous ones.
+func (s *CollectionSchema) Rules(rules CollectionRules) *CollectionSchema {
+	if s.isAlter() {
+		return s.fail(fmt.Errorf("the rules of %q can't be changed by AlterCollection", s.name))
+	}
+	s.rules = &rules
+	return s
+}
+
+// RenameField renames a field of an altered collection, keeping its data.
+func (s *CollectionSchema) RenameField(oldName, newName string) *CollectionSchema {
+	if !s.isAlter() {
+		return s.fail(fmt.Errorf("RenameField requires AlterCollection, %q is being defined", s.name))
+	}
+	rename := func(from, to string) func(app core.App, collection *core.Collection) error {
+		return func(app core.App, collection *core.Collection) error {
+			field := collection.Fields.GetByName(from)
+			if field == nil {
+				return fmt.Errorf("collection %q has no field %q", collection.Name, from)
+			}
+			field.SetName(to)
+			return nil
+		}
+	}
+	s.ops = append(s.ops, schemaOp{apply: rename(oldName, newName), reverse: rename(newName, oldName)})
+	return s
+}
+
+// DropField drops a field of an altered collection and its data.
+// definition is the field as it is defined before the drop; Down adds it
+// back from it.
+func (s *CollectionSchema) DropField(definition core.Field) *CollectionSchema {
+	if !s.isAlter() {
+		return s.fail(fmt.Errorf("DropField requires AlterCollection, %q is being defined", s.name))
+	}
+	name := definition.GetName()
+	s.ops = append(s.ops, schemaOp{
+		apply: func(app core.App, collection *core.Collection) error {
+			if collection.Fields.GetByName(name) == nil {
+				return fmt.Errorf("collection %q has no field %q", collection.Name, name)
+			}
+			collection.Fields.RemoveByName(name)
+			return nil
+		},
+		reverse: func(app core.App, collection *core.Collection) error {
+			field, err := cloneField(definition)
+			if err != nil {
+				return err
+			}
+			return addSchemaField(app, collection, field)
+		},
+	})
+	return s
+}
+
+// cloneField returns a copy of field with a new id, so that a definition
+// can be applied more than once.
+func cloneField(field core.Field) (core.Field, error) {
+	list := core.NewFieldsList(field)
+	clone, err := list.Clone()
+	if err != nil {
+		return nil, err
+	}
+	result := clone[0]
+	result.SetId("")
+	return result, nil
+}
+
+// Build returns the collection of the schema without saving it: a new
+// collection for DefineCollection, or the existing collection with the
+// changes applied for AlterCollection.
+func (s *CollectionSchema) Build(app core.App) (*core.Collection, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.isAlter() {
+		collection, err := app.FindCollectionByNameOrId(s.name)
+		if err != nil {
+			return nil, collectionError(err)
+		}
+		for _, op := range s.ops {
+			if err := op.apply(app, collection); err != nil {
+				return nil, err
+			}
+		}
+		return collection, nil
+	}
+
+	collection := core.NewCollection(s.collectionType, s.name)
+	for _, op := range s.ops {
+		if err := op.apply(app, collection); err != nil {
+			return nil, err
+		}
+	}
+	if r := s.rules; r != nil {
+		collection.ListRule, collection.ViewRule = r.List, r.View
+		collection.CreateRule, collection.UpdateRule, collection.DeleteRule = r.Create, r.Update, r.Delete
+		if r.Manage != nil {
+			if !collection.IsAuth() {
+				return nil, fmt.Errorf("the manage rule of %q requires an auth collection", s.name)
+			}
+			collection.ManageRule = r.Manage
+		}
+	}
+	return collection, nil
+}
+
+// Up builds the collection and saves it: it creates a defined collection
+// or applies the changes of an altered one.
+func (s *CollectionSchema) Up(app core.App) error {
+	collection, err := s.Build(app)
+	if err != nil {
+		return err
+	}
+	if s.isAlter() {
+		return saveCollection(app, collection)
+	}
+
+	// a relation to the collection itself is only valid once the collection
+	// exists, so it's added by a second save
+	var selfRelations []core.Field
+	for _, field := range collection.Fields {
+		if relation, ok := field.(*core.RelationField); ok && relation.CollectionId == collection.Id {
+			selfRelations = append(selfRelations, relation)
+		}
+	}
+	if len(selfRelations) == 0 {
+		return saveCollection(app, collection)
+	}
+	return app.RunInTransaction(func(txApp core.App) error {
+		fields := collection.Fields
+		collection.Fields = core.NewFieldsList()
+		for _, field := range fields {
+			if !slices.Contains(selfRelations, field) {
+				collection.Fields.Add(field)
+			}
+		}
+		if err := saveCollection(txApp, collection); err != nil {
+			return err
+		}
+		collection.Fields = fields
+		return saveCollection(txApp, collection)
+	})
+}
+
+// Down reverts Up: it deletes a defined collection, or applies the reverse
+// changes of an altered one in the opposite order.
+func (s *CollectionSchema) Down(app core.App) error {
+	if s.err != nil {
+		return s.err
+	}
+	collection, err := app.FindCollectionByNameOrId(s.name)
+	if err != nil {
+		return collectionError(err)
+	}
+	if !s.isAlter() {
+		return app.Delete(collection)
+	}
+	for i := len(s.ops) - 1; i >= 0; i-- {
+		if err := s.ops[i].reverse(app, collection); err != nil {
+			return err
+		}
+	}
+	return saveCollection(app, collection)
+}
+
+// Migration returns Up and Down, to register the schema as a migration.
+//
+// Example:
+//
+//	m.Register(dsl.DefineCollection("tags").Text("name", dsl.Required).Migration())
+func (s *CollectionSchema) Migration() (up, down func(app core.App) error) {
+	return s.Up, s.Down
+}
+
+// saveCollection saves collection, translating validation errors.
+func saveCollection(app core.App, collection *core.Collection) error {
+	if err := app.Save(collection); err != nil {
+		return saveError(collection.Name, err)
+	}
+	return nil
+}
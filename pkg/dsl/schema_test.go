@@ -0,0 +1,158 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestDefineCollection(t *testing.T) {
+	app := newTestApp(t)
+
+	schema := DefineCollection("orders").
+		Text("code", Required, Max(20), Pattern(`^[A-Z0-9-]+$`)).
+		Number("total", Required, Min(0), OnlyInt).
+		Select("status", []string{"open", "paid"}).
+		Relation("product", "products", CascadeDelete).
+		Relation("parent", "orders").
+		Bool("gift").
+		Timestamps().
+		UniqueIndex("code").
+		Index("status", "created DESC").
+		Rules(CollectionRules{List: Rule(""), View: Rule("@request.auth.id != ''")})
+	if err := schema.Up(app); err != nil {
+		t.Fatalf("Failed to create the collection: %v", err)
+	}
+
+	orders, err := app.FindCollectionByNameOrId("orders")
+	if err != nil {
+		t.Fatalf("Failed to find the created collection: %v", err)
+	}
+	code, ok := orders.Fields.GetByName("code").(*core.TextField)
+	if !ok || !code.Required || code.Max != 20 || code.Pattern == "" {
+		t.Errorf("Expected a required text field with max and pattern, got %+v", orders.Fields.GetByName("code"))
+	}
+	total, ok := orders.Fields.GetByName("total").(*core.NumberField)
+	if !ok || total.Min == nil || *total.Min != 0 || total.Max != nil || !total.OnlyInt {
+		t.Errorf("Expected an integer number field with min 0, got %+v", orders.Fields.GetByName("total"))
+	}
+	if status, ok := orders.Fields.GetByName("status").(*core.SelectField); !ok || status.MaxSelect != 1 || len(status.Values) != 2 {
+		t.Errorf("Expected a single select field, got %+v", orders.Fields.GetByName("status"))
+	}
+	products, _ := app.FindCollectionByNameOrId("products")
+	if product, ok := orders.Fields.GetByName("product").(*core.RelationField); !ok || product.CollectionId != products.Id || !product.CascadeDelete {
+		t.Errorf("Expected a relation to products, got %+v", orders.Fields.GetByName("product"))
+	}
+	if parent, ok := orders.Fields.GetByName("parent").(*core.RelationField); !ok || parent.CollectionId != orders.Id {
+		t.Errorf("Expected a self relation, got %+v", orders.Fields.GetByName("parent"))
+	}
+	if orders.Fields.GetByName("created") == nil || orders.Fields.GetByName("updated") == nil {
+		t.Errorf("Expected the created and updated fields")
+	}
+	if index := orders.GetIndex("idx_orders_code"); !strings.Contains(index, "UNIQUE") {
+		t.Errorf("Expected a unique index on code, got %q", index)
+	}
+	if index := orders.GetIndex("idx_orders_status_created_DESC"); !strings.Contains(index, "(status, created DESC)") {
+		t.Errorf("Expected an index on status and created, got %q", index)
+	}
+	if orders.ListRule == nil || *orders.ListRule != "" || orders.ViewRule == nil || orders.CreateRule != nil {
+		t.Errorf("Expected the public list rule, the view rule and superuser only create, got %v %v %v", orders.ListRule, orders.ViewRule, orders.CreateRule)
+	}
+
+	mustCreate(t, app, "orders", map[string]any{"code": "A-1", "total": 10})
+	if _, err := Collection(app, "orders").Create(map[string]any{"code": "a 1", "total": 10}); err == nil {
+		t.Errorf("Expected the pattern to reject the code")
+	}
+
+	if err := schema.Down(app); err != nil {
+		t.Fatalf("Failed to revert the collection: %v", err)
+	}
+	if _, err := app.FindCollectionByNameOrId("orders"); err == nil {
+		t.Errorf("Expected the collection to be deleted")
+	}
+}
+
+func TestAlterCollection(t *testing.T) {
+	app := newTestApp(t)
+	keyboard := mustCreate(t, app, "products", map[string]any{"name": "keyboard", "price": 10, "status": "active"})
+
+	up, down := AlterCollection("products").
+		Text("sku", Max(20)).
+		UniqueIndex("sku").
+		RenameField("name", "title").
+		DropField(&core.SelectField{Name: "status", Values: []string{"draft", "active", "archived"}, MaxSelect: 1}).
+		Migration()
+	if err := up(app); err != nil {
+		t.Fatalf("Failed to alter the collection: %v", err)
+	}
+
+	products, _ := app.FindCollectionByNameOrId("products")
+	if products.Fields.GetByName("sku") == nil || products.Fields.GetByName("status") != nil || products.Fields.GetByName("name") != nil {
+		t.Errorf("Expected sku added, status dropped and name renamed, got %v", products.Fields.FieldNames())
+	}
+	if products.GetIndex("idx_products_sku") == "" {
+		t.Errorf("Expected the sku index")
+	}
+	record, err := Collection(app, "products").One(keyboard.Id)
+	if err != nil {
+		t.Fatalf("Failed to fetch the record: %v", err)
+	}
+	if record.GetString("title") != "keyboard" {
+		t.Errorf("Expected the renamed field to keep its data, got %v", record.FieldsData())
+	}
+
+	if err := down(app); err != nil {
+		t.Fatalf("Failed to revert the changes: %v", err)
+	}
+	products, _ = app.FindCollectionByNameOrId("products")
+	if products.Fields.GetByName("sku") != nil || products.Fields.GetByName("title") != nil || products.GetIndex("idx_products_sku") != "" {
+		t.Errorf("Expected sku and its index removed and title renamed back, got %v", products.Fields.FieldNames())
+	}
+	if status, ok := products.Fields.GetByName("status").(*core.SelectField); !ok || len(status.Values) != 3 {
+		t.Errorf("Expected the dropped status field back, got %+v", products.Fields.GetByName("status"))
+	}
+	record, _ = Collection(app, "products").One(keyboard.Id)
+	if record.GetString("name") != "keyboard" {
+		t.Errorf("Expected the name back, got %v", record.FieldsData())
+	}
+
+	// the migration can be applied again
+	if err := up(app); err != nil {
+		t.Fatalf("Failed to alter the collection again: %v", err)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		schema *CollectionSchema
+		errMsg string
+	}{
+		{"option of another field type", DefineCollection("a").Bool("flag", Max(3)), "option Max doesn't apply"},
+		{"invalid pattern", DefineCollection("a").Text("code", Pattern("[")), "invalid pattern"},
+		{"drop in define mode", DefineCollection("a").DropField(&core.TextField{Name: "x"}), "requires AlterCollection"},
+		{"rules in alter mode", AlterCollection("products").Rules(CollectionRules{}), "can't be changed"},
+		{"manage rule of a base collection", DefineCollection("a").Rules(CollectionRules{Manage: Rule("")}), "requires an auth collection"},
+		{"unknown relation collection", DefineCollection("a").Relation("x", "missing"), "relation field"},
+		{"existing field", AlterCollection("products").Text("name"), "already has a field"},
+		{"unknown renamed field", AlterCollection("products").RenameField("missing", "x"), "has no field"},
+		{"unknown collection", AlterCollection("missing").Text("x"), "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Up(app)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	// a failed alter doesn't change the collection
+	products, _ := app.FindCollectionByNameOrId("products")
+	if products.Fields.GetByName("x") != nil {
+		t.Errorf("Expected the collection to be unchanged")
+	}
+}
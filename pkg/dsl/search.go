@@ -0,0 +1,480 @@
+package dsl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/spf13/cast"
+)
+
+// RankSort is the sort field ordering search results by relevance, most
+// relevant first ("-@rank" reverses the order). See QueryBuilder.Search.
+const RankSort = "@rank"
+
+// searchStoreKey is the app store key prefix of the search indexes,
+// followed by the collection id.
+const searchStoreKey = "dsl.search."
+
+// searchHooksStoreKey is the app store key marking that the index
+// synchronization hooks are bound.
+const searchHooksStoreKey = "dsl.searchHooks"
+
+// searchHookId is the id of the index synchronization hook handlers.
+const searchHookId = "dslSearchIndex"
+
+// searchTablePrefix is the name prefix of the FTS5 tables, followed by
+// the collection id.
+const searchTablePrefix = "_dsl_search_"
+
+// cjkSeparator is inserted between CJK characters by TokenizerCJK so that
+// every character is indexed as a separate token. It is a private use
+// character, removed again from snippets.
+const cjkSeparator = '\uE000'
+
+// SearchTokenizer selects how the text of the searched fields is split
+// into terms.
+type SearchTokenizer string
+
+const (
+	TokenizerUnicode SearchTokenizer = "unicode61" // Words separated by spaces and punctuation, case and diacritics insensitive (default)
+	TokenizerPorter  SearchTokenizer = "porter"    // Like TokenizerUnicode with English stemming ("running" matches "run")
+	TokenizerTrigram SearchTokenizer = "trigram"   // Substrings of at least 3 characters
+	TokenizerCJK     SearchTokenizer = "cjk"       // Like TokenizerUnicode, with every Chinese, Japanese and Korean character as a term
+)
+
+// SearchOptions configures the search index of a collection.
+type SearchOptions struct {
+	Tokenizer      SearchTokenizer // How the text is split into terms, TokenizerUnicode by default
+	HighlightStart string          // Inserted before the matched terms in snippets, "<mark>" by default
+	HighlightEnd   string          // Inserted after the matched terms in snippets, "</mark>" by default
+	SnippetTokens  int             // Maximum number of terms per snippet, 16 by default
+}
+
+// searchIndex is the FTS5 index of a collection.
+type searchIndex struct {
+	table   string        // The FTS5 table name
+	fields  []string      // The indexed fields, in column order after record_id
+	options SearchOptions // The index options with the defaults applied
+}
+
+// SearchHit is a record matching a full-text search, with its relevance
+// and highlighted snippets. See SearchHits.
+type SearchHit struct {
+	Record   *core.Record      // The matching record
+	Rank     float64           // The BM25 rank, lower values are more relevant
+	Snippets map[string]string // Highlighted excerpts keyed by the indexed field names
+}
+
+// EnableSearch creates and registers a full-text search index of the
+// given fields of collection for app, backed by an SQLite FTS5 table.
+//
+// The index is built from the existing records when it is created or its
+// fields or tokenizer change, and kept in sync by record hooks afterwards,
+// in the transaction of the record write. Records written without hooks, e.g. by the bulk operations with
+// SkipHooks, require a call to RebuildSearchIndex.
+//
+// The registration is kept in the app store, usually in an OnServe or
+// OnBootstrap hook.
+//
+// Example:
+//
+//	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+//	    err := dsl.EnableSearch(e.App, "products", []string{"name", "description"}, dsl.SearchOptions{
+//	        Tokenizer: dsl.TokenizerCJK,
+//	    })
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return e.Next()
+//	})
+func EnableSearch(app core.App, collection string, fields []string, opts ...SearchOptions) error {
+	c, err := app.FindCachedCollectionByNameOrId(collection)
+	if err != nil {
+		return collectionError(err)
+	}
+	if len(fields) == 0 {
+		return errors.New("search requires at least one field")
+	}
+	for _, field := range fields {
+		if c.Fields.GetByName(field) == nil {
+			return fmt.Errorf("collection %q has no field %q", c.Name, field)
+		}
+	}
+
+	idx := &searchIndex{table: searchTablePrefix + c.Id, fields: fields}
+	if len(opts) > 0 {
+		idx.options = opts[0]
+	}
+	if idx.options.Tokenizer == "" {
+		idx.options.Tokenizer = TokenizerUnicode
+	}
+	if idx.options.HighlightStart == "" && idx.options.HighlightEnd == "" {
+		idx.options.HighlightStart, idx.options.HighlightEnd = "<mark>", "</mark>"
+	}
+	if idx.options.SnippetTokens <= 0 {
+		idx.options.SnippetTokens = 16
+	}
+	ddl, err := idx.createSQL()
+	if err != nil {
+		return err
+	}
+
+	bindSearchHooks(app)
+
+	var existing string
+	err = app.DB().NewQuery("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = {:name}").
+		Bind(dbx.Params{"name": idx.table}).
+		Row(&existing)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	app.Store().Set(searchStoreKey+c.Id, idx)
+	if existing == ddl {
+		return nil
+	}
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().NewQuery("DROP TABLE IF EXISTS " + quoteIdentifier(idx.table)).Execute(); err != nil {
+			return err
+		}
+		if _, err := txApp.DB().NewQuery(ddl).Execute(); err != nil {
+			return err
+		}
+		return idx.fill(txApp, c)
+	})
+}
+
+// RebuildSearchIndex rebuilds the search index of collection from its
+// records, e.g. after records were written without hooks.
+//
+// Example:
+//
+//	err := dsl.RebuildSearchIndex(app, "products")
+func RebuildSearchIndex(app core.App, collection string) error {
+	c, err := app.FindCachedCollectionByNameOrId(collection)
+	if err != nil {
+		return collectionError(err)
+	}
+	idx := searchIndexOf(app, c)
+	if idx == nil {
+		return fmt.Errorf("search is not enabled for collection %q", c.Name)
+	}
+	return app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().NewQuery("DELETE FROM " + quoteIdentifier(idx.table)).Execute(); err != nil {
+			return err
+		}
+		return idx.fill(txApp, c)
+	})
+}
+
+// Search restricts the query to the records whose indexed fields match
+// the search terms, see EnableSearch.
+//
+// The terms are separated by spaces and all of them must match; a
+// trailing "*" matches a term prefix. Search combines with the filter of
+// the query. The results are ordered by relevance unless Sort is used,
+// which accepts RankSort ("@rank") next to the regular fields.
+//
+// Example:
+//
+//	records, err := dsl.Collection(app, "products").
+//	    List(*dsl.Query("status = 'active'").Search("wireless mou*").Sort("@rank,-created"))
+func (q *QueryBuilder) Search(text string) *QueryBuilder {
+	q.search = text
+	return q
+}
+
+// SearchHits runs the query like List and returns the matching records
+// with their relevance rank and a highlighted snippet of every indexed
+// field. The query must use Search.
+//
+// Example:
+//
+//	hits, err := dsl.Collection(app, "products").SearchHits(*dsl.Query("").Search("无线鼠标").Page(1, 10))
+//	for _, hit := range hits {
+//	    fmt.Println(hit.Record.Id, hit.Snippets["name"]) // 罗技<mark>无线鼠标</mark> M330
+//	}
+func (c *CollectionQueryBuilder) SearchHits(query QueryBuilder, params ...dbx.Params) ([]SearchHit, error) {
+	if strings.TrimSpace(query.search) == "" {
+		return nil, errors.New("search hits require a query with Search")
+	}
+	records, err := c.List(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, len(records))
+	if len(records) == 0 {
+		return hits, nil
+	}
+	idx := searchIndexOf(c.app, records[0].Collection())
+	if idx == nil {
+		return nil, fmt.Errorf("search is not enabled for collection %q", records[0].Collection().Name)
+	}
+
+	ids := make([]any, len(records))
+	for i, record := range records {
+		ids[i] = record.Id
+	}
+	columns := []string{"[[record_id]]", "[[rank]]"}
+	for i := range idx.fields {
+		columns = append(columns, fmt.Sprintf("snippet(%s, %d, {:start}, {:end}, '…', %d)", quoteIdentifier(idx.table), i+1, idx.options.SnippetTokens))
+	}
+	q := c.withQueryContext(c.app.DB().Select(columns...).
+		From(idx.table).
+		Where(dbx.NewExp(quoteIdentifier(idx.table)+" MATCH {:match}", dbx.Params{
+			"match": idx.matchQuery(query.search),
+			"start": idx.options.HighlightStart,
+			"end":   idx.options.HighlightEnd,
+		})).
+		AndWhere(dbx.In("record_id", ids...)))
+
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, c.contextError(err)
+	}
+	defer rows.Close()
+
+	details := make(map[string]SearchHit, len(records))
+	for rows.Next() {
+		var id string
+		var rank float64
+		snippets := make([]string, len(idx.fields))
+		dest := []any{&id, &rank}
+		for i := range snippets {
+			dest = append(dest, &snippets[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		hit := SearchHit{Rank: rank, Snippets: make(map[string]string, len(idx.fields))}
+		for i, field := range idx.fields {
+			hit.Snippets[field] = strings.ReplaceAll(snippets[i], string(cjkSeparator), "")
+		}
+		details[id] = hit
+	}
+	if err := rows.Err(); err != nil {
+		return nil, c.contextError(err)
+	}
+
+	for i, record := range records {
+		hits[i] = details[record.Id]
+		hits[i].Record = record
+	}
+	return hits, nil
+}
+
+// search joins the search index of the collection and restricts the query
+// to the records matching text.
+func (s *recordSelect) search(text string) error {
+	idx := searchIndexOf(s.app, s.collection)
+	if idx == nil {
+		return fmt.Errorf("search is not enabled for collection %q", s.collection.Name)
+	}
+	s.query.
+		InnerJoin(idx.table, dbx.NewExp("[["+idx.table+".record_id]] = [["+s.collection.Name+".id]]")).
+		AndWhere(dbx.NewExp("[["+idx.table+"]] MATCH {:dslSearch}", dbx.Params{"dslSearch": idx.matchQuery(text)}))
+	s.searchTable = idx.table
+	return nil
+}
+
+// searchIndexOf returns the search index of collection, or nil when
+// search isn't enabled for it.
+func searchIndexOf(app core.App, collection *core.Collection) *searchIndex {
+	idx, _ := app.Store().Get(searchStoreKey + collection.Id).(*searchIndex)
+	return idx
+}
+
+// bindSearchHooks binds the hooks keeping the search indexes of app in
+// sync, once.
+//
+// The index rows are written in the same transaction as the record, so
+// they commit or roll back together and an index failure fails the save.
+func bindSearchHooks(app core.App) {
+	app.Store().GetOrSet(searchHooksStoreKey, func() any {
+		// inTransaction runs the record write of e and then write in one
+		// transaction, the same way PocketBase cascades record deletes
+		inTransaction := func(e *core.RecordEvent, write func(txApp core.App, idx *searchIndex) error) error {
+			idx := searchIndexOf(app, e.Record.Collection())
+			if idx == nil {
+				return e.Next()
+			}
+			originalApp := e.App
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				e.App = txApp
+				if err := e.Next(); err != nil {
+					return err
+				}
+				if err := write(txApp, idx); err != nil {
+					return fmt.Errorf("failed to update the search index of %q: %w", e.Record.Collection().Name, err)
+				}
+				return nil
+			})
+			e.App = originalApp
+			return err
+		}
+		put := func(e *core.RecordEvent) error {
+			return inTransaction(e, func(txApp core.App, idx *searchIndex) error {
+				return idx.put(txApp, e.Record)
+			})
+		}
+		remove := func(e *core.RecordEvent) error {
+			return inTransaction(e, func(txApp core.App, idx *searchIndex) error {
+				return idx.remove(txApp, e.Record.Id)
+			})
+		}
+		app.OnRecordCreateExecute().Bind(&hook.Handler[*core.RecordEvent]{Id: searchHookId, Func: put})
+		app.OnRecordUpdateExecute().Bind(&hook.Handler[*core.RecordEvent]{Id: searchHookId, Func: put})
+		app.OnRecordDeleteExecute().Bind(&hook.Handler[*core.RecordEvent]{Id: searchHookId, Func: remove})
+
+		app.OnCollectionAfterDeleteSuccess().Bind(&hook.Handler[*core.CollectionEvent]{
+			Id: searchHookId,
+			Func: func(e *core.CollectionEvent) error {
+				if idx := searchIndexOf(app, e.Collection); idx != nil {
+					app.Store().Remove(searchStoreKey + e.Collection.Id)
+					if _, err := app.DB().NewQuery("DROP TABLE IF EXISTS " + quoteIdentifier(idx.table)).Execute(); err != nil {
+						app.Logger().Error("Failed to drop the search index", "collection", e.Collection.Name, "error", err)
+					}
+				}
+				return e.Next()
+			},
+		})
+		return true
+	})
+}
+
+// createSQL returns the statement creating the FTS5 table of the index.
+func (idx *searchIndex) createSQL() (string, error) {
+	var tokenize string
+	switch idx.options.Tokenizer {
+	case TokenizerUnicode:
+		tokenize = "unicode61 remove_diacritics 2"
+	case TokenizerPorter:
+		tokenize = "porter unicode61 remove_diacritics 2"
+	case TokenizerTrigram:
+		tokenize = "trigram"
+	case TokenizerCJK:
+		tokenize = "unicode61 remove_diacritics 2 separators " + quoteString(string(cjkSeparator))
+	default:
+		return "", fmt.Errorf("unknown search tokenizer %q", idx.options.Tokenizer)
+	}
+
+	columns := []string{"record_id UNINDEXED"}
+	for _, field := range idx.fields {
+		columns = append(columns, quoteIdentifier(field))
+	}
+	return fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, tokenize = %s)",
+		quoteIdentifier(idx.table), strings.Join(columns, ", "), quoteString(tokenize)), nil
+}
+
+// fill indexes all the records of collection, including the soft-deleted
+// ones.
+func (idx *searchIndex) fill(app core.App, collection *core.Collection) error {
+	return Collection(app, collection.Id).WithDeleted().EachChunk(context.Background(), *Query(""), func(records []*core.Record) error {
+		for _, record := range records {
+			if err := idx.insert(app, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// put replaces the indexed text of record.
+func (idx *searchIndex) put(app core.App, record *core.Record) error {
+	if err := idx.remove(app, record.Id); err != nil {
+		return err
+	}
+	return idx.insert(app, record)
+}
+
+// insert adds the text of record to the index.
+func (idx *searchIndex) insert(app core.App, record *core.Record) error {
+	values := dbx.Params{"record_id": record.Id}
+	for _, field := range idx.fields {
+		values[field] = idx.text(record.Get(field))
+	}
+	_, err := app.DB().Insert(idx.table, values).Execute()
+	return err
+}
+
+// remove removes the text of the record with the given id from the index.
+func (idx *searchIndex) remove(app core.App, id string) error {
+	_, err := app.DB().Delete(idx.table, dbx.HashExp{"record_id": id}).Execute()
+	return err
+}
+
+// text returns the indexed text of a field value.
+func (idx *searchIndex) text(value any) string {
+	var text string
+	if values, ok := value.([]string); ok {
+		text = strings.Join(values, " ")
+	} else {
+		text = cast.ToString(value)
+	}
+	if idx.options.Tokenizer == TokenizerCJK {
+		text = separateCJK(text)
+	}
+	return text
+}
+
+// matchQuery returns the FTS5 query matching all the terms of text.
+//
+// The terms are quoted, so that the FTS5 query syntax can't be injected.
+func (idx *searchIndex) matchQuery(text string) string {
+	terms := strings.Fields(text)
+	for i, term := range terms {
+		prefix := len(term) > 1 && strings.HasSuffix(term, "*")
+		if prefix {
+			term = strings.TrimSuffix(term, "*")
+		}
+		if idx.options.Tokenizer == TokenizerCJK {
+			term = separateCJK(term)
+		}
+		terms[i] = quoteString(term, '"')
+		if prefix {
+			terms[i] += "*"
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// separateCJK inserts cjkSeparator between the Chinese, Japanese and
+// Korean characters of text and their neighbours.
+func separateCJK(text string) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range text {
+		if (isCJK(r) || isCJK(prev)) && !unicode.IsSpace(r) && !unicode.IsSpace(prev) {
+			b.WriteRune(cjkSeparator)
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// quoteIdentifier quotes an SQLite identifier.
+func quoteIdentifier(name string) string {
+	return quoteString(name, '"')
+}
+
+// quoteString quotes an SQLite string literal, or with the given quote.
+func quoteString(s string, quote ...rune) string {
+	q := "'"
+	if len(quote) > 0 {
+		q = string(quote[0])
+	}
+	return q + strings.ReplaceAll(s, q, q+q) + q
+}
@@ -0,0 +1,279 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newSearchApp returns a test app whose products collection has a
+// description field.
+func newSearchApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	app := newTestApp(t)
+	products, err := app.FindCollectionByNameOrId("products")
+	if err != nil {
+		t.Fatalf("Failed to find products collection: %v", err)
+	}
+	products.Fields.Add(&core.TextField{Name: "description"})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	return app
+}
+
+// enableSearch enables search of the products name and description or
+// fails the test.
+func enableSearch(t *testing.T, app core.App, opts ...SearchOptions) {
+	t.Helper()
+
+	if err := EnableSearch(app, "products", []string{"name", "description"}, opts...); err != nil {
+		t.Fatalf("Failed to enable search: %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newSearchApp(t)
+	c := Collection(app, "products")
+
+	// existing records are indexed when search is enabled
+	mustCreate(t, app, "products", map[string]any{"name": "Wireless mouse", "description": "A quiet mouse", "price": 20, "status": "active"})
+	enableSearch(t, app)
+	mustCreate(t, app, "products", map[string]any{"name": "Wired keyboard", "description": "Works with any mouse", "price": 30, "status": "active"})
+	mustCreate(t, app, "products", map[string]any{"name": "Mouse pad", "price": 5, "status": "draft"})
+	mustCreate(t, app, "products", map[string]any{"name": "Monitor", "description": "Wireless casting", "price": 200, "status": "active"})
+
+	search := func(query *QueryBuilder) string {
+		records, err := c.List(*query)
+		if err != nil {
+			t.Fatalf("Failed to search records: %v", err)
+		}
+		return recordNames(records)
+	}
+
+	// ordered by relevance: matches in both fields rank first
+	if names := search(Query("").Search("mouse")); !strings.HasPrefix(names, "Wireless mouse,") || strings.Count(names, ",") != 2 {
+		t.Errorf("Expected the 3 mouse products with Wireless mouse first, got %s", names)
+	}
+	if names := search(Query("").Search("wireless mouse")); names != "Wireless mouse" {
+		t.Errorf("Expected all terms to match, got %s", names)
+	}
+	if names := search(Query("status = 'active'").Search("mouse").Sort("name")); names != "Wired keyboard,Wireless mouse" {
+		t.Errorf("Expected the search combined with the filter, got %s", names)
+	}
+	if names := search(Query("").Search("mon*")); names != "Monitor" {
+		t.Errorf("Expected a prefix match, got %s", names)
+	}
+	if names := search(Query("").Search(`"mouse" OR monitor`)); names != "" {
+		t.Errorf("Expected the FTS syntax to be quoted, got %s", names)
+	}
+
+	// the index follows updates and deletes
+	pad, err := c.First(*Query("name = 'Mouse pad'"))
+	if err != nil {
+		t.Fatalf("Failed to fetch record: %v", err)
+	}
+	if _, err := c.Update(pad.Id, map[string]any{"name": "Desk pad"}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if names := search(Query("").Search("desk")); names != "Desk pad" {
+		t.Errorf("Expected the updated record to be found, got %s", names)
+	}
+	if err := c.Delete(pad.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if names := search(Query("").Search("pad")); names != "" {
+		t.Errorf("Expected the deleted record to be removed from the index, got %s", names)
+	}
+
+	result, err := c.ListPage(*Query("").Search("wireless").Page(1, 1))
+	if err != nil {
+		t.Fatalf("Failed to search records: %v", err)
+	}
+	if result.TotalItems != 2 || len(result.Items) != 1 {
+		t.Errorf("Expected 2 total items and 1 item, got %d and %d", result.TotalItems, len(result.Items))
+	}
+	if total, err := c.Sum("price", *Query("").Search("wireless")); err != nil || total != 220 {
+		t.Errorf("Expected the sum of the matching prices 220, got %v (%v)", total, err)
+	}
+
+	if _, err := c.List(*Query("").Sort(RankSort)); err == nil {
+		t.Error("Expected error when sorting by rank without Search")
+	}
+	if _, err := Collection(app, "categories").List(*Query("").Search("tools")); err == nil {
+		t.Error("Expected error when searching a collection without index")
+	}
+}
+
+func TestSearchIndexTransaction(t *testing.T) {
+	app := newSearchApp(t)
+	enableSearch(t, app)
+	c := Collection(app, "products")
+
+	// the index rows roll back with the record
+	errRollback := errors.New("rollback")
+	err := app.RunInTransaction(func(txApp core.App) error {
+		mustCreate(t, txApp, "products", map[string]any{"name": "Wireless mouse"})
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Expected the rollback error, got %v", err)
+	}
+	products, _ := app.FindCollectionByNameOrId("products")
+	table := searchIndexOf(app, products).table
+	var rows int
+	if err := app.DB().Select("count(*)").From(table).Row(&rows); err != nil || rows != 0 {
+		t.Errorf("Expected no index rows after the rollback, got %d (%v)", rows, err)
+	}
+
+	// an index failure fails the save instead of leaving the index behind
+	if _, err := app.DB().NewQuery("DROP TABLE " + quoteIdentifier(table)).Execute(); err != nil {
+		t.Fatalf("Failed to drop the index table: %v", err)
+	}
+	if _, err := c.Create(map[string]any{"name": "Monitor"}); err == nil || !strings.Contains(err.Error(), "search index") {
+		t.Errorf("Expected the index error, got %v", err)
+	}
+	if count := mustCount(t, app, "products"); count != 0 {
+		t.Errorf("Expected the record to be rolled back, got %d records", count)
+	}
+}
+
+func TestSearchHits(t *testing.T) {
+	app := newSearchApp(t)
+	enableSearch(t, app, SearchOptions{HighlightStart: "[", HighlightEnd: "]"})
+	mustCreate(t, app, "products", map[string]any{"name": "Wireless mouse", "description": "A quiet wireless mouse for travel"})
+	mustCreate(t, app, "products", map[string]any{"name": "Keyboard", "description": "Pairs with a wireless dongle"})
+
+	hits, err := Collection(app, "products").SearchHits(*Query("").Search("wireless"))
+	if err != nil {
+		t.Fatalf("Failed to search records: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("Expected 2 hits, got %d", len(hits))
+	}
+	if hits[0].Record.GetString("name") != "Wireless mouse" {
+		t.Errorf("Expected the most relevant hit first, got %s", hits[0].Record.GetString("name"))
+	}
+	if hits[0].Rank >= hits[1].Rank {
+		t.Errorf("Expected a lower rank for the first hit, got %v and %v", hits[0].Rank, hits[1].Rank)
+	}
+	if snippet := hits[0].Snippets["name"]; snippet != "[Wireless] mouse" {
+		t.Errorf("Expected the highlighted name, got %q", snippet)
+	}
+	if snippet := hits[1].Snippets["description"]; snippet != "Pairs with a [wireless] dongle" {
+		t.Errorf("Expected the highlighted description, got %q", snippet)
+	}
+
+	if _, err := Collection(app, "products").SearchHits(*Query("")); err == nil {
+		t.Error("Expected error for a query without Search")
+	}
+}
+
+func TestSearchCJK(t *testing.T) {
+	app := newSearchApp(t)
+	enableSearch(t, app, SearchOptions{Tokenizer: TokenizerCJK})
+	c := Collection(app, "products")
+
+	mustCreate(t, app, "products", map[string]any{"name": "罗技无线鼠标 M330", "description": "静音设计"})
+	mustCreate(t, app, "products", map[string]any{"name": "有线键盘", "description": "适合办公"})
+	mustCreate(t, app, "products", map[string]any{"name": "无线充电器"})
+
+	search := func(text string) string {
+		records, err := c.List(*Query("").Search(text).Sort("name"))
+		if err != nil {
+			t.Fatalf("Failed to search records: %v", err)
+		}
+		return recordNames(records)
+	}
+	if names := search("无线"); names != "无线充电器,罗技无线鼠标 M330" {
+		t.Errorf("Expected the 2 wireless products, got %s", names)
+	}
+	if names := search("鼠标"); names != "罗技无线鼠标 M330" {
+		t.Errorf("Expected the mouse, got %s", names)
+	}
+	if names := search("线鼠"); names != "罗技无线鼠标 M330" {
+		t.Errorf("Expected a match inside a word, got %s", names)
+	}
+	if names := search("鼠线"); names != "" {
+		t.Errorf("Expected the characters to match in order, got %s", names)
+	}
+	if names := search("无线 m330"); names != "罗技无线鼠标 M330" {
+		t.Errorf("Expected mixed terms to match, got %s", names)
+	}
+
+	hits, err := c.SearchHits(*Query("").Search("无线鼠标"))
+	if err != nil {
+		t.Fatalf("Failed to search records: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %d", len(hits))
+	}
+	if snippet := hits[0].Snippets["name"]; snippet != "罗技<mark>无线鼠标</mark> M330" {
+		t.Errorf("Expected the highlighted name without separators, got %q", snippet)
+	}
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	app := newSearchApp(t)
+	enableSearch(t, app)
+	c := Collection(app, "products")
+
+	// bulk inserts without hooks aren't indexed until the rebuild
+	_, err := c.CreateMany([]map[string]any{{"name": "Laser printer"}, {"name": "Inkjet printer"}}, BulkOptions{SkipHooks: true})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if count := searchCount(t, c, "printer"); count != 0 {
+		t.Fatalf("Expected no indexed records, got %d", count)
+	}
+	if err := RebuildSearchIndex(app, "products"); err != nil {
+		t.Fatalf("Failed to rebuild search index: %v", err)
+	}
+	if count := searchCount(t, c, "printer"); count != 2 {
+		t.Errorf("Expected 2 indexed records, got %d", count)
+	}
+
+	// enabling again with the same settings keeps the index, a change of
+	// the fields rebuilds it
+	enableSearch(t, app)
+	if count := searchCount(t, c, "printer"); count != 2 {
+		t.Errorf("Expected the index to be kept, got %d", count)
+	}
+	if err := EnableSearch(app, "products", []string{"description"}); err != nil {
+		t.Fatalf("Failed to enable search: %v", err)
+	}
+	if count := searchCount(t, c, "printer"); count != 0 {
+		t.Errorf("Expected the index of the description only, got %d", count)
+	}
+
+	if err := EnableSearch(app, "products", []string{"missing"}); err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+	if err := RebuildSearchIndex(app, "categories"); err == nil {
+		t.Error("Expected error for a collection without index")
+	}
+
+	// the index is dropped with its collection
+	table := searchTablePrefix + mustCollection(t, app, "products").Id
+	if err := app.Delete(mustCollection(t, app, "products")); err != nil {
+		t.Fatalf("Failed to delete collection: %v", err)
+	}
+	if app.HasTable(table) {
+		t.Errorf("Expected the search table %s to be dropped", table)
+	}
+}
+
+// searchCount returns the number of records matching text.
+func searchCount(t *testing.T, c *CollectionQueryBuilder, text string) int {
+	t.Helper()
+
+	records, err := c.List(*Query("").Search(text))
+	if err != nil {
+		t.Fatalf("Failed to search records: %v", err)
+	}
+	return len(records)
+}
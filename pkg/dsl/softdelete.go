@@ -0,0 +1,208 @@
+package dsl
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DefaultSoftDeleteField is the name of the date field holding the
+// deletion time of soft-deletable collections.
+const DefaultSoftDeleteField = "deleted"
+
+// softDeleteStoreKey is the app store key prefix of the soft delete
+// registrations, followed by the collection id.
+const softDeleteStoreKey = "dsl.softDelete."
+
+// deletedScope selects which records of a soft-deletable collection are
+// visible to a CollectionQueryBuilder.
+type deletedScope int
+
+const (
+	scopeActive      deletedScope = iota // Only the records that aren't deleted (default)
+	scopeWithDeleted                     // All records
+	scopeOnlyDeleted                     // Only the deleted records
+)
+
+// EnableSoftDelete registers collection as soft-deletable for app.
+//
+// The collection must have a date field, named "deleted" unless another
+// field name is passed, which holds the deletion time of the records.
+// Once registered, Delete and DeleteWhere set that field instead of
+// removing the records, and One, First, List, Count and the other read
+// operations ignore the deleted records unless WithDeleted or OnlyDeleted
+// is used. Restore and ForceDelete undelete and physically delete records.
+//
+// The registration is kept in the app store, usually in an OnServe or
+// OnBootstrap hook.
+//
+// Example:
+//
+//	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+//	    if err := dsl.EnableSoftDelete(e.App, "orders"); err != nil {
+//	        return err
+//	    }
+//	    return e.Next()
+//	})
+func EnableSoftDelete(app core.App, collection string, field ...string) error {
+	c, err := app.FindCachedCollectionByNameOrId(collection)
+	if err != nil {
+		return collectionError(err)
+	}
+	name := DefaultSoftDeleteField
+	if len(field) > 0 && field[0] != "" {
+		name = field[0]
+	}
+	if _, ok := c.Fields.GetByName(name).(*core.DateField); !ok {
+		return fmt.Errorf("collection %q has no date field %q", c.Name, name)
+	}
+	app.Store().Set(softDeleteStoreKey+c.Id, name)
+	return nil
+}
+
+// softDeleteField returns the deletion time field of collection, or an
+// empty string when it isn't soft-deletable.
+func softDeleteField(app core.App, collection *core.Collection) string {
+	name, _ := app.Store().Get(softDeleteStoreKey + collection.Id).(string)
+	return name
+}
+
+// WithDeleted returns a copy of the builder whose operations include the
+// soft-deleted records.
+//
+// Example:
+//
+//	records, err := dsl.Collection(app, "orders").WithDeleted().List(*dsl.Query(""))
+func (c *CollectionQueryBuilder) WithDeleted() *CollectionQueryBuilder {
+	clone := *c
+	clone.scope = scopeWithDeleted
+	return &clone
+}
+
+// OnlyDeleted returns a copy of the builder whose operations only see the
+// soft-deleted records.
+//
+// Example:
+//
+//	trash, err := dsl.Collection(app, "orders").OnlyDeleted().List(*dsl.Query("").Sort("-deleted"))
+func (c *CollectionQueryBuilder) OnlyDeleted() *CollectionQueryBuilder {
+	clone := *c
+	clone.scope = scopeOnlyDeleted
+	return &clone
+}
+
+// withApp returns a copy of the builder operating on app, e.g. the app of
+// a transaction.
+func (c *CollectionQueryBuilder) withApp(app core.App) *CollectionQueryBuilder {
+	clone := *c
+	clone.app = app
+	return &clone
+}
+
+// deletedExpr returns the condition selecting the records of collection
+// visible in the builder scope, or nil when all records are visible.
+func (c *CollectionQueryBuilder) deletedExpr(collection *core.Collection) dbx.Expression {
+	field := softDeleteField(c.app, collection)
+	if field == "" {
+		return nil
+	}
+	column := "[[" + collection.Name + "." + field + "]]"
+	switch c.scope {
+	case scopeWithDeleted:
+		return nil
+	case scopeOnlyDeleted:
+		return dbx.NewExp(column + " != ''")
+	default:
+		return dbx.NewExp("(" + column + " = '' OR " + column + " IS NULL)")
+	}
+}
+
+// visible reports whether record is visible in the builder scope.
+func (c *CollectionQueryBuilder) visible(record *core.Record) bool {
+	field := softDeleteField(c.app, record.Collection())
+	if field == "" {
+		return true
+	}
+	deleted := !record.GetDateTime(field).IsZero()
+	switch c.scope {
+	case scopeWithDeleted:
+		return true
+	case scopeOnlyDeleted:
+		return deleted
+	default:
+		return !deleted
+	}
+}
+
+// deleteRecord soft deletes record when its collection is soft-deletable
+// and deletes it otherwise.
+func (c *CollectionQueryBuilder) deleteRecord(record *core.Record) error {
+	field := softDeleteField(c.app, record.Collection())
+	if field == "" {
+		return c.delete(record)
+	}
+	if err := c.checkRule(record, record.Collection().DeleteRule, "delete"); err != nil {
+		return err
+	}
+	record.Set(field, types.NowDateTime())
+	return c.withoutRules().save(record)
+}
+
+// Restore undeletes a soft-deleted record by ID.
+//
+// Restore returns an error if the collection isn't soft-deletable or if no
+// deleted record has the given ID.
+//
+// Example:
+//
+//	err := dsl.Collection(app, "orders").Restore("order123")
+func (c *CollectionQueryBuilder) Restore(id string) error {
+	record, err := c.OnlyDeleted().One(id)
+	if err != nil {
+		return err
+	}
+	field := softDeleteField(c.app, record.Collection())
+	if field == "" {
+		return fmt.Errorf("collection %q is not soft-deletable", record.Collection().Name)
+	}
+	record.Set(field, "")
+	return c.save(record)
+}
+
+// ForceDelete physically deletes a record by ID, whether it is
+// soft-deleted or not, with the regular delete hooks.
+//
+// Example:
+//
+//	err := dsl.Collection(app, "orders").ForceDelete("order123")
+func (c *CollectionQueryBuilder) ForceDelete(id string) error {
+	record, err := c.findRecordById(id)
+	if err != nil {
+		return err
+	}
+	return c.delete(record)
+}
+
+// WithDeleted returns a copy of the repository whose operations include
+// the soft-deleted records. See CollectionQueryBuilder.WithDeleted.
+func (r *Repo[T]) WithDeleted() *Repo[T] {
+	return &Repo[T]{collection: r.collection.WithDeleted()}
+}
+
+// OnlyDeleted returns a copy of the repository whose operations only see
+// the soft-deleted records. See CollectionQueryBuilder.OnlyDeleted.
+func (r *Repo[T]) OnlyDeleted() *Repo[T] {
+	return &Repo[T]{collection: r.collection.OnlyDeleted()}
+}
+
+// Restore undeletes a soft-deleted record by ID.
+func (r *Repo[T]) Restore(id string) error {
+	return r.collection.Restore(id)
+}
+
+// ForceDelete physically deletes a record by ID.
+func (r *Repo[T]) ForceDelete(id string) error {
+	return r.collection.ForceDelete(id)
+}
@@ -0,0 +1,229 @@
+package dsl
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newSoftDeleteApp extends the test app with a "deleted" date field on
+// products and registers products as soft-deletable.
+func newSoftDeleteApp(t *testing.T) *tests.TestApp {
+	app := newTestApp(t)
+
+	products := mustCollection(t, app, "products")
+	products.Fields.Add(&core.DateField{Name: "deleted"})
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to update products collection: %v", err)
+	}
+	if err := EnableSoftDelete(app, "products"); err != nil {
+		t.Fatalf("Failed to enable soft delete: %v", err)
+	}
+	return app
+}
+
+func TestEnableSoftDeleteErrors(t *testing.T) {
+	app := newTestApp(t)
+
+	if err := EnableSoftDelete(app, "missing"); err == nil {
+		t.Error("Expected error for a missing collection")
+	}
+	if err := EnableSoftDelete(app, "products"); err == nil {
+		t.Error("Expected error for a collection without a deleted field")
+	}
+	if err := EnableSoftDelete(app, "products", "name"); err == nil {
+		t.Error("Expected error for a non-date field")
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	app := newSoftDeleteApp(t)
+	c := Collection(app, "products")
+
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20})
+
+	if err := c.Delete(hammer.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Fatalf("Expected the record to be kept, got %d records", total)
+	}
+
+	if _, err := c.One(hammer.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a deleted record, got %v", err)
+	}
+	if err := c.Delete(hammer.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows when deleting twice, got %v", err)
+	}
+	if _, err := c.Update(hammer.Id, map[string]any{"price": 1}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows when updating a deleted record, got %v", err)
+	}
+
+	records, err := c.List(*Query("").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "saw" {
+		t.Errorf("Expected saw, got %s", names)
+	}
+	if _, err := c.First(*Query("name = 'hammer'")); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows from First, got %v", err)
+	}
+	if count, err := c.Count(""); err != nil || count != 1 {
+		t.Errorf("Expected count 1, got %d (%v)", count, err)
+	}
+	if sum, err := c.Sum("price", *Query("")); err != nil || sum != 20 {
+		t.Errorf("Expected sum 20, got %v (%v)", sum, err)
+	}
+
+	records, err = c.WithDeleted().List(*Query("").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "hammer,saw" {
+		t.Errorf("Expected hammer,saw, got %s", names)
+	}
+	if count, err := c.WithDeleted().Count("price > {:price}", map[string]any{"price": 5}); err != nil || count != 2 {
+		t.Errorf("Expected count 2, got %d (%v)", count, err)
+	}
+
+	records, err = c.OnlyDeleted().List(*Query(""))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "hammer" {
+		t.Errorf("Expected hammer, got %s", names)
+	}
+	record, err := c.OnlyDeleted().One(hammer.Id)
+	if err != nil {
+		t.Fatalf("Failed to fetch deleted record: %v", err)
+	}
+	if record.GetDateTime("deleted").IsZero() {
+		t.Error("Expected the deletion time to be set")
+	}
+
+	if err := c.Restore(hammer.Id); err != nil {
+		t.Fatalf("Failed to restore record: %v", err)
+	}
+	if _, err := c.One(hammer.Id); err != nil {
+		t.Errorf("Expected the restored record to be visible, got %v", err)
+	}
+	if err := c.Restore(hammer.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows when restoring a record that isn't deleted, got %v", err)
+	}
+
+	if err := c.Delete(hammer.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if err := c.ForceDelete(hammer.Id); err != nil {
+		t.Fatalf("Failed to force delete record: %v", err)
+	}
+	if total := mustCount(t, app, "products"); total != 1 {
+		t.Errorf("Expected 1 record after force delete, got %d", total)
+	}
+}
+
+func TestSoftDeleteWhere(t *testing.T) {
+	for _, skipHooks := range []bool{false, true} {
+		app := newSoftDeleteApp(t)
+		c := Collection(app, "products")
+
+		mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+		mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20})
+
+		result, err := c.DeleteWhere(*Query("price > 15"), BulkOptions{SkipHooks: skipHooks})
+		if err != nil {
+			t.Fatalf("Failed to delete records: %v", err)
+		}
+		if result.Affected != 1 {
+			t.Errorf("Expected 1 affected record, got %d", result.Affected)
+		}
+		if total := mustCount(t, app, "products"); total != 2 {
+			t.Errorf("Expected the records to be kept, got %d records", total)
+		}
+		records, err := c.OnlyDeleted().List(*Query(""))
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		if names := recordNames(records); names != "saw" {
+			t.Errorf("Expected saw to be deleted (SkipHooks %v), got %s", skipHooks, names)
+		}
+	}
+}
+
+func TestSoftDeleteRestoreNotSoftDeletable(t *testing.T) {
+	app := newTestApp(t)
+	record := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+
+	if err := Collection(app, "categories").Restore(record.Id); err == nil {
+		t.Error("Expected error for a collection that isn't soft-deletable")
+	}
+	if err := Collection(app, "categories").Delete(record.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if total := mustCount(t, app, "categories"); total != 0 {
+		t.Errorf("Expected the record to be removed, got %d records", total)
+	}
+}
+
+func TestSoftDeleteExpand(t *testing.T) {
+	app := newTestApp(t)
+	categories := mustCollection(t, app, "categories")
+	categories.Fields.Add(&core.DateField{Name: "deleted"})
+	if err := app.Save(categories); err != nil {
+		t.Fatalf("Failed to update categories collection: %v", err)
+	}
+	if err := EnableSoftDelete(app, "categories"); err != nil {
+		t.Fatalf("Failed to enable soft delete: %v", err)
+	}
+	tools := mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "category": tools.Id})
+	if err := Collection(app, "categories").Delete(tools.Id); err != nil {
+		t.Fatalf("Failed to soft delete record: %v", err)
+	}
+
+	// Expand and Preload both skip the deleted category
+	for name, query := range map[string]*QueryBuilder{
+		"expand":  Query("").Expand("category"),
+		"preload": Query("").Preload("category"),
+	} {
+		record, err := Collection(app, "products").First(*query)
+		if err != nil {
+			t.Fatalf("%s: failed to fetch the record: %v", name, err)
+		}
+		if related := record.ExpandedOne("category"); related != nil {
+			t.Errorf("%s: expected the deleted category not to be loaded, got %s", name, related.Id)
+		}
+	}
+	if record, err := Collection(app, "products").One(hammer.Id); err != nil || record.GetString("category") != tools.Id {
+		t.Errorf("Expected the relation value to be kept, got %v (%v)", record, err)
+	}
+}
+
+func TestSoftDeleteUpsert(t *testing.T) {
+	app := newSoftDeleteApp(t)
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+	products := Collection(app, "products")
+	if err := products.Delete(hammer.Id); err != nil {
+		t.Fatalf("Failed to soft delete record: %v", err)
+	}
+
+	// the deleted match is restored instead of duplicated
+	record, created, err := products.Upsert([]string{"name"}, map[string]any{"name": "hammer", "price": 20})
+	if err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	if created || record.Id != hammer.Id || record.GetInt("price") != 20 || !record.GetDateTime("deleted").IsZero() {
+		t.Errorf("Expected the deleted record to be restored and updated, got created=%v %v", created, record.FieldsData())
+	}
+	if _, err := products.One(hammer.Id); err != nil {
+		t.Errorf("Expected the restored record to be visible, got %v", err)
+	}
+	if total := mustCount(t, app, "products"); total != 1 {
+		t.Errorf("Expected a single record, got %d", total)
+	}
+}
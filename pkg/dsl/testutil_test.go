@@ -0,0 +1,77 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// newTestApp creates an empty PocketBase app in a temporary directory with
+// a "products" collection (name, price, status, tags) and a "categories"
+// collection referenced by products.category.
+func newTestApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+
+	categories := core.NewBaseCollection("categories")
+	categories.Fields.Add(&core.TextField{Name: "name"})
+	if err := app.Save(categories); err != nil {
+		t.Fatalf("Failed to create categories collection: %v", err)
+	}
+
+	products := core.NewBaseCollection("products")
+	products.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.NumberField{Name: "price"},
+		&core.SelectField{Name: "status", Values: []string{"draft", "active", "archived"}, MaxSelect: 1},
+		&core.SelectField{Name: "tags", Values: []string{"new", "sale", "hot"}, MaxSelect: 3},
+		&core.RelationField{Name: "category", CollectionId: categories.Id, MaxSelect: 1},
+		&core.AutodateField{Name: "created", OnCreate: true},
+		&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+	)
+	if err := app.Save(products); err != nil {
+		t.Fatalf("Failed to create products collection: %v", err)
+	}
+
+	return app
+}
+
+// mustCreate creates a record in collection or fails the test.
+func mustCreate(t *testing.T, app core.App, collection string, data map[string]any) *core.Record {
+	t.Helper()
+
+	record, err := Collection(app, collection).Create(data)
+	if err != nil {
+		t.Fatalf("Failed to create %s record: %v", collection, err)
+	}
+	return record
+}
+
+// mustCollection returns the collection with the given name or fails the
+// test.
+func mustCollection(t *testing.T, app core.App, name string) *core.Collection {
+	t.Helper()
+
+	collection, err := app.FindCollectionByNameOrId(name)
+	if err != nil {
+		t.Fatalf("Failed to find %s collection: %v", name, err)
+	}
+	return collection
+}
+
+// mustCount returns the number of records in collection or fails the test.
+func mustCount(t *testing.T, app core.App, collection string) int64 {
+	t.Helper()
+
+	total, err := app.CountRecords(collection)
+	if err != nil {
+		t.Fatalf("Failed to count %s records: %v", collection, err)
+	}
+	return total
+}
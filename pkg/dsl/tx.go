@@ -0,0 +1,120 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// savepointSeq generates unique savepoint names for nested transactions.
+var savepointSeq atomic.Uint64
+
+// Tx represents a running transaction started with Transaction.
+//
+// All operations must go through the Tx (or the app returned by App) to
+// be part of the transaction; using the original app inside the callback
+// bypasses it and may deadlock on SQLite's write lock.
+type Tx struct {
+	app core.App // The transactional PocketBase app
+}
+
+// Transaction runs fn inside a database transaction.
+//
+// The transaction is committed when fn returns nil and rolled back when
+// fn returns an error or panics (the panic is re-raised after the
+// rollback). The error returned by fn is returned as is.
+//
+// When app is already transactional (e.g. tx.App() or the app of a
+// record hook running inside a transaction) the call is nested: it runs in
+// a SAVEPOINT, so an error rolls back only the changes made by fn and the
+// outer transaction can continue. Note that PocketBase still fires the
+// after-success hooks of records saved in a rolled back savepoint once the
+// outer transaction commits.
+//
+// Example:
+//
+//	err := dsl.Transaction(app, func(tx *dsl.Tx) error {
+//	    order, err := tx.Collection("orders").Create(map[string]any{"total": 10})
+//	    if err != nil {
+//	        return err
+//	    }
+//	    _, err = tx.Collection("order_items").Create(map[string]any{"order": order.Id})
+//	    return err
+//	})
+func Transaction(app core.App, fn func(tx *Tx) error) error {
+	if app.IsTransactional() {
+		return savepoint(app, fn)
+	}
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		return fn(&Tx{app: txApp})
+	})
+}
+
+// savepoint runs fn inside a savepoint of the already running transaction
+// of app.
+func savepoint(app core.App, fn func(tx *Tx) error) (err error) {
+	name := fmt.Sprintf("dsl_sp_%d", savepointSeq.Add(1))
+	db := app.NonconcurrentDB()
+
+	if _, err := db.NewQuery("SAVEPOINT " + name).Execute(); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	rollback := func() error {
+		_, err := db.NewQuery("ROLLBACK TO " + name).Execute()
+		if err == nil {
+			_, err = db.NewQuery("RELEASE " + name).Execute()
+		}
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rollbackErr := rollback(); rollbackErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to roll back savepoint: %w", rollbackErr))
+			}
+			return
+		}
+		if _, releaseErr := db.NewQuery("RELEASE " + name).Execute(); releaseErr != nil {
+			err = fmt.Errorf("failed to release savepoint: %w", releaseErr)
+		}
+	}()
+
+	return fn(&Tx{app: app})
+}
+
+// App returns the transactional PocketBase app, for calling app methods
+// that aren't covered by the DSL.
+func (tx *Tx) App() core.App {
+	return tx.app
+}
+
+// Collection creates a new CollectionQueryBuilder bound to the
+// transaction.
+//
+// Example:
+//
+//	record, err := tx.Collection("users").One("user123")
+func (tx *Tx) Collection(collection string) *CollectionQueryBuilder {
+	return Collection(tx.app, collection)
+}
+
+// Transaction runs fn in a nested transaction (savepoint). See the
+// package-level Transaction for the semantics.
+//
+// Example:
+//
+//	err := tx.Transaction(func(tx *dsl.Tx) error {
+//	    // an error here only undoes the changes made in this callback
+//	    return nil
+//	})
+func (tx *Tx) Transaction(fn func(tx *Tx) error) error {
+	return Transaction(tx.app, fn)
+}
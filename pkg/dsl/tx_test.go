@@ -0,0 +1,103 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	app := newTestApp(t)
+
+	err := Transaction(app, func(tx *Tx) error {
+		if _, err := tx.Collection("products").Create(map[string]any{"name": "a"}); err != nil {
+			return err
+		}
+		_, err := tx.Collection("products").Create(map[string]any{"name": "b"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Errorf("Expected 2 records after commit, got %d", total)
+	}
+
+	failure := errors.New("failure")
+	err = Transaction(app, func(tx *Tx) error {
+		if _, err := tx.Collection("products").Create(map[string]any{"name": "c"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Errorf("Expected 2 records after rollback, got %d", total)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to be re-raised")
+			}
+		}()
+		Transaction(app, func(tx *Tx) error {
+			tx.Collection("products").Create(map[string]any{"name": "d"})
+			panic("boom")
+		})
+	}()
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Errorf("Expected 2 records after panic, got %d", total)
+	}
+}
+
+func TestTransactionNested(t *testing.T) {
+	app := newTestApp(t)
+
+	failure := errors.New("failure")
+	err := Transaction(app, func(tx *Tx) error {
+		if _, err := tx.Collection("products").Create(map[string]any{"name": "outer"}); err != nil {
+			return err
+		}
+
+		// a failed nested transaction only undoes its own changes
+		err := tx.Transaction(func(tx *Tx) error {
+			if _, err := tx.Collection("products").Create(map[string]any{"name": "inner"}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected the nested callback error, got %v", err)
+		}
+
+		return Transaction(tx.App(), func(tx *Tx) error {
+			_, err := tx.Collection("products").Create(map[string]any{"name": "kept"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	records, err := Collection(app, "products").List(*Query("").Sort("name"))
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if names := recordNames(records); names != "kept,outer" {
+		t.Errorf("Expected kept,outer, got %s", names)
+	}
+
+	// a failed outer transaction undoes the committed nested ones
+	Transaction(app, func(tx *Tx) error {
+		tx.Transaction(func(tx *Tx) error {
+			_, err := tx.Collection("products").Create(map[string]any{"name": "lost"})
+			return err
+		})
+		return failure
+	})
+	if total := mustCount(t, app, "products"); total != 2 {
+		t.Errorf("Expected 2 records after outer rollback, got %d", total)
+	}
+}
@@ -0,0 +1,118 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ErrAmbiguousUpsertKey is returned by Upsert when more than one record
+// matches the key fields.
+var ErrAmbiguousUpsertKey = errors.New("upsert key matches more than one record")
+
+// UpsertOptions configures Upsert.
+type UpsertOptions struct {
+	// InsertOnly lists fields that are written when the record is created
+	// but never overwritten when it is updated (e.g. a random password).
+	InsertOnly []string
+}
+
+// Upsert creates a record from data, or updates the existing record whose
+// keyFields values equal the ones in data, and reports whether the record
+// was created.
+//
+// The lookup and the write run in a single transaction, so concurrent
+// upserts of the same key don't create duplicates. Every key field must be
+// present in data; a unique index on the key fields is recommended to
+// keep the lookup fast. ErrAmbiguousUpsertKey is returned when more than
+// one record matches.
+//
+// The lookup includes the soft deleted records of the collection (see
+// EnableSoftDelete): a deleted record matching the key is restored and
+// updated instead of being duplicated, unless data sets the deleted field
+// itself.
+//
+// Example:
+//
+//	record, created, err := dsl.Collection(app, "users").Upsert(
+//	    []string{"openid"},
+//	    map[string]any{"openid": openid, "nickname": nickname, "password": random},
+//	    dsl.UpsertOptions{InsertOnly: []string{"password"}},
+//	)
+func (c *CollectionQueryBuilder) Upsert(keyFields []string, data map[string]any, opts ...UpsertOptions) (*core.Record, bool, error) {
+	if len(keyFields) == 0 {
+		return nil, false, errors.New("upsert requires at least one key field")
+	}
+	keys := make([]Expr, len(keyFields))
+	for i, field := range keyFields {
+		value, ok := data[field]
+		if !ok {
+			return nil, false, fmt.Errorf("missing value for upsert key field %q", field)
+		}
+		keys[i] = Eq(field, value)
+	}
+	var options UpsertOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	var record *core.Record
+	var created bool
+	err := Transaction(c.app, func(tx *Tx) error {
+		collection := c.withApp(tx.App())
+		existing, err := collection.WithDeleted().List(*Where(keys...).Page(1, 2))
+		if err != nil {
+			return err
+		}
+
+		switch len(existing) {
+		case 0:
+			record, err = collection.Create(data)
+			created = true
+			return err
+		case 1:
+			patch := maps.Clone(data)
+			delete(patch, core.FieldNameId)
+			for _, field := range options.InsertOnly {
+				delete(patch, field)
+			}
+			record = existing[0]
+			record.Load(patch)
+			if field := softDeleteField(c.app, record.Collection()); field != "" {
+				if _, ok := data[field]; !ok {
+					record.Set(field, "") // restore a soft deleted match
+				}
+			}
+			return collection.save(record)
+		default:
+			return ErrAmbiguousUpsertKey
+		}
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return record, created, nil
+}
+
+// Upsert creates or updates the record matching the keyFields values of
+// item and reports whether it was created. See
+// CollectionQueryBuilder.Upsert.
+//
+// Example:
+//
+//	product, created, err := repo.Upsert([]string{"sku"}, Product{SKU: "W-1", Name: "Widget"})
+func (r *Repo[T]) Upsert(keyFields []string, item T, opts ...UpsertOptions) (T, bool, error) {
+	var zero T
+	data, err := r.ToMap(item)
+	if err != nil {
+		return zero, false, err
+	}
+	record, created, err := r.collection.Upsert(keyFields, data, opts...)
+	if err != nil {
+		return zero, false, err
+	}
+	result, err := r.FromRecord(record)
+	return result, created, err
+}
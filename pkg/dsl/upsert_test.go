@@ -0,0 +1,66 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+	options := UpsertOptions{InsertOnly: []string{"status"}}
+
+	record, created, err := c.Upsert([]string{"name"}, map[string]any{"name": "widget", "price": 10, "status": "draft"}, options)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if !created || record.GetInt("price") != 10 {
+		t.Errorf("Expected a created record with price 10, got created=%v price=%d", created, record.GetInt("price"))
+	}
+
+	updated, created, err := c.Upsert([]string{"name"}, map[string]any{"name": "widget", "price": 20, "status": "active"}, options)
+	if err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if created || updated.Id != record.Id {
+		t.Errorf("Expected record %s to be updated, got created=%v id=%s", record.Id, created, updated.Id)
+	}
+	if updated.GetInt("price") != 20 || updated.GetString("status") != "draft" {
+		t.Errorf("Expected price 20 and the insert-only status kept, got %d and %s", updated.GetInt("price"), updated.GetString("status"))
+	}
+	if total := mustCount(t, app, "products"); total != 1 {
+		t.Errorf("Expected 1 record, got %d", total)
+	}
+
+	if _, _, err := c.Upsert([]string{"name"}, map[string]any{"price": 1}); err == nil {
+		t.Error("Expected error for a missing key value")
+	}
+
+	mustCreate(t, app, "products", map[string]any{"name": "widget"})
+	if _, _, err := c.Upsert([]string{"name"}, map[string]any{"name": "widget"}); !errors.Is(err, ErrAmbiguousUpsertKey) {
+		t.Errorf("Expected ErrAmbiguousUpsertKey, got %v", err)
+	}
+}
+
+func TestRepoUpsert(t *testing.T) {
+	type product struct {
+		ID    string `pb:"id"`
+		Name  string `pb:"name"`
+		Price int    `pb:"price"`
+	}
+
+	app := newTestApp(t)
+	repo := NewRepo[product](app, "products")
+
+	first, created, err := repo.Upsert([]string{"name"}, product{Name: "widget", Price: 1})
+	if err != nil || !created {
+		t.Fatalf("Expected created record, got %v and %v", created, err)
+	}
+	second, created, err := repo.Upsert([]string{"name"}, product{Name: "widget", Price: 2})
+	if err != nil || created {
+		t.Fatalf("Expected updated record, got %v and %v", created, err)
+	}
+	if second.ID != first.ID || second.Price != 2 {
+		t.Errorf("Expected %s with price 2, got %+v", first.ID, second)
+	}
+}
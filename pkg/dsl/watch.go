@@ -0,0 +1,504 @@
+package dsl
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// watchersStoreKey is the app store key of the change subscriptions.
+const watchersStoreKey = "dsl.watchers"
+
+// watchHookId is the id of the change subscription hook handlers.
+const watchHookId = "dslWatch"
+
+// DefaultWatchBuffer is the number of events buffered by a subscription
+// when WatchOptions.Buffer isn't set.
+const DefaultWatchBuffer = 64
+
+// ChangeType is the kind of a record change.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created" // A record was created
+	ChangeUpdated ChangeType = "updated" // A record was updated
+	ChangeDeleted ChangeType = "deleted" // A record was deleted
+)
+
+// ChangeEvent describes a record change reported by a subscription.
+type ChangeEvent struct {
+	Type       ChangeType   // The kind of change
+	Collection string       // The collection name
+	Old        *core.Record // The record before the change, nil for ChangeCreated
+	New        *core.Record // The record after the change, nil for ChangeDeleted
+	OldMatch   bool         // Whether Old matched the watched query
+	NewMatch   bool         // Whether New matches the watched query
+}
+
+// OverflowPolicy selects what a subscription does with an event when its
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	OverflowDropNewest OverflowPolicy = iota // Discard the new event (default)
+	OverflowDropOldest                       // Discard the oldest buffered event to make room
+	OverflowBlock                            // Block the writer until there is room or the subscription is closed
+)
+
+// WatchOptions configures a subscription.
+type WatchOptions struct {
+	Buffer   int            // Number of buffered events, DefaultWatchBuffer by default
+	Overflow OverflowPolicy // What to do when the buffer is full
+}
+
+// Subscription delivers the changes of the records matching a query, see
+// Watch.
+type Subscription struct {
+	collectionId string         // The watched collection id
+	query        QueryBuilder   // The watched query
+	overflow     OverflowPolicy // What to do when the buffer is full
+	watchers     *watchers      // The registry of the app
+
+	events    chan ChangeEvent // The buffered events
+	done      chan struct{}    // Closed by Close to release blocked writers
+	closeOnce sync.Once        // Runs Close once
+	mu        sync.Mutex       // Serializes the sends with Close
+	closed    bool             // Whether Close was called
+	dropped   atomic.Uint64    // Number of events discarded on overflow
+}
+
+// Watch subscribes to the changes of the records of collection matching
+// query and returns the subscription, whose Events channel receives a
+// ChangeEvent per created, updated or deleted record.
+//
+// The filter of the query is evaluated against the changed record in the
+// database, right before and after the change (within its transaction, so
+// every save of a transaction is matched as it was), with the same
+// semantics as List;
+// sorting and pagination are ignored. Created records are reported when
+// they match, deleted records when they matched, and updated records when
+// they matched before or after the update, so that e.g. an order becoming
+// paid can be told apart with OldMatch and NewMatch.
+//
+// Events are delivered once the change is committed; changes of rolled
+// back transactions and records written without hooks (bulk operations
+// with SkipHooks) aren't reported. When the buffer is full the event is
+// handled according to WatchOptions.Overflow. Close unsubscribes and
+// closes the Events channel.
+//
+// Example:
+//
+//	sub, err := dsl.Watch(app, "orders", *dsl.Query("status = 'paid'"))
+//	if err != nil {
+//	    return err
+//	}
+//	defer sub.Close()
+//
+//	for e := range sub.Events() {
+//	    if e.Type == dsl.ChangeUpdated && e.NewMatch && !e.OldMatch {
+//	        notifyPaid(e.New)
+//	    }
+//	}
+func Watch(app core.App, collection string, query QueryBuilder, opts ...WatchOptions) (*Subscription, error) {
+	var options WatchOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultWatchBuffer
+	}
+
+	c := Collection(app, collection)
+	query = bulkQuery(query)
+	s, err := c.filteredSelect(query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		collectionId: s.collection.Id,
+		query:        query,
+		overflow:     options.Overflow,
+		watchers:     getWatchers(app),
+		events:       make(chan ChangeEvent, options.Buffer),
+		done:         make(chan struct{}),
+	}
+	sub.watchers.add(sub)
+	return sub, nil
+}
+
+// WatchFunc subscribes to the changes like Watch and calls fn for every
+// event from a separate goroutine, in order, until the subscription is
+// closed.
+//
+// Example:
+//
+//	sub, err := dsl.WatchFunc(app, "orders", *dsl.Query("status = 'paid'"), func(e dsl.ChangeEvent) {
+//	    if e.Type == dsl.ChangeUpdated && e.NewMatch && !e.OldMatch {
+//	        notifyPaid(e.New)
+//	    }
+//	})
+func WatchFunc(app core.App, collection string, query QueryBuilder, fn func(e ChangeEvent), opts ...WatchOptions) (*Subscription, error) {
+	sub, err := Watch(app, collection, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for e := range sub.events {
+			fn(e)
+		}
+	}()
+	return sub, nil
+}
+
+// Events returns the channel receiving the change events. It is closed by
+// Close.
+func (s *Subscription) Events() <-chan ChangeEvent {
+	return s.events
+}
+
+// Dropped returns the number of events discarded because the buffer was
+// full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the Events channel. Buffered events can
+// still be received. Close can be called more than once.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.watchers.remove(s)
+		close(s.done) // releases a writer blocked in send
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.events)
+	})
+}
+
+// send delivers e according to the overflow policy.
+func (s *Subscription) send(e ChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+
+	switch s.overflow {
+	case OverflowBlock:
+		select {
+		case s.events <- e:
+		case <-s.done:
+		}
+	case OverflowDropOldest:
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.events <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// matches reports whether record, as stored in app, matches the
+// subscription query.
+func (s *Subscription) matches(app core.App, record *core.Record) (bool, error) {
+	if s.query.filter == "" && softDeleteField(app, record.Collection()) == "" {
+		return true, nil
+	}
+	query := s.query
+	query.filter = andFilters(query.filter, "id = {:dslWatchId}")
+	query.params = maps.Clone(query.params)
+	if query.params == nil {
+		query.params = dbx.Params{}
+	}
+	query.params["dslWatchId"] = record.Id
+
+	ids, _, err := Collection(app, s.collectionId).matchingIds(query)
+	return len(ids) > 0, err
+}
+
+// watchers is the registry of the subscriptions of an app.
+type watchers struct {
+	mu      sync.RWMutex                 // Guards the fields below
+	subs    map[string][]*Subscription   // The subscriptions keyed by collection id
+	pending map[string][]*pendingCapture // The states captured before updates and deletes, in FIFO order, keyed by pendingKey
+}
+
+// pendingCapture is the state of a record captured before one update or
+// delete, for all the subscriptions.
+type pendingCapture struct {
+	key     string          // The pendingKey of the record
+	changes []pendingChange // The captured state per subscription
+	new     *core.Record    // The record as saved, set once the write succeeded, with the newMatch of the changes
+}
+
+// pendingContextKey is the context key of the *pendingCapture of a record
+// event, which identifies the capture of the save in the after hooks.
+type pendingContextKey struct{}
+
+// pendingKey returns the key of the pending captures of record.
+func pendingKey(record *core.Record) string {
+	return record.Collection().Id + "/" + record.Id
+}
+
+// pendingChange is the state of a record captured before an update or a
+// delete, for one subscription.
+type pendingChange struct {
+	sub      *Subscription // The subscription
+	old      *core.Record  // The record before the change
+	oldMatch bool          // Whether old matched the subscription query
+	newMatch bool          // Whether the saved record matched the subscription query
+}
+
+// getWatchers returns the subscription registry of app, creating it and
+// binding its hooks on first use.
+func getWatchers(app core.App) *watchers {
+	return app.Store().GetOrSet(watchersStoreKey, func() any {
+		w := &watchers{
+			subs:    map[string][]*Subscription{},
+			pending: map[string][]*pendingCapture{},
+		}
+
+		app.OnRecordAfterCreateSuccess().Bind(&hook.Handler[*core.RecordEvent]{
+			Id: watchHookId,
+			Func: func(e *core.RecordEvent) error {
+				for _, sub := range w.subscriptions(e.Record.Collection().Id) {
+					match, err := sub.matches(e.App, e.Record)
+					if err != nil {
+						e.App.Logger().Error("Failed to evaluate the watched query", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+						continue
+					}
+					if match {
+						sub.send(ChangeEvent{Type: ChangeCreated, Collection: e.Record.Collection().Name, New: e.Record.Clone(), NewMatch: true})
+					}
+				}
+				return e.Next()
+			},
+		})
+
+		capture := func(e *core.RecordEvent, update bool) error {
+			subs := w.subscriptions(e.Record.Collection().Id)
+			if len(subs) == 0 {
+				return e.Next()
+			}
+			old, err := e.App.FindRecordById(e.Record.Collection(), e.Record.Id)
+			if err != nil {
+				e.App.Logger().Error("Failed to load the watched record", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+				return e.Next()
+			}
+			changes := make([]pendingChange, 0, len(subs))
+			for _, sub := range subs {
+				match, err := sub.matches(e.App, old)
+				if err != nil {
+					e.App.Logger().Error("Failed to evaluate the watched query", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+					continue
+				}
+				changes = append(changes, pendingChange{sub: sub, old: old, oldMatch: match})
+			}
+			pending := w.pushPending(e.Record, changes)
+			// the context is shared with the after hooks of this save
+			e.Context = context.WithValue(e.Context, pendingContextKey{}, pending)
+			if err := e.Next(); err != nil {
+				w.removePending(pending)
+				return err
+			}
+			if !update {
+				return nil
+			}
+			// the after hooks of a transaction run on commit, when the record
+			// (object and row) may have changed again, so the saved state is
+			// matched now, within the transaction
+			saved := make([]pendingChange, 0, len(changes))
+			for _, change := range changes {
+				match, err := change.sub.matches(e.App, e.Record)
+				if err != nil {
+					e.App.Logger().Error("Failed to evaluate the watched query", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+					continue
+				}
+				change.newMatch = match
+				saved = append(saved, change)
+			}
+			w.mu.Lock()
+			pending.changes = saved
+			pending.new = e.Record.Clone()
+			w.mu.Unlock()
+			return nil
+		}
+		discard := func(e *core.RecordErrorEvent) error {
+			w.takePending(e.Context, e.Record)
+			return e.Next()
+		}
+		app.OnRecordUpdate().Bind(&hook.Handler[*core.RecordEvent]{
+			Id:   watchHookId,
+			Func: func(e *core.RecordEvent) error { return capture(e, true) },
+		})
+		app.OnRecordDelete().Bind(&hook.Handler[*core.RecordEvent]{
+			Id:   watchHookId,
+			Func: func(e *core.RecordEvent) error { return capture(e, false) },
+		})
+		app.OnRecordAfterUpdateError().Bind(&hook.Handler[*core.RecordErrorEvent]{Id: watchHookId, Func: discard})
+		app.OnRecordAfterDeleteError().Bind(&hook.Handler[*core.RecordErrorEvent]{Id: watchHookId, Func: discard})
+
+		app.OnRecordAfterUpdateSuccess().Bind(&hook.Handler[*core.RecordEvent]{
+			Id: watchHookId,
+			Func: func(e *core.RecordEvent) error {
+				pending := w.takePending(e.Context, e.Record)
+				if pending == nil {
+					return e.Next()
+				}
+				record, saved := pending.new, pending.new != nil
+				if !saved {
+					record = e.Record.Clone()
+				}
+				for _, change := range pending.changes {
+					match := change.newMatch
+					if !saved {
+						var err error
+						if match, err = change.sub.matches(e.App, record); err != nil {
+							e.App.Logger().Error("Failed to evaluate the watched query", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+							continue
+						}
+					}
+					if change.oldMatch || match {
+						change.sub.send(ChangeEvent{
+							Type:       ChangeUpdated,
+							Collection: e.Record.Collection().Name,
+							Old:        change.old,
+							New:        record.Clone(),
+							OldMatch:   change.oldMatch,
+							NewMatch:   match,
+						})
+					}
+				}
+				return e.Next()
+			},
+		})
+
+		app.OnRecordAfterDeleteSuccess().Bind(&hook.Handler[*core.RecordEvent]{
+			Id: watchHookId,
+			Func: func(e *core.RecordEvent) error {
+				for _, change := range w.takePending(e.Context, e.Record).pendingChanges() {
+					if change.oldMatch {
+						change.sub.send(ChangeEvent{Type: ChangeDeleted, Collection: e.Record.Collection().Name, Old: change.old, OldMatch: true})
+					}
+				}
+				return e.Next()
+			},
+		})
+
+		return w
+	}).(*watchers)
+}
+
+// add registers sub.
+func (w *watchers) add(sub *Subscription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs[sub.collectionId] = append(w.subs[sub.collectionId], sub)
+}
+
+// remove unregisters sub.
+func (w *watchers) remove(sub *Subscription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	subs := w.subs[sub.collectionId]
+	for i, s := range subs {
+		if s == sub {
+			w.subs[sub.collectionId] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(w.subs[sub.collectionId]) == 0 {
+		delete(w.subs, sub.collectionId)
+	}
+}
+
+// subscriptions returns the subscriptions of the collection with the
+// given id.
+func (w *watchers) subscriptions(collectionId string) []*Subscription {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.subs[collectionId]
+}
+
+// pushPending queues the state captured before a change of record.
+func (w *watchers) pushPending(record *core.Record, changes []pendingChange) *pendingCapture {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	capture := &pendingCapture{key: pendingKey(record), changes: changes}
+	w.pending[capture.key] = append(w.pending[capture.key], capture)
+	return capture
+}
+
+// removePending removes capture from the queue of its record.
+func (w *watchers) removePending(capture *pendingCapture) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.removePendingLocked(capture)
+}
+
+// removePendingLocked removes capture from the queue of its record, with
+// w.mu held.
+func (w *watchers) removePendingLocked(capture *pendingCapture) {
+	queue := w.pending[capture.key]
+	if i := slices.Index(queue, capture); i >= 0 {
+		queue = slices.Delete(queue, i, i+1)
+	}
+	if len(queue) == 0 {
+		delete(w.pending, capture.key)
+	} else {
+		w.pending[capture.key] = queue
+	}
+}
+
+// takePending removes and returns the capture of the change of record the
+// after hook with context ctx reports: the capture of the same
+// save when the context carries it, and the oldest capture of the record
+// otherwise.
+func (w *watchers) takePending(ctx context.Context, record *core.Record) *pendingCapture {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := pendingKey(record)
+	queue := w.pending[key]
+	if capture, ok := ctx.Value(pendingContextKey{}).(*pendingCapture); ok && capture.key == key {
+		if !slices.Contains(queue, capture) {
+			return nil // already discarded by the failed save
+		}
+		w.removePendingLocked(capture)
+		return capture
+	}
+	if len(queue) == 0 {
+		return nil
+	}
+	capture := queue[0]
+	w.removePendingLocked(capture)
+	return capture
+}
+
+// pendingChanges returns the captured changes of c, which may be nil.
+func (c *pendingCapture) pendingChanges() []pendingChange {
+	if c == nil {
+		return nil
+	}
+	return c.changes
+}
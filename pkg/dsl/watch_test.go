@@ -0,0 +1,269 @@
+package dsl
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// nextEvent returns the next event of sub or fails the test.
+func nextEvent(t *testing.T, sub *Subscription) ChangeEvent {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.Events():
+		if !ok {
+			t.Fatal("Expected an event, the subscription is closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return ChangeEvent{}
+}
+
+// expectNoEvent fails the test if sub has a pending event.
+func expectNoEvent(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	select {
+	case e := <-sub.Events():
+		t.Errorf("Expected no event, got %s of %s", e.Type, eventName(e))
+	default:
+	}
+}
+
+// eventName returns the name of the record of e.
+func eventName(e ChangeEvent) string {
+	if e.New != nil {
+		return e.New.GetString("name")
+	}
+	return e.Old.GetString("name")
+}
+
+func TestWatch(t *testing.T) {
+	app := newTestApp(t)
+	c := Collection(app, "products")
+
+	sub, err := Watch(app, "products", *Query("status = 'active'"))
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer sub.Close()
+
+	mustCreate(t, app, "products", map[string]any{"name": "draft", "status": "draft"})
+	expectNoEvent(t, sub)
+
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "price": 10})
+	e := nextEvent(t, sub)
+	if e.Type != ChangeCreated || e.Collection != "products" || e.New.Id != hammer.Id || e.Old != nil || !e.NewMatch {
+		t.Errorf("Expected the created hammer, got %+v", e)
+	}
+
+	// updates report the old and new values and whether they match
+	if _, err := c.Update(hammer.Id, map[string]any{"price": 12}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	e = nextEvent(t, sub)
+	if e.Type != ChangeUpdated || e.Old.GetInt("price") != 10 || e.New.GetInt("price") != 12 || !e.OldMatch || !e.NewMatch {
+		t.Errorf("Expected the price update from 10 to 12, got %+v", e)
+	}
+
+	if _, err := c.Update(hammer.Id, map[string]any{"status": "archived"}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	e = nextEvent(t, sub)
+	if e.Type != ChangeUpdated || !e.OldMatch || e.NewMatch {
+		t.Errorf("Expected the hammer to leave the query, got %+v", e)
+	}
+
+	// changes outside of the query aren't reported
+	if _, err := c.Update(hammer.Id, map[string]any{"price": 1}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	expectNoEvent(t, sub)
+
+	if _, err := c.Update(hammer.Id, map[string]any{"status": "active"}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	e = nextEvent(t, sub)
+	if e.Type != ChangeUpdated || e.OldMatch || !e.NewMatch {
+		t.Errorf("Expected the hammer to enter the query, got %+v", e)
+	}
+
+	if err := c.Delete(hammer.Id); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	e = nextEvent(t, sub)
+	if e.Type != ChangeDeleted || e.Old.Id != hammer.Id || e.New != nil || !e.OldMatch {
+		t.Errorf("Expected the deleted hammer, got %+v", e)
+	}
+
+	// rolled back changes aren't reported
+	err = Transaction(app, func(tx *Tx) error {
+		if _, err := tx.Collection("products").Create(map[string]any{"name": "saw", "status": "active"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction to fail")
+	}
+	expectNoEvent(t, sub)
+
+	// other collections aren't reported
+	mustCreate(t, app, "categories", map[string]any{"name": "tools"})
+	expectNoEvent(t, sub)
+
+	sub.Close()
+	sub.Close()
+	mustCreate(t, app, "products", map[string]any{"name": "drill", "status": "active"})
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Expected the events channel to be closed")
+	}
+
+	if _, err := Watch(app, "products", *Query("missing = 1")); err == nil {
+		t.Error("Expected error for an invalid filter")
+	}
+	if _, err := Watch(app, "missing", *Query("")); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestWatchSameRecordInTransaction(t *testing.T) {
+	app := newTestApp(t)
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "active", "price": 10})
+
+	sub, err := Watch(app, "products", *Query(""))
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer sub.Close()
+
+	// the same record object saved twice, with a failed save in between
+	err = app.RunInTransaction(func(txApp core.App) error {
+		hammer.Set("price", 11)
+		if err := txApp.Save(hammer); err != nil {
+			return err
+		}
+		hammer.Set("status", "invalid")
+		if err := txApp.Save(hammer); err == nil {
+			t.Error("Expected the invalid status to fail the save")
+		}
+		hammer.Set("status", "active")
+		hammer.Set("price", 12)
+		return txApp.Save(hammer)
+	})
+	if err != nil {
+		t.Fatalf("Failed to run the transaction: %v", err)
+	}
+
+	for _, prices := range [][2]int{{10, 11}, {11, 12}} {
+		e := nextEvent(t, sub)
+		if e.Type != ChangeUpdated || e.Old.GetInt("price") != prices[0] || e.New.GetInt("price") != prices[1] {
+			t.Errorf("Expected the price update from %d to %d, got %s from %d to %d", prices[0], prices[1], e.Type, e.Old.GetInt("price"), e.New.GetInt("price"))
+		}
+	}
+	expectNoEvent(t, sub)
+}
+
+func TestWatchUpdatesInTransaction(t *testing.T) {
+	app := newTestApp(t)
+	hammer := mustCreate(t, app, "products", map[string]any{"name": "hammer", "status": "draft"})
+
+	sub, err := Watch(app, "products", *Query("status = 'active'"))
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer sub.Close()
+
+	// the record enters and leaves the query before the commit
+	err = Transaction(app, func(tx *Tx) error {
+		products := tx.Collection("products")
+		if _, err := products.Update(hammer.Id, map[string]any{"status": "active"}); err != nil {
+			return err
+		}
+		_, err := products.Update(hammer.Id, map[string]any{"status": "archived"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to run the transaction: %v", err)
+	}
+
+	e := nextEvent(t, sub)
+	if e.Type != ChangeUpdated || e.OldMatch || !e.NewMatch || e.New.GetString("status") != "active" {
+		t.Errorf("Expected the record to become active, got %s %v->%v %s", e.Type, e.OldMatch, e.NewMatch, e.New.GetString("status"))
+	}
+	e = nextEvent(t, sub)
+	if e.Type != ChangeUpdated || !e.OldMatch || e.NewMatch || e.New.GetString("status") != "archived" {
+		t.Errorf("Expected the record to stop being active, got %s %v->%v %s", e.Type, e.OldMatch, e.NewMatch, e.New.GetString("status"))
+	}
+	expectNoEvent(t, sub)
+}
+
+func TestWatchOverflow(t *testing.T) {
+	app := newTestApp(t)
+
+	newest, err := Watch(app, "products", *Query(""), WatchOptions{Buffer: 2})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer newest.Close()
+	oldest, err := Watch(app, "products", *Query(""), WatchOptions{Buffer: 2, Overflow: OverflowDropOldest})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer oldest.Close()
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		mustCreate(t, app, "products", map[string]any{"name": name})
+	}
+
+	if newest.Dropped() != 2 || oldest.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped events each, got %d and %d", newest.Dropped(), oldest.Dropped())
+	}
+	if names := eventName(nextEvent(t, newest)) + eventName(nextEvent(t, newest)); names != "ab" {
+		t.Errorf("Expected the first events to be kept, got %s", names)
+	}
+	if names := eventName(nextEvent(t, oldest)) + eventName(nextEvent(t, oldest)); names != "cd" {
+		t.Errorf("Expected the last events to be kept, got %s", names)
+	}
+}
+
+func TestWatchFunc(t *testing.T) {
+	app := newTestApp(t)
+
+	var mu sync.Mutex
+	var names []string
+	received := make(chan struct{}, 10)
+	sub, err := WatchFunc(app, "products", *Query("price > 5"), func(e ChangeEvent) {
+		mu.Lock()
+		names = append(names, eventName(e))
+		mu.Unlock()
+		received <- struct{}{}
+	}, WatchOptions{Overflow: OverflowBlock})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	mustCreate(t, app, "products", map[string]any{"name": "cheap", "price": 1})
+	mustCreate(t, app, "products", map[string]any{"name": "hammer", "price": 10})
+	mustCreate(t, app, "products", map[string]any{"name": "saw", "price": 20})
+	for range 2 {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for the callback")
+		}
+	}
+	sub.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) != 2 || names[0] != "hammer" || names[1] != "saw" {
+		t.Errorf("Expected hammer and saw, got %v", names)
+	}
+}
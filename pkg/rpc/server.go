@@ -20,6 +20,7 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -317,7 +318,7 @@ func (s *Server) handleRPC(e *core.RequestEvent, serviceName, methodName string)
 		// Method returns (result, error)
 		if !results[1].IsNil() {
 			err := results[1].Interface().(error)
-			return e.JSON(http.StatusInternalServerError, err)
+			return e.JSON(errorStatus(err), err)
 		}
 		// Return the first result as the response
 		response := results[0].Interface()
@@ -326,7 +327,7 @@ func (s *Server) handleRPC(e *core.RequestEvent, serviceName, methodName string)
 		// Method returns only error
 		if !results[0].IsNil() {
 			err := results[0].Interface().(error)
-			return e.JSON(http.StatusInternalServerError, err)
+			return e.JSON(errorStatus(err), err)
 		}
 		// Return success status
 		return e.JSON(http.StatusOK, map[string]string{"status": "ok"})
@@ -377,7 +378,7 @@ func (s *Server) handleRPCGet(e *core.RequestEvent, serviceName, entityName, id
 		// Method returns (result, error)
 		if !results[1].IsNil() {
 			err := results[1].Interface().(error)
-			return e.JSON(http.StatusInternalServerError, err)
+			return e.JSON(errorStatus(err), err)
 		}
 		// Return the first result as the response
 		response := results[0].Interface()
@@ -386,13 +387,33 @@ func (s *Server) handleRPCGet(e *core.RequestEvent, serviceName, entityName, id
 		// Method returns only error
 		if !results[0].IsNil() {
 			err := results[0].Interface().(error)
-			return e.JSON(http.StatusInternalServerError, err)
+			return e.JSON(errorStatus(err), err)
 		}
 		// Return success status
 		return e.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	}
 }
 
+// HTTPError is implemented by errors that carry the HTTP status code the
+// server should respond with, such as *dsl.ConflictError (409 Conflict).
+//
+// Errors returned by service methods that don't implement HTTPError,
+// directly or wrapped, are reported as 500 Internal Server Error.
+type HTTPError interface {
+	error
+	HTTPStatus() int // The HTTP status code of the response
+}
+
+// errorStatus returns the HTTP status code for an error returned by a
+// service method.
+func errorStatus(err error) int {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.HTTPStatus()
+	}
+	return http.StatusInternalServerError
+}
+
 // kebabToPascal converts kebab-case to PascalCase.
 //
 // This utility function converts URL-friendly kebab-case strings to
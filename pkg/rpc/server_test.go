@@ -1,6 +1,9 @@
 package rpc
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 )
 
@@ -256,6 +259,30 @@ func TestKebabToPascal(t *testing.T) {
 	}
 }
 
+// conflictError is a test error carrying an HTTP status code
+type conflictError struct{}
+
+func (e *conflictError) Error() string   { return "conflict" }
+func (e *conflictError) HTTPStatus() int { return http.StatusConflict }
+
+// TestErrorStatus tests the HTTP status codes of service method errors
+func TestErrorStatus(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("boom"), http.StatusInternalServerError},
+		{&conflictError{}, http.StatusConflict},
+		{fmt.Errorf("saving product: %w", &conflictError{}), http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		if status := errorStatus(tc.err); status != tc.expected {
+			t.Errorf("errorStatus(%v) = %d, expected %d", tc.err, status, tc.expected)
+		}
+	}
+}
+
 // TestServerServicesCount tests that services are properly counted
 func TestServerServicesCount(t *testing.T) {
 	server := NewServer()
@@ -78,7 +78,7 @@ func exchangeWechatAuthInfo(store AuthHandler, code string) (*core.Record, error
 
 	// Check if user already exists with this code (avoid re-signin with same code)
 	record, err := store.FindAuthRecordByCode(code)
-	if err != nil && err != NoAuthRecordError && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, NoAuthRecordError) && !errors.Is(err, sql.ErrNoRows) {
 		log.Printf("find user by code failed,%v \n", err)
 		return nil, errors.New("find user by code failed")
 	}